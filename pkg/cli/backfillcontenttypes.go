@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+type backfillContentTypes struct {
+	root *workspaceProvider
+}
+
+func (b *backfillContentTypes) Customize(c *cobra.Command) {
+	c.Args = cobra.MinimumNArgs(1)
+	c.Use = "backfill-content-types [OPTIONS] ID..."
+	c.Short = "Store the content types of files written before content types were stored"
+}
+
+func (b *backfillContentTypes) Run(cmd *cobra.Command, args []string) error {
+	for _, arg := range args {
+		updated, err := b.root.client.BackfillContentTypes(cmd.Context(), arg)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("stored the content types of %d files in workspace %s\n", updated, arg)
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gptscript-ai/workspace-provider/pkg/client"
+	"github.com/spf13/cobra"
+)
+
+type batch struct {
+	root *workspaceProvider
+
+	Write                 []string `usage:"Write a local file into the workspace, given as FILE=LOCAL_FILE"`
+	Delete                []string `usage:"Delete a file from the workspace"`
+	WithoutCreateRevision bool     `usage:"Do not create new revisions"`
+}
+
+func (b *batch) Customize(c *cobra.Command) {
+	c.Args = cobra.ExactArgs(1)
+	c.Use = "batch [OPTIONS] ID"
+	c.Short = "Write and delete files in a workspace all or nothing"
+}
+
+func (b *batch) Run(cmd *cobra.Command, args []string) error {
+	var ops []client.BatchOperation
+	for _, write := range b.Write {
+		fileName, localFile, ok := strings.Cut(write, "=")
+		if !ok {
+			return fmt.Errorf("invalid write %q: must be FILE=LOCAL_FILE", write)
+		}
+
+		f, err := os.Open(localFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		ops = append(ops, client.BatchOperation{Op: client.BatchWrite, FileName: fileName, Content: f})
+	}
+	for _, fileName := range b.Delete {
+		ops = append(ops, client.BatchOperation{Op: client.BatchDelete, FileName: fileName})
+	}
+
+	return b.root.client.Batch(cmd.Context(), args[0], ops, client.BatchOptions{
+		CreateRevision: &[]bool{!b.WithoutCreateRevision}[0],
+	})
+}
@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+type blame struct {
+	root *workspaceProvider
+}
+
+func (b *blame) Customize(c *cobra.Command) {
+	c.Args = cobra.ExactArgs(2)
+	c.Use = "blame [OPTIONS] ID FILE"
+	c.Short = "Show the revision that introduced each line of a text file"
+}
+
+func (b *blame) Run(cmd *cobra.Command, args []string) error {
+	lines, err := b.root.client.Blame(cmd.Context(), args[0], args[1])
+	if err != nil {
+		return err
+	}
+
+	writer := cmd.OutOrStdout()
+	for _, line := range lines {
+		revision := line.RevisionID
+		if revision == "" {
+			revision = "current"
+		}
+		_, _ = fmt.Fprintf(writer, "%s\t%d\t%s\n", revision, line.LineNumber, line.Text)
+	}
+
+	return nil
+}
@@ -3,16 +3,28 @@ package cli
 import (
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/gptscript-ai/workspace-provider/pkg/client"
 	"github.com/spf13/cobra"
 )
 
 type create struct {
 	root *workspaceProvider
+
+	Name        string   `usage:"A unique name for the workspace, which can be given as name://NAME instead of its ID"`
+	Labels      []string `usage:"Label the workspace with this key=value" name:"label"`
+	Owner       string   `usage:"The owner of the workspace" env:"CREATE_OWNER"`
+	Description string   `usage:"A description of the workspace" env:"CREATE_DESCRIPTION"`
+	MaxBytes    int64    `usage:"The maximum bytes stored by the workspace, including revisions"`
+	MaxFiles    int64    `usage:"The maximum number of files in the workspace"`
+	MaxFileSize int64    `usage:"The maximum size of each file in the workspace"`
+	ExpiresAt   string   `usage:"Expire the workspace at this RFC 3339 time" env:"CREATE_EXPIRES_AT"`
+	TTL         string   `usage:"Expire the workspace after this long, such as '24h'" name:"ttl" env:"CREATE_TTL"`
 }
 
 func (c *create) Customize(cmd *cobra.Command) {
-	cmd.Use = "create [ID...]"
+	cmd.Use = "create [OPTIONS] [ID...]"
 	cmd.Short = "Create a new workspace, optionally from one or more IDs"
 }
 
@@ -25,7 +37,36 @@ func (c *create) Run(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	workspace, err := c.root.client.Create(cmd.Context(), c.root.Provider, args...)
+	labels, err := parseLabels(c.Labels)
+	if err != nil {
+		return err
+	}
+
+	var (
+		expiresAt time.Time
+		ttl       time.Duration
+	)
+	if c.ExpiresAt != "" {
+		if expiresAt, err = time.Parse(time.RFC3339Nano, c.ExpiresAt); err != nil {
+			return fmt.Errorf("invalid expiry time: %w", err)
+		}
+	}
+	if c.TTL != "" {
+		if ttl, err = time.ParseDuration(c.TTL); err != nil {
+			return fmt.Errorf("invalid TTL: %w", err)
+		}
+	}
+
+	workspace, err := c.root.client.CreateWithOptions(cmd.Context(), c.root.Provider, client.CreateOptions{
+		FromWorkspaces: args,
+		Name:           c.Name,
+		Labels:         labels,
+		Owner:          c.Owner,
+		Description:    c.Description,
+		Quota:          client.Quota{MaxBytes: c.MaxBytes, MaxFiles: c.MaxFiles, MaxFileSize: c.MaxFileSize},
+		ExpiresAt:      expiresAt,
+		TTL:            ttl,
+	})
 	if err != nil {
 		return err
 	}
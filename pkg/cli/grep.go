@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/gptscript-ai/workspace-provider/pkg/client"
+	"github.com/spf13/cobra"
+)
+
+type grep struct {
+	root *workspaceProvider
+
+	Regex       bool     `usage:"Treat the pattern as a regular expression instead of literal text" short:"E"`
+	IgnoreCase  bool     `usage:"Match the pattern regardless of case" short:"i"`
+	Prefix      string   `usage:"Only search files with this prefix"`
+	Include     []string `usage:"Only search files that match this glob pattern, such as '**/*.md'"`
+	Exclude     []string `usage:"Do not search files that match this glob pattern"`
+	MimeType    []string `usage:"Only search files with this mime type, such as 'text/markdown'"`
+	MaxFileSize int64    `usage:"Skip files larger than this many bytes"`
+	Context     int      `usage:"Print this many lines of context before and after each match" short:"C"`
+	MaxMatches  int      `usage:"Stop after this many matches"`
+}
+
+func (g *grep) Customize(c *cobra.Command) {
+	c.Args = cobra.ExactArgs(2)
+	c.Use = "grep [OPTIONS] ID PATTERN"
+	c.Short = "Search the text files in a workspace"
+}
+
+func (g *grep) Run(cmd *cobra.Command, args []string) error {
+	matches, err := g.root.client.Search(cmd.Context(), args[0], args[1], client.SearchOptions{
+		Regex:        g.Regex,
+		IgnoreCase:   g.IgnoreCase,
+		Prefix:       g.Prefix,
+		Include:      g.Include,
+		Exclude:      g.Exclude,
+		MimeTypes:    g.MimeType,
+		MaxFileSize:  g.MaxFileSize,
+		ContextLines: g.Context,
+		MaxMatches:   g.MaxMatches,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, match := range matches {
+		if g.Context > 0 {
+			for i, line := range match.Before {
+				fmt.Printf("%s-%d-%s\n", match.FileName, match.Line-len(match.Before)+i, line)
+			}
+		}
+		fmt.Printf("%s:%d:%s\n", match.FileName, match.Line, match.Text)
+		if g.Context > 0 {
+			for i, line := range match.After {
+				fmt.Printf("%s-%d-%s\n", match.FileName, match.Line+i+1, line)
+			}
+			fmt.Println("--")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+type info struct {
+	root *workspaceProvider
+}
+
+func (i *info) Customize(c *cobra.Command) {
+	c.Args = cobra.ExactArgs(1)
+	c.Use = "info [OPTIONS] ID"
+	c.Short = "Print the manifest of a workspace"
+}
+
+func (i *info) Run(cmd *cobra.Command, args []string) error {
+	info, err := i.root.client.Info(cmd.Context(), args[0])
+	if err != nil {
+		return err
+	}
+
+	writer := cmd.OutOrStdout()
+
+	_, _ = writer.Write([]byte("workspace id: " + info.ID + "\n"))
+	_, _ = writer.Write([]byte("provider: " + info.Provider + "\n"))
+	if info.Name != "" {
+		_, _ = writer.Write([]byte("name: " + info.Name + "\n"))
+	}
+	if !info.CreatedAt.IsZero() {
+		_, _ = writer.Write([]byte("created at: " + info.CreatedAt.String() + "\n"))
+	}
+	if !info.ExpiresAt.IsZero() {
+		_, _ = writer.Write([]byte("expires at: " + info.ExpiresAt.String() + "\n"))
+	}
+	if info.Owner != "" {
+		_, _ = writer.Write([]byte("owner: " + info.Owner + "\n"))
+	}
+	if info.Description != "" {
+		_, _ = writer.Write([]byte("description: " + info.Description + "\n"))
+	}
+	if len(info.FromWorkspaces) > 0 {
+		_, _ = writer.Write([]byte("from workspaces: " + strings.Join(info.FromWorkspaces, ",") + "\n"))
+	}
+	for _, key := range slices.Sorted(maps.Keys(info.Labels)) {
+		_, _ = writer.Write([]byte(fmt.Sprintf("label: %s=%s\n", key, info.Labels[key])))
+	}
+
+	return nil
+}
+
+type setLabels struct {
+	root *workspaceProvider
+}
+
+func (s *setLabels) Customize(c *cobra.Command) {
+	c.Args = cobra.MinimumNArgs(1)
+	c.Use = "set-labels [OPTIONS] ID [KEY=VALUE...]"
+	c.Short = "Replace the labels of a workspace, removing them if no values are given"
+}
+
+func (s *setLabels) Run(cmd *cobra.Command, args []string) error {
+	labels, err := parseLabels(args[1:])
+	if err != nil {
+		return err
+	}
+
+	return s.root.client.SetLabels(cmd.Context(), args[0], labels)
+}
+
+// parseLabels parses labels of the form key=value.
+func parseLabels(values []string) (map[string]string, error) {
+	return parseKeyValues("label", values)
+}
@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gptscript-ai/workspace-provider/pkg/client"
+	"github.com/spf13/cobra"
+)
+
+type list struct {
+	root *workspaceProvider
+
+	Labels       []string `usage:"Only list workspaces with this key=value label" name:"label"`
+	Long         bool     `usage:"Include the creation time and owner of each workspace" short:"l"`
+	Limit        int      `usage:"List a page of at most this many workspaces, followed by the continuation for the next page"`
+	Continuation string   `usage:"List the page of workspaces after the one that printed this continuation"`
+}
+
+func (l *list) Customize(c *cobra.Command) {
+	c.Args = cobra.NoArgs
+	c.Use = "list [OPTIONS]"
+	c.Short = "List the workspaces of the provider"
+}
+
+func (l *list) Run(cmd *cobra.Command, _ []string) error {
+	labels, err := parseLabels(l.Labels)
+	if err != nil {
+		return err
+	}
+
+	page, err := l.root.client.List(cmd.Context(), l.root.Provider, client.ListOptions{
+		Labels:       labels,
+		Limit:        l.Limit,
+		Continuation: l.Continuation,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, info := range page.Workspaces {
+		if !l.Long {
+			fmt.Println(info.ID)
+			continue
+		}
+
+		var createdAt string
+		if !info.CreatedAt.IsZero() {
+			createdAt = info.CreatedAt.Format(time.RFC3339)
+		}
+		fmt.Printf("%s\t%s\t%s\n", createdAt, info.Owner, info.ID)
+	}
+
+	if page.Continuation != "" {
+		fmt.Printf("continuation: %s\n", page.Continuation)
+	}
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/gptscript-ai/workspace-provider/pkg/client"
+	"github.com/spf13/cobra"
+)
+
+type listRevisions struct {
+	root *workspaceProvider
+
+	Limit       int    `usage:"Maximum number of revisions to list" env:"LIST_REVISIONS_LIMIT"`
+	Cursor      string `usage:"Only list revisions after this revision ID" env:"LIST_REVISIONS_CURSOR"`
+	NewestFirst bool   `usage:"List the newest revisions first" env:"LIST_REVISIONS_NEWEST_FIRST"`
+}
+
+func (l *listRevisions) Customize(c *cobra.Command) {
+	c.Args = cobra.ExactArgs(2)
+	c.Use = "list-revisions [OPTIONS] ID FILE"
+	c.Short = "List the revisions of a file in a workspace"
+}
+
+func (l *listRevisions) Run(cmd *cobra.Command, args []string) error {
+	revisions, err := l.root.client.ListRevisions(cmd.Context(), args[0], args[1], client.ListRevisionsOptions{
+		Limit:       l.Limit,
+		Cursor:      l.Cursor,
+		NewestFirst: l.NewestFirst,
+	})
+	if err != nil {
+		return err
+	}
+
+	writer := cmd.OutOrStdout()
+	for _, rev := range revisions {
+		_, _ = fmt.Fprintf(writer, "%s\t%d\t%s\n", rev.RevisionID, rev.Size, rev.ModTime)
+	}
+
+	return nil
+}
@@ -3,14 +3,25 @@ package cli
 import (
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/gptscript-ai/workspace-provider/pkg/client"
 	"github.com/spf13/cobra"
 )
 
 type ls struct {
 	root *workspaceProvider
 
-	Prefix string `usage:"Only list files with this prefix" env:"LS_PREFIX"`
+	Prefix       string   `usage:"Only list files with this prefix" env:"LS_PREFIX"`
+	Include      []string `usage:"Only list files that match this glob pattern, such as '**/*.md'"`
+	Exclude      []string `usage:"Do not list files that match this glob pattern"`
+	Metadata     []string `usage:"Only list files with this key=value metadata"`
+	Long         bool     `usage:"Include the size and modification time of each file" short:"l"`
+	SortBy       string   `usage:"Sort long listings by 'name', 'size' or 'modTime'" default:"name"`
+	Descending   bool     `usage:"Sort long listings in descending order"`
+	NonRecursive bool     `usage:"Only list the files immediately under the prefix, and its subdirectories with a trailing slash" name:"non-recursive"`
+	Limit        int      `usage:"List a page of at most this many files, unsorted, followed by the continuation for the next page"`
+	Continuation string   `usage:"List the page of files after the one that printed this continuation"`
 }
 
 func (l *ls) Customize(c *cobra.Command) {
@@ -20,8 +31,52 @@ func (l *ls) Customize(c *cobra.Command) {
 }
 
 func (l *ls) Run(cmd *cobra.Command, args []string) error {
+	metadata, err := parseMetadata(l.Metadata)
+	if err != nil {
+		return err
+	}
+
 	for _, arg := range args {
-		contents, err := l.root.client.Ls(cmd.Context(), arg, l.Prefix)
+		if l.Limit != 0 || l.Continuation != "" {
+			page, err := l.root.client.LsPage(cmd.Context(), arg, l.Prefix, client.LsOptions{
+				Recursive:    &[]bool{!l.NonRecursive}[0],
+				Include:      l.Include,
+				Exclude:      l.Exclude,
+				Metadata:     metadata,
+				Limit:        l.Limit,
+				Continuation: l.Continuation,
+			})
+			if err != nil {
+				return err
+			}
+
+			printPage(arg, page, l.Long)
+			continue
+		}
+
+		if l.Long {
+			infos, err := l.root.client.LsWithInfo(cmd.Context(), arg, l.Prefix, client.LsOptions{
+				Recursive:  &[]bool{!l.NonRecursive}[0],
+				Include:    l.Include,
+				Exclude:    l.Exclude,
+				Metadata:   metadata,
+				SortBy:     l.SortBy,
+				Descending: l.Descending,
+			})
+			if err != nil {
+				return err
+			}
+
+			printInfos(arg, infos)
+			continue
+		}
+
+		contents, err := l.root.client.Ls(cmd.Context(), arg, l.Prefix, client.LsOptions{
+			Recursive: &[]bool{!l.NonRecursive}[0],
+			Include:   l.Include,
+			Exclude:   l.Exclude,
+			Metadata:  metadata,
+		})
 		if err != nil {
 			return err
 		}
@@ -37,3 +92,31 @@ func printContent(id string, content []string) {
 	fmt.Println(strings.Join(content, "\n"))
 	fmt.Print("\n\n")
 }
+
+func printInfos(id string, infos []client.FileInfo) {
+	fmt.Printf("%s:\n", id)
+	for _, info := range infos {
+		if info.IsDir {
+			fmt.Printf("-\t-\t%s\n", info.Name)
+			continue
+		}
+		fmt.Printf("%d\t%s\t%s\n", info.Size, info.ModTime.Format(time.RFC3339), info.Name)
+	}
+	fmt.Print("\n\n")
+}
+
+func printPage(id string, page client.LsPage, long bool) {
+	if long {
+		printInfos(id, page.Files)
+	} else {
+		names := make([]string, 0, len(page.Files))
+		for _, info := range page.Files {
+			names = append(names, info.Name)
+		}
+		printContent(id, names)
+	}
+
+	if page.Continuation != "" {
+		fmt.Printf("continuation: %s\n\n", page.Continuation)
+	}
+}
@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+type setMetadata struct {
+	root *workspaceProvider
+}
+
+func (s *setMetadata) Customize(c *cobra.Command) {
+	c.Args = cobra.MinimumNArgs(2)
+	c.Use = "set-metadata [OPTIONS] ID FILE [KEY=VALUE...]"
+	c.Short = "Replace the metadata of a file in a workspace, removing it if no values are given"
+}
+
+func (s *setMetadata) Run(cmd *cobra.Command, args []string) error {
+	metadata, err := parseMetadata(args[2:])
+	if err != nil {
+		return err
+	}
+
+	return s.root.client.SetMetadata(cmd.Context(), args[0], args[1], metadata)
+}
+
+type getMetadata struct {
+	root *workspaceProvider
+}
+
+func (g *getMetadata) Customize(c *cobra.Command) {
+	c.Args = cobra.ExactArgs(2)
+	c.Use = "get-metadata [OPTIONS] ID FILE"
+	c.Short = "Print the metadata of a file in a workspace"
+}
+
+func (g *getMetadata) Run(cmd *cobra.Command, args []string) error {
+	metadata, err := g.root.client.GetMetadata(cmd.Context(), args[0], args[1])
+	if err != nil {
+		return err
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(metadata)) {
+		fmt.Printf("%s=%s\n", key, metadata[key])
+	}
+
+	return nil
+}
+
+// parseMetadata parses metadata values of the form key=value.
+func parseMetadata(values []string) (map[string]string, error) {
+	return parseKeyValues("metadata", values)
+}
+
+// parseKeyValues parses values of the form key=value, using the name in errors.
+func parseKeyValues(name string, values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	result := make(map[string]string, len(values))
+	for _, value := range values {
+		key, value, ok := strings.Cut(value, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid %s %q: must be key=value", name, key)
+		}
+		result[key] = value
+	}
+	return result, nil
+}
@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+type mkdir struct {
+	root *workspaceProvider
+}
+
+func (m *mkdir) Customize(c *cobra.Command) {
+	c.Args = cobra.MinimumNArgs(2)
+	c.Use = "mkdir [OPTIONS] ID DIR..."
+	c.Short = "Create directories in a workspace, which are kept when empty"
+}
+
+func (m *mkdir) Run(cmd *cobra.Command, args []string) error {
+	workspaceID := args[0]
+	for _, arg := range args[1:] {
+		if err := m.root.client.Mkdir(cmd.Context(), workspaceID, arg); err != nil {
+			return err
+		}
+
+		fmt.Printf("directory %s created in workspace %s\n", arg, workspaceID)
+	}
+
+	return nil
+}
@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/gptscript-ai/workspace-provider/pkg/client"
+	"github.com/spf13/cobra"
+)
+
+type mv struct {
+	root                        *workspaceProvider
+	LatestRevisionID            string `usage:"Only move the file if this is its latest revision" name:"latest-revision"`
+	DestinationLatestRevisionID string `usage:"Only move the file if this is the latest revision of the destination" name:"destination-latest-revision"`
+	IfNotExists                 bool   `usage:"Only move the file if the destination does not exist" name:"if-not-exists"`
+}
+
+func (m *mv) Customize(cmd *cobra.Command) {
+	cmd.Args = cobra.ExactArgs(3)
+	cmd.Use = "mv [OPTIONS] ID FILE NEW_FILE"
+	cmd.Short = "Move a file, along with its revisions, within a workspace"
+}
+
+func (m *mv) Run(cmd *cobra.Command, args []string) error {
+	if err := m.root.client.Move(cmd.Context(), args[0], args[1], args[2], client.MoveOptions{
+		LatestRevisionID:            m.LatestRevisionID,
+		DestinationLatestRevisionID: m.DestinationLatestRevisionID,
+		IfNotExists:                 m.IfNotExists,
+	}); err != nil {
+		return err
+	}
+
+	fmt.Printf("file %s moved to %s in workspace %s\n", args[1], args[2], args[0])
+	return nil
+}
@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+type resolve struct {
+	root *workspaceProvider
+}
+
+func (r *resolve) Customize(c *cobra.Command) {
+	c.Args = cobra.ExactArgs(1)
+	c.Use = "resolve [OPTIONS] NAME"
+	c.Short = "Print the ID of the workspace with a name"
+}
+
+func (r *resolve) Run(cmd *cobra.Command, args []string) error {
+	id, err := r.root.client.Resolve(cmd.Context(), args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(id)
+	return nil
+}
+
+type rename struct {
+	root *workspaceProvider
+}
+
+func (r *rename) Customize(c *cobra.Command) {
+	c.Args = cobra.RangeArgs(1, 2)
+	c.Use = "rename [OPTIONS] ID [NAME]"
+	c.Short = "Change the name of a workspace, removing it if no name is given"
+}
+
+func (r *rename) Run(cmd *cobra.Command, args []string) error {
+	var name string
+	if len(args) > 1 {
+		name = args[1]
+	}
+
+	return r.root.client.Rename(cmd.Context(), args[0], name)
+}
+
+type names struct {
+	root *workspaceProvider
+}
+
+func (n *names) Customize(c *cobra.Command) {
+	c.Args = cobra.NoArgs
+	c.Use = "names [OPTIONS]"
+	c.Short = "List the names of workspaces along with their IDs"
+}
+
+func (n *names) Run(cmd *cobra.Command, _ []string) error {
+	names, err := n.root.client.Names(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		fmt.Printf("%s\t%s\n", name.Name, name.ID)
+	}
+	return nil
+}
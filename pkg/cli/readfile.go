@@ -11,8 +11,11 @@ import (
 type readFile struct {
 	root *workspaceProvider
 
-	Base64EncodeOutput   bool `usage:"Encode output as base64" env:"READ_FILE_BASE64_ENCODE_OUTPUT"`
-	WithLatestRevisionID bool `usage:"Include the latest revision" env:"READ_FILE_WITH_LATEST_REVISION_ID"`
+	Base64EncodeOutput   bool  `usage:"Encode output as base64" env:"READ_FILE_BASE64_ENCODE_OUTPUT"`
+	WithLatestRevisionID bool  `usage:"Include the latest revision" env:"READ_FILE_WITH_LATEST_REVISION_ID"`
+	Offset               int64 `usage:"The byte offset to start reading from"`
+	Length               int64 `usage:"The maximum number of bytes to read, zero reads to the end of the file"`
+	VerifyChecksum       bool  `usage:"Fail if the contents don't match the checksum stored when the file was written"`
 }
 
 func (r *readFile) Customize(c *cobra.Command) {
@@ -24,6 +27,9 @@ func (r *readFile) Customize(c *cobra.Command) {
 func (r *readFile) Run(cmd *cobra.Command, args []string) error {
 	file, err := r.root.client.OpenFile(cmd.Context(), args[0], args[1], client.OpenOptions{
 		WithLatestRevisionID: r.WithLatestRevisionID,
+		Offset:               r.Offset,
+		Length:               r.Length,
+		VerifyChecksum:       r.VerifyChecksum,
 	})
 	if err != nil {
 		return err
@@ -0,0 +1,32 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/gptscript-ai/workspace-provider/pkg/client"
+	"github.com/spf13/cobra"
+)
+
+type reap struct {
+	root *workspaceProvider
+
+	DryRun bool `usage:"Print the workspaces that would be removed without removing them"`
+}
+
+func (r *reap) Customize(c *cobra.Command) {
+	c.Args = cobra.NoArgs
+	c.Use = "reap [OPTIONS]"
+	c.Short = "Remove the expired workspaces of the provider along with their revisions"
+}
+
+func (r *reap) Run(cmd *cobra.Command, _ []string) error {
+	removed, err := r.root.client.ReapExpired(cmd.Context(), r.root.Provider, client.ReapOptions{DryRun: r.DryRun})
+	for _, id := range removed {
+		if r.DryRun {
+			fmt.Printf("would remove expired workspace %s\n", id)
+		} else {
+			fmt.Printf("removed expired workspace %s\n", id)
+		}
+	}
+	return err
+}
@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/gptscript-ai/workspace-provider/pkg/client"
+	"github.com/spf13/cobra"
+)
+
+type removeMatching struct {
+	root *workspaceProvider
+
+	Exclude []string `usage:"Keep files that match this glob pattern"`
+	DryRun  bool     `usage:"Print the files that would be removed without removing them"`
+}
+
+func (r *removeMatching) Customize(c *cobra.Command) {
+	c.Args = cobra.MinimumNArgs(2)
+	c.Use = "rm-matching [OPTIONS] ID PATTERN..."
+	c.Short = "Remove all files matching glob patterns, such as 'tmp/**/*.log'"
+}
+
+func (r *removeMatching) Run(cmd *cobra.Command, args []string) error {
+	files, err := r.root.client.RemoveMatching(cmd.Context(), args[0], client.RemoveMatchingOptions{
+		Include: args[1:],
+		Exclude: r.Exclude,
+		DryRun:  r.DryRun,
+	})
+	if err != nil {
+		return err
+	}
+
+	verb := "deleted"
+	if r.DryRun {
+		verb = "would be deleted"
+	}
+	for _, file := range files {
+		fmt.Printf("%s %s from workspace %s\n", file, verb, args[0])
+	}
+
+	return nil
+}
@@ -0,0 +1,33 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/gptscript-ai/workspace-provider/pkg/client"
+	"github.com/spf13/cobra"
+)
+
+type rmDir struct {
+	root *workspaceProvider
+
+	Recursive bool `usage:"Remove the directories along with everything in them" short:"r" env:"RM_DIR_RECURSIVE"`
+}
+
+func (r *rmDir) Customize(c *cobra.Command) {
+	c.Args = cobra.MinimumNArgs(2)
+	c.Use = "rm-dir [OPTIONS] ID DIR..."
+	c.Short = "Remove directories from a workspace"
+}
+
+func (r *rmDir) Run(cmd *cobra.Command, args []string) error {
+	workspaceID := args[0]
+	for _, arg := range args[1:] {
+		if err := r.root.client.RemoveDir(cmd.Context(), workspaceID, arg, client.RemoveDirOptions{Recursive: r.Recursive}); err != nil {
+			return err
+		}
+
+		fmt.Printf("directory %s removed from workspace %s\n", arg, workspaceID)
+	}
+
+	return nil
+}
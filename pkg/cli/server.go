@@ -1,14 +1,19 @@
 package cli
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/spf13/cobra"
 
 	wserver "github.com/gptscript-ai/workspace-provider/pkg/server"
 )
 
 type server struct {
-	root *workspaceProvider
-	Port int `usage:"Port to run the server on" default:"8888" env:"PORT"`
+	root          *workspaceProvider
+	Port          int    `usage:"Port to run the server on" default:"8888" env:"PORT"`
+	SweepInterval string `usage:"How often to remove expired files, such as '10m', or never if not set" env:"SWEEP_INTERVAL"`
+	ReapInterval  string `usage:"How often to remove expired workspaces, such as '1h', or never if not set" env:"REAP_INTERVAL"`
 }
 
 func (s *server) Customize(cmd *cobra.Command) {
@@ -17,5 +22,21 @@ func (s *server) Customize(cmd *cobra.Command) {
 }
 
 func (s *server) Run(cmd *cobra.Command, _ []string) error {
-	return wserver.Run(cmd.Context(), s.root.client, s.Port)
+	var opts wserver.Options
+	if s.SweepInterval != "" {
+		interval, err := time.ParseDuration(s.SweepInterval)
+		if err != nil {
+			return fmt.Errorf("invalid sweep interval: %w", err)
+		}
+		opts.SweepInterval = interval
+	}
+	if s.ReapInterval != "" {
+		interval, err := time.ParseDuration(s.ReapInterval)
+		if err != nil {
+			return fmt.Errorf("invalid reap interval: %w", err)
+		}
+		opts.ReapInterval = interval
+	}
+
+	return wserver.Run(cmd.Context(), s.root.client, s.Port, opts)
 }
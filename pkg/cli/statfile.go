@@ -34,6 +34,12 @@ func (r *statFile) Run(cmd *cobra.Command, args []string) error {
 	_, _ = writer.Write([]byte("size: " + strconv.FormatInt(info.Size, 10) + "\n"))
 	_, _ = writer.Write([]byte("mod time: " + info.ModTime.String() + "\n"))
 	_, _ = writer.Write([]byte("mime type: " + info.MimeType + "\n"))
+	if info.IsDir {
+		_, _ = writer.Write([]byte("directory: true\n"))
+	}
+	if !info.ExpiresAt.IsZero() {
+		_, _ = writer.Write([]byte("expires at: " + info.ExpiresAt.String() + "\n"))
+	}
 	if r.WithLatestRevisionID {
 		rev, err := info.GetRevisionID()
 		if err != nil {
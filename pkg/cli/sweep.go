@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+type sweep struct {
+	root *workspaceProvider
+}
+
+func (s *sweep) Customize(c *cobra.Command) {
+	c.Use = "sweep [OPTIONS] [ID...]"
+	c.Short = "Remove expired files, from every workspace of the provider if no workspaces are given"
+}
+
+func (s *sweep) Run(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		removed, err := s.root.client.SweepAllExpired(cmd.Context(), s.root.Provider)
+		for id, files := range removed {
+			fmt.Printf("removed %d expired files from workspace %s\n", len(files), id)
+		}
+		return err
+	}
+
+	for _, arg := range args {
+		files, err := s.root.client.SweepExpired(cmd.Context(), arg)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("removed %d expired files from workspace %s\n", len(files), arg)
+	}
+
+	return nil
+}
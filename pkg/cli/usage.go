@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/gptscript-ai/workspace-provider/pkg/client"
+	"github.com/spf13/cobra"
+)
+
+type usage struct {
+	root *workspaceProvider
+
+	Recompute bool `usage:"List the workspace to compute its usage instead of using the recorded counters"`
+}
+
+func (u *usage) Customize(c *cobra.Command) {
+	c.Args = cobra.ExactArgs(1)
+	c.Use = "usage [OPTIONS] ID"
+	c.Short = "Print the storage used by a workspace and its quota"
+}
+
+func (u *usage) Run(cmd *cobra.Command, args []string) error {
+	usage, err := u.root.client.Usage(cmd.Context(), args[0], client.UsageOptions{Recompute: u.Recompute})
+	if err != nil {
+		return err
+	}
+
+	quota, err := u.root.client.Quota(cmd.Context(), args[0])
+	if err != nil {
+		return err
+	}
+
+	writer := cmd.OutOrStdout()
+
+	_, _ = writer.Write([]byte(fmt.Sprintf("files: %d\n", usage.Files)))
+	_, _ = writer.Write([]byte(fmt.Sprintf("bytes: %d\n", usage.Bytes)))
+	_, _ = writer.Write([]byte(fmt.Sprintf("revision files: %d\n", usage.RevisionFiles)))
+	_, _ = writer.Write([]byte(fmt.Sprintf("revision bytes: %d\n", usage.RevisionBytes)))
+	if quota.MaxBytes != 0 {
+		_, _ = writer.Write([]byte(fmt.Sprintf("max bytes: %d\n", quota.MaxBytes)))
+	}
+	if quota.MaxFiles != 0 {
+		_, _ = writer.Write([]byte(fmt.Sprintf("max files: %d\n", quota.MaxFiles)))
+	}
+	if quota.MaxFileSize != 0 {
+		_, _ = writer.Write([]byte(fmt.Sprintf("max file size: %d\n", quota.MaxFileSize)))
+	}
+
+	return nil
+}
+
+type setQuota struct {
+	root *workspaceProvider
+
+	MaxBytes    int64 `usage:"The maximum bytes stored by the workspace, including revisions"`
+	MaxFiles    int64 `usage:"The maximum number of files in the workspace"`
+	MaxFileSize int64 `usage:"The maximum size of each file in the workspace"`
+}
+
+func (s *setQuota) Customize(c *cobra.Command) {
+	c.Args = cobra.ExactArgs(1)
+	c.Use = "set-quota [OPTIONS] ID"
+	c.Short = "Replace the quota of a workspace, removing it if no limits are given"
+}
+
+func (s *setQuota) Run(cmd *cobra.Command, args []string) error {
+	return s.root.client.SetQuota(cmd.Context(), args[0], client.Quota{MaxBytes: s.MaxBytes, MaxFiles: s.MaxFiles, MaxFileSize: s.MaxFileSize})
+}
@@ -10,13 +10,20 @@ import (
 )
 
 type workspaceProvider struct {
-	Provider              string `usage:"The workspace provider to use, valid options are 'directory' and 's3'" default:"directory" env:"WORKSPACE_PROVIDER_PROVIDER,PROVIDER"`
-	DataHome              string `usage:"The data home directory or bucket name" env:"WORKSPACE_PROVIDER_DATA_HOME"`
-	S3Bucket              string `usage:"The S3 bucket name" name:"s3-bucket" env:"WORKSPACE_PROVIDER_S3_BUCKET"`
-	S3BaseEndpoint        string `usage:"The S3 base endpoint to use with S3 compatible providers" name:"s3-base-endpoint" env:"WORKSPACE_PROVIDER_S3_BASE_ENDPOINT"`
-	S3UsePathStyle        bool   `usage:"Use path style addressing for S3 compatible providers" name:"s3-use-path-style" env:"WORKSPACE_PROVIDER_S3_USE_PATH_STYLE"`
-	AzureContainer        string `usage:"The Azure container name" name:"azure-container" env:"WORKSPACE_PROVIDER_AZURE_CONTAINER"`
-	AzureConnectionString string `usage:"The Azure connection string" name:"azure-connection-string" env:"WORKSPACE_PROVIDER_AZURE_CONNECTION_STRING"`
+	Provider                 string `usage:"The workspace provider to use, valid options are 'directory' and 's3'" default:"directory" env:"WORKSPACE_PROVIDER_PROVIDER,PROVIDER"`
+	DataHome                 string `usage:"The data home directory or bucket name" env:"WORKSPACE_PROVIDER_DATA_HOME"`
+	S3Bucket                 string `usage:"The S3 bucket name" name:"s3-bucket" env:"WORKSPACE_PROVIDER_S3_BUCKET"`
+	S3BaseEndpoint           string `usage:"The S3 base endpoint to use with S3 compatible providers" name:"s3-base-endpoint" env:"WORKSPACE_PROVIDER_S3_BASE_ENDPOINT"`
+	S3UsePathStyle           bool   `usage:"Use path style addressing for S3 compatible providers" name:"s3-use-path-style" env:"WORKSPACE_PROVIDER_S3_USE_PATH_STYLE"`
+	AzureContainer           string `usage:"The Azure container name" name:"azure-container" env:"WORKSPACE_PROVIDER_AZURE_CONTAINER"`
+	AzureConnectionString    string `usage:"The Azure connection string" name:"azure-connection-string" env:"WORKSPACE_PROVIDER_AZURE_CONNECTION_STRING"`
+	RevisionEncoding         string `usage:"How revisions are stored, valid options are 'full' and 'delta'" default:"full" env:"WORKSPACE_PROVIDER_REVISION_ENCODING"`
+	RevisionKeyframeInterval int    `usage:"How often a revision is stored as a full copy when using delta encoding" default:"10" env:"WORKSPACE_PROVIDER_REVISION_KEYFRAME_INTERVAL"`
+	RevisionsStore           string `usage:"Where to store revisions, e.g. s3://bucket/prefix (defaults to alongside the workspaces)" env:"WORKSPACE_PROVIDER_REVISIONS_STORE"`
+	QuotaMaxBytes            int64  `usage:"The maximum bytes stored by each workspace of the provider, including revisions (0 is unlimited)" env:"WORKSPACE_PROVIDER_QUOTA_MAX_BYTES"`
+	QuotaMaxFiles            int64  `usage:"The maximum number of files in each workspace of the provider (0 is unlimited)" env:"WORKSPACE_PROVIDER_QUOTA_MAX_FILES"`
+	QuotaMaxFileSize         int64  `usage:"The maximum size of each file in the workspaces of the provider (0 is unlimited)" env:"WORKSPACE_PROVIDER_QUOTA_MAX_FILE_SIZE"`
+	Quotas                   bool   `usage:"Allow quotas to be set on workspaces of the provider without limits of its own (implied by its limits)" env:"WORKSPACE_PROVIDER_QUOTAS"`
 
 	client *client.Client
 }
@@ -26,15 +33,37 @@ func New() *cobra.Command {
 	c := cmd.Command(w,
 		&create{root: w},
 		&rm{root: w},
+		&list{root: w},
+		&info{root: w},
+		&resolve{root: w},
+		&rename{root: w},
+		&names{root: w},
+		&setLabels{root: w},
+		&usage{root: w},
+		&setQuota{root: w},
 		&ls{root: w},
 		&removeAllWithPrefix{root: w},
+		&removeMatching{root: w},
+		&grep{root: w},
+		&backfillContentTypes{root: w},
+		&sweep{root: w},
+		&reap{root: w},
 		&cpFile{root: w},
 		&writeFile{root: w},
+		&appendFile{root: w},
+		&batch{root: w},
 		&rmFile{root: w},
+		&mkdir{root: w},
+		&rmDir{root: w},
+		&mv{root: w},
 		&readFile{root: w},
 		&server{root: w},
 		&validateEnv{root: w},
 		&statFile{root: w},
+		&setMetadata{root: w},
+		&getMetadata{root: w},
+		&listRevisions{root: w},
+		&blame{root: w},
 	)
 
 	c.CompletionOptions.HiddenDefaultCmd = true
@@ -69,14 +98,23 @@ func (w *workspaceProvider) PersistentPre(cmd *cobra.Command, _ []string) error
 		return fmt.Errorf("invalid workspace provider: %s", w.Provider)
 	}
 
+	var quotas map[string]client.Quota
+	if quota := (client.Quota{MaxBytes: w.QuotaMaxBytes, MaxFiles: w.QuotaMaxFiles, MaxFileSize: w.QuotaMaxFileSize}); w.Quotas || quota != (client.Quota{}) {
+		quotas = map[string]client.Quota{w.Provider: quota}
+	}
+
 	var err error
 	w.client, err = client.New(cmd.Context(), client.Options{
-		DirectoryDataHome:     w.DataHome,
-		S3BucketName:          w.S3Bucket,
-		S3BaseEndpoint:        w.S3BaseEndpoint,
-		S3UsePathStyle:        w.S3UsePathStyle,
-		AzureContainerName:    w.AzureContainer,
-		AzureConnectionString: w.AzureConnectionString,
+		DirectoryDataHome:        w.DataHome,
+		S3BucketName:             w.S3Bucket,
+		S3BaseEndpoint:           w.S3BaseEndpoint,
+		S3UsePathStyle:           w.S3UsePathStyle,
+		AzureContainerName:       w.AzureContainer,
+		AzureConnectionString:    w.AzureConnectionString,
+		RevisionEncoding:         w.RevisionEncoding,
+		RevisionKeyframeInterval: w.RevisionKeyframeInterval,
+		RevisionsStore:           w.RevisionsStore,
+		Quotas:                   quotas,
 	})
 
 	return err
@@ -2,9 +2,11 @@ package cli
 
 import (
 	"encoding/base64"
+	"fmt"
 	"io"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/gptscript-ai/go-gptscript"
 	"github.com/gptscript-ai/workspace-provider/pkg/client"
@@ -14,9 +16,14 @@ import (
 type writeFile struct {
 	root *workspaceProvider
 
-	Base64EncodedInput    bool   `usage:"Encode input as base64" env:"WRITE_FILE_BASE64_ENCODED_INPUT"`
-	WithoutCreateRevision bool   `usage:"Do not create a new revision" env:"WRITE_FILE_WITHOUT_CREATE_REVISION"`
-	LatestRevisionID      string `usage:"Only write if this is the latest revision" env:"WRITE_FILE_LATEST_REVISION_ID"`
+	Base64EncodedInput    bool     `usage:"Encode input as base64" env:"WRITE_FILE_BASE64_ENCODED_INPUT"`
+	WithoutCreateRevision bool     `usage:"Do not create a new revision" env:"WRITE_FILE_WITHOUT_CREATE_REVISION"`
+	LatestRevisionID      string   `usage:"Only write if this is the latest revision" env:"WRITE_FILE_LATEST_REVISION_ID"`
+	Checksum              string   `usage:"Only write if the contents have this hex encoded SHA-256 checksum" env:"WRITE_FILE_CHECKSUM"`
+	ContentType           string   `usage:"The mime type of the contents, detected from the contents if not set" env:"WRITE_FILE_CONTENT_TYPE"`
+	Metadata              []string `usage:"Store this key=value metadata with the file"`
+	ExpiresAt             string   `usage:"Expire the file at this RFC 3339 time" env:"WRITE_FILE_EXPIRES_AT"`
+	TTL                   string   `usage:"Expire the file after this long, such as '1h'" name:"ttl" env:"WRITE_FILE_TTL"`
 }
 
 func (c *writeFile) Customize(cmd *cobra.Command) {
@@ -33,12 +40,32 @@ func (c *writeFile) Run(cmd *cobra.Command, args []string) error {
 		source = strings.NewReader(gptscript.GetEnv("FILE_CONTENTS", args[2]))
 	}
 
-	if c.Base64EncodedInput {
-		source = base64.NewDecoder(base64.StdEncoding, source)
+	metadata, err := parseMetadata(c.Metadata)
+	if err != nil {
+		return err
 	}
 
-	return c.root.client.WriteFile(cmd.Context(), args[0], args[1], source, client.WriteOptions{
+	opts := client.WriteOptions{
 		LatestRevisionID: c.LatestRevisionID,
 		CreateRevision:   &[]bool{!c.WithoutCreateRevision}[0],
-	})
+		Checksum:         c.Checksum,
+		ContentType:      c.ContentType,
+		Metadata:         metadata,
+	}
+	if c.ExpiresAt != "" {
+		if opts.ExpiresAt, err = time.Parse(time.RFC3339Nano, c.ExpiresAt); err != nil {
+			return fmt.Errorf("invalid expiry time: %w", err)
+		}
+	}
+	if c.TTL != "" {
+		if opts.TTL, err = time.ParseDuration(c.TTL); err != nil {
+			return fmt.Errorf("invalid TTL: %w", err)
+		}
+	}
+
+	if c.Base64EncodedInput {
+		source = base64.NewDecoder(base64.StdEncoding, source)
+	}
+
+	return c.root.client.WriteFile(cmd.Context(), args[0], args[1], source, opts)
 }
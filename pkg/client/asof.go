@@ -0,0 +1,196 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// resolveAsOf returns the ID of the revision that holds the content of the file as it was at the given time, along with
+// the time that content was written, if known. An empty revision ID means that the live file was current at that time.
+// A NotFoundError is returned if the file did not exist yet.
+//
+// Writes record their time in the revision info of the file. For files written before that was recorded, the time at
+// which a revision was stored is used instead, and the earliest content is assumed to have always existed.
+func resolveAsOf(ctx context.Context, wc workspaceClient, workspaceID, fileName string, asOf time.Time) (string, time.Time, error) {
+	rc := wc.RevisionClient()
+	if rc == nil {
+		return "", time.Time{}, asOfLiveFile(ctx, wc, workspaceID, fileName, asOf)
+	}
+
+	info, err := getRevisionInfo(ctx, rc, fileName)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if info.CurrentID == -1 {
+		// No writes were recorded for this file, so the live file is the only content available.
+		return "", time.Time{}, asOfLiveFile(ctx, wc, workspaceID, fileName, asOf)
+	}
+
+	var storedAt map[int64]time.Time
+	for id := info.CurrentID; id >= 0; id-- {
+		writtenAt, ok := info.WrittenAt[id]
+		if !ok {
+			if storedAt == nil {
+				revisions, err := wc.ListRevisions(ctx, fileName)
+				if err != nil {
+					return "", time.Time{}, err
+				}
+
+				storedAt = make(map[int64]time.Time, len(revisions))
+				for _, rev := range revisions {
+					revID, _ := parseRevisionID(rev.RevisionID)
+					storedAt[revID] = rev.ModTime
+				}
+			}
+
+			// Revision N is stored by the write that makes N the current ID.
+			if writtenAt, ok = storedAt[id]; !ok && id != 0 {
+				continue
+			}
+		}
+
+		if !writtenAt.After(asOf) {
+			if id == info.CurrentID {
+				return "", writtenAt, nil
+			}
+
+			// The content that was written when the current ID became N is stored as revision N+1.
+			return strconv.FormatInt(id+1, 10), writtenAt, nil
+		}
+	}
+
+	return "", time.Time{}, newNotFoundError(workspaceID, fileName)
+}
+
+func asOfLiveFile(ctx context.Context, wc workspaceClient, workspaceID, fileName string, asOf time.Time) error {
+	info, err := wc.StatFile(ctx, fileName, StatOptions{})
+	if err != nil {
+		return err
+	}
+	if info.ModTime.After(asOf) {
+		return newNotFoundError(workspaceID, fileName)
+	}
+	return nil
+}
+
+func openFileAsOf(ctx context.Context, wc workspaceClient, workspaceID, fileName string, opt OpenOptions) (*File, error) {
+	revisionID, _, err := resolveAsOf(ctx, wc, workspaceID, fileName, opt.AsOf)
+	if err != nil {
+		return nil, err
+	}
+
+	if revisionID == "" {
+		opt.AsOf = time.Time{}
+		return wc.OpenFile(ctx, fileName, opt)
+	}
+
+	f, err := wc.GetRevision(ctx, fileName, revisionID)
+	if err != nil || !opt.hasRange() {
+		return f, err
+	}
+
+	// Revisions may be delta encoded, so the range is read from the reconstructed content.
+	if f.ReadCloser, err = limitRange(f.ReadCloser, opt.Offset, opt.Length); err != nil {
+		return nil, err
+	}
+	f.Size = -1
+
+	return f, nil
+}
+
+func statFileAsOf(ctx context.Context, wc workspaceClient, workspaceID, fileName string, opt StatOptions) (FileInfo, error) {
+	revisionID, writtenAt, err := resolveAsOf(ctx, wc, workspaceID, fileName, opt.AsOf)
+	if err != nil {
+		return FileInfo{}, err
+	}
+
+	if revisionID == "" {
+		opt.AsOf = time.Time{}
+		return wc.StatFile(ctx, fileName, opt)
+	}
+
+	revisions, err := wc.ListRevisions(ctx, fileName)
+	if err != nil {
+		return FileInfo{}, err
+	}
+
+	for _, rev := range revisions {
+		if rev.RevisionID != revisionID {
+			continue
+		}
+
+		f, err := wc.GetRevision(ctx, fileName, revisionID)
+		if err != nil {
+			return FileInfo{}, err
+		}
+		defer f.Close()
+
+		mt, err := mimetype.DetectReader(f)
+		if err != nil {
+			return FileInfo{}, err
+		}
+
+		info := rev.FileInfo
+		info.MimeType = strings.Split(mt.String(), ";")[0]
+		if !writtenAt.IsZero() {
+			info.ModTime = writtenAt
+		}
+		if opt.WithLatestRevisionID {
+			info.RevisionID = revisionID
+		} else {
+			info.RevisionID = ""
+		}
+
+		return info, nil
+	}
+
+	// The revision that was current at that time has been deleted.
+	return FileInfo{}, newNotFoundError(workspaceID, fileName)
+}
+
+func lsAsOf(ctx context.Context, wc workspaceClient, workspaceID string, files []string, asOf time.Time) ([]string, error) {
+	existing := make([]string, 0, len(files))
+	for _, file := range files {
+		if _, _, err := resolveAsOf(ctx, wc, workspaceID, file, asOf); err != nil {
+			if nfe := (*NotFoundError)(nil); errors.As(err, &nfe) {
+				continue
+			}
+			return nil, err
+		}
+
+		existing = append(existing, file)
+	}
+
+	return existing, nil
+}
+
+// lsWithInfoAsOf replaces the information about each file with the information as it was at the given time, dropping
+// the files that did not exist then.
+func lsWithInfoAsOf(ctx context.Context, wc workspaceClient, workspaceID string, files []FileInfo, asOf time.Time) ([]FileInfo, error) {
+	existing := make([]FileInfo, 0, len(files))
+	for _, file := range files {
+		if file.IsDir {
+			// Directories are listed as they are now.
+			existing = append(existing, file)
+			continue
+		}
+
+		info, err := statFileAsOf(ctx, wc, workspaceID, file.Name, StatOptions{AsOf: asOf})
+		if err != nil {
+			if nfe := (*NotFoundError)(nil); errors.As(err, &nfe) {
+				continue
+			}
+			return nil, err
+		}
+
+		info.Name = file.Name
+		existing = append(existing, info)
+	}
+
+	return existing, nil
+}
@@ -3,22 +3,29 @@ package client
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"path/filepath"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/appendblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
 	"github.com/gabriel-vasile/mimetype"
 	"github.com/google/uuid"
 )
 
-func newAzure(containerName, connectionString string) (workspaceFactory, error) {
+func newAzure(containerName, connectionString string, store revisionStore) (workspaceFactory, error) {
 	client, err := azblob.NewClientFromConnectionString(connectionString, nil)
 	if err != nil {
 		return nil, err
@@ -27,18 +34,31 @@ func newAzure(containerName, connectionString string) (workspaceFactory, error)
 	return &azureProvider{
 		containerName: containerName,
 		client:        client,
-		revisionsProvider: &azureProvider{
-			containerName: containerName,
-			dir:           revisionsDir,
-			client:        client,
-		},
+		revisionStore: store,
 	}, nil
 }
 
+// appendBlockSize is the maximum size of a block appended to an append blob.
+const appendBlockSize = 4 * 1024 * 1024
+
 type azureProvider struct {
 	containerName, dir string
 	client             *azblob.Client
-	revisionsProvider  *azureProvider
+	revisionsProvider  workspaceClient
+	revisionStore      revisionStore
+}
+
+// revisionClient returns the client for the revisions of the workspace in dir of the container. Unless a separate
+// revision store is configured, revisions are kept in the revisions directory of the same container.
+func (a *azureProvider) revisionClient(container, dir string) workspaceClient {
+	if a.revisionStore != nil {
+		return a.revisionStore(AzureProvider, container, dir)
+	}
+	return &azureProvider{
+		containerName: container,
+		dir:           fmt.Sprintf("%s/%s", revisionsDir, dir),
+		client:        a.client,
+	}
 }
 
 func (a *azureProvider) validatePath(path string, allowTrailingSlash bool) error {
@@ -86,19 +106,15 @@ func (a *azureProvider) validatePath(path string, allowTrailingSlash bool) error
 
 func (a *azureProvider) New(id string) (workspaceClient, error) {
 	container, dir, _ := strings.Cut(strings.TrimPrefix(id, AzureProvider+"://"), "/")
-	if dir == revisionsDir {
+	if a.revisionStore == nil && dir == revisionsDir {
 		return nil, errors.New("cannot create a workspace client for the revisions directory")
 	}
 
 	return &azureProvider{
-		containerName: container,
-		dir:           dir,
-		client:        a.client,
-		revisionsProvider: &azureProvider{
-			containerName: container,
-			dir:           fmt.Sprintf("%s/%s", revisionsDir, dir),
-			client:        a.client,
-		},
+		containerName:     container,
+		dir:               dir,
+		client:            a.client,
+		revisionsProvider: a.revisionClient(container, dir),
 	}, nil
 }
 
@@ -110,22 +126,81 @@ func (a *azureProvider) Rm(ctx context.Context, id string) error {
 	container, dir, _ := strings.Cut(strings.TrimPrefix(id, AzureProvider+"://"), "/")
 
 	newA := &azureProvider{
-		containerName: container,
-		dir:           dir,
-		client:        a.client,
-		revisionsProvider: &azureProvider{
-			containerName: container,
-			dir:           fmt.Sprintf("%s/%s", revisionsDir, dir),
-			client:        a.client,
-		},
+		containerName:     container,
+		dir:               dir,
+		client:            a.client,
+		revisionsProvider: a.revisionClient(container, dir),
 	}
 
-	// Best effort
-	_ = newA.revisionsProvider.RemoveAllWithPrefix(ctx, "")
+	// The revisions are removed first so that removing the workspace can be retried if they can't be.
+	if err := newA.revisionsProvider.RemoveAllWithPrefix(ctx, ""); err != nil {
+		return fmt.Errorf("failed to remove revisions: %w", err)
+	}
 
 	return newA.RemoveAllWithPrefix(ctx, "")
 }
 
+// List returns the IDs of the workspaces in the container, sorted. Azure can't list from a given blob, so the
+// workspaces and manifests are listed from the start, but only until enough of each after the given workspace have
+// been listed.
+func (a *azureProvider) List(ctx context.Context, after string, limit int) ([]string, error) {
+	_, after, _ = strings.Cut(strings.TrimPrefix(after, AzureProvider+"://"), "/")
+
+	containerClient := a.client.ServiceClient().NewContainerClient(a.containerName)
+	pager := containerClient.NewListBlobsHierarchyPager("/", nil)
+
+	var dirs []string
+	for pager.More() && (limit == 0 || len(dirs) < limit) {
+		resp, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, blobPrefix := range resp.Segment.BlobPrefixes {
+			if dir := strings.TrimSuffix(*blobPrefix.Name, "/"); isManifestName(dir) && dir > after {
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+
+	prefix := manifestsDir + "/"
+	pager = containerClient.NewListBlobsHierarchyPager("/", &container.ListBlobsHierarchyOptions{
+		Prefix: &prefix,
+	})
+
+	var manifests []string
+	for pager.More() && (limit == 0 || len(manifests) < limit) {
+		resp, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, blob := range resp.Segment.BlobItems {
+			if dir, ok := strings.CutSuffix(strings.TrimPrefix(*blob.Name, manifestsDir+"/"), ".json"); ok && dir > after {
+				manifests = append(manifests, dir)
+			}
+		}
+	}
+
+	return workspaceIDs(dirs, manifests, after, limit, func(dir string) string {
+		return fmt.Sprintf("%s://%s/%s", AzureProvider, a.containerName, dir)
+	}), nil
+}
+
+// ManifestClient returns the client for the manifests directory of the workspace's container.
+func (a *azureProvider) ManifestClient(id string) (workspaceClient, string, error) {
+	container, dir, _ := strings.Cut(strings.TrimPrefix(id, AzureProvider+"://"), "/")
+	if first, _, _ := strings.Cut(dir, "/"); container == "" || !isManifestName(first) {
+		return nil, "", newInvalidArgumentError("invalid workspace id: %s", id)
+	}
+	return &azureProvider{containerName: container, dir: manifestsDir, client: a.client}, manifestKey(dir) + ".json", nil
+}
+
+// NamesClient returns the client for the names directory next to the manifests in the container.
+func (a *azureProvider) NamesClient() workspaceClient {
+	return &azureProvider{containerName: a.containerName, dir: manifestsDir + "/" + namesDir, client: a.client}
+}
+
 func (a *azureProvider) Ls(ctx context.Context, prefix string) ([]string, error) {
 	prefix = strings.TrimPrefix(prefix, "/")
 	if err := a.validatePath(prefix, true); err != nil {
@@ -150,6 +225,9 @@ func (a *azureProvider) Ls(ctx context.Context, prefix string) ([]string, error)
 		}
 
 		for _, blob := range resp.Segment.BlobItems {
+			if isDirMarker(*blob.Name) {
+				continue
+			}
 			files = append(files, strings.TrimPrefix(*blob.Name, a.dir+"/"))
 		}
 	}
@@ -181,10 +259,7 @@ func (a *azureProvider) DeleteFile(ctx context.Context, filePath string) error {
 		return err
 	}
 
-	for i := info.CurrentID; i > 0; i-- {
-		// Best effort
-		_ = deleteRevision(ctx, a.revisionsProvider, filePath, fmt.Sprintf("%d", i))
-	}
+	deleteRevisions(ctx, a.revisionsProvider, filePath, info)
 
 	// Best effort
 	_ = deleteRevisionInfo(ctx, a.revisionsProvider, filePath)
@@ -200,14 +275,48 @@ func (a *azureProvider) OpenFile(ctx context.Context, filePath string, opt OpenO
 	}
 	blobClient := a.client.ServiceClient().NewContainerClient(a.containerName).NewBlockBlobClient(fmt.Sprintf("%s/%s", a.dir, filePath))
 
-	resp, err := blobClient.DownloadStream(ctx, nil)
+	var downloadOpts *azblob.DownloadStreamOptions
+	if opt.hasRange() {
+		downloadOpts = &azblob.DownloadStreamOptions{}
+		downloadOpts.Range.Offset = opt.Offset
+		downloadOpts.Range.Count = opt.Length
+	}
+
+	var (
+		body      io.ReadCloser
+		size      int64
+		checksum  string
+		metadata  map[string]string
+		expiresAt time.Time
+	)
+	resp, err := blobClient.DownloadStream(ctx, downloadOpts)
 	if err != nil {
 		var storageErr *azcore.ResponseError
-		if errors.As(err, &storageErr) && storageErr.StatusCode == 404 {
+		if !errors.As(err, &storageErr) {
+			return nil, err
+		}
+
+		switch storageErr.StatusCode {
+		case 404:
 			// We need to use the original file path here, because that is how the gptscript sdk will determine whether this is a not found error.
 			return nil, newNotFoundError(fmt.Sprintf("%s://%s/%s", AzureProvider, a.containerName, a.dir), originalFilePath)
+		case 416:
+			// The range starts beyond the end of the blob.
+			props, err := blobClient.GetProperties(ctx, nil)
+			if err != nil {
+				return nil, err
+			}
+			body, size = io.NopCloser(strings.NewReader("")), *props.ContentLength
+			expiresAt = metadataExpiresAt(props.Metadata)
+		default:
+			return nil, err
+		}
+	} else {
+		body, checksum, metadata = resp.Body, metadataChecksum(resp.Metadata), userMetadata(resp.Metadata)
+		expiresAt = metadataExpiresAt(resp.Metadata)
+		if opt.hasRange() {
+			size = contentRangeSize(resp.ContentRange)
 		}
-		return nil, err
 	}
 
 	var revision string
@@ -220,8 +329,12 @@ func (a *azureProvider) OpenFile(ctx context.Context, filePath string, opt OpenO
 	}
 
 	return &File{
-		ReadCloser: resp.Body,
+		ReadCloser: body,
 		RevisionID: revision,
+		Size:       size,
+		Checksum:   checksum,
+		Metadata:   metadata,
+		ExpiresAt:  expiresAt,
 	}, nil
 }
 
@@ -230,47 +343,246 @@ func (a *azureProvider) WriteFile(ctx context.Context, fileName string, reader i
 	if err := a.validatePath(fileName, false); err != nil {
 		return err
 	}
-	if a.revisionsProvider != nil && (opt.CreateRevision == nil || *opt.CreateRevision) {
-		info, err := getRevisionInfo(ctx, a.revisionsProvider, fileName)
-		if err != nil {
-			if nfe := (*NotFoundError)(nil); !errors.As(err, &nfe) {
-				return err
-			}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(data)
+	checksum := hex.EncodeToString(sum[:])
+	if err = verifyChecksum(fmt.Sprintf("%s://%s/%s", AzureProvider, a.containerName, a.dir), fileName, opt.Checksum, checksum); err != nil {
+		return err
+	}
+
+	if a.revisionsProvider != nil {
+		if err := recordWrite(ctx, a.revisionsProvider, a, AzureProvider+"://"+a.containerName, fileName, opt); err != nil {
+			return err
 		}
+	}
 
-		if opt.LatestRevisionID != "" {
-			requiredLatestRevision, err := strconv.ParseInt(opt.LatestRevisionID, 10, 64)
-			if err != nil {
-				return fmt.Errorf("failed to parse latest revision for write: %w", err)
-			}
+	contentType := opt.ContentType
+	if contentType == "" {
+		contentType = detectContentType(data[:min(len(data), sniffLength)])
+	}
 
-			if requiredLatestRevision != info.CurrentID {
-				return newConflictError(AzureProvider+"://"+a.containerName, fileName, opt.LatestRevisionID, fmt.Sprintf("%d", info.CurrentID))
-			}
+	uploadOpts := &azblob.UploadStreamOptions{
+		HTTPHeaders: &blob.HTTPHeaders{BlobContentType: &contentType},
+		Metadata:    azureMetadata(objectMetadata(opt.Metadata, checksum, opt.ExpiresAt)),
+	}
+	if opt.exclusive {
+		etag := azcore.ETagAny
+		uploadOpts.AccessConditions = &blob.AccessConditions{ModifiedAccessConditions: &blob.ModifiedAccessConditions{IfNoneMatch: &etag}}
+	}
+	blobClient := a.client.ServiceClient().NewContainerClient(a.containerName).NewBlockBlobClient(fmt.Sprintf("%s/%s", a.dir, fileName))
+	_, err = blobClient.UploadStream(ctx, bytes.NewReader(data), uploadOpts)
+	if opt.exclusive && bloberror.HasCode(err, bloberror.BlobAlreadyExists, bloberror.ConditionNotMet) {
+		return &FileExistsError{id: AzureProvider + "://" + a.containerName, name: fileName}
+	}
+	if bloberror.HasCode(err, bloberror.InvalidBlobType) {
+		// The file was appended to, so it is an append blob. Replace it with a block blob.
+		if _, err = blobClient.Delete(ctx, nil); err != nil {
+			return err
 		}
+		_, err = blobClient.UploadStream(ctx, bytes.NewReader(data), uploadOpts)
+	}
+	return err
+}
 
-		info.CurrentID++
-		if err = writeRevision(ctx, a.revisionsProvider, a, fileName, info); err != nil {
-			if nfe := (*NotFoundError)(nil); !errors.As(err, &nfe) {
-				return fmt.Errorf("failed to write revision: %w", err)
-			}
+// AppendFile appends blocks to append blobs, creating an append blob if the file does not exist. Files that were
+// written as block blobs are converted to append blobs by the first append, so only that append rewrites the content.
+func (a *azureProvider) AppendFile(ctx context.Context, fileName string, reader io.Reader, opt WriteOptions) error {
+	fileName = strings.TrimPrefix(fileName, "/")
+	if err := a.validatePath(fileName, false); err != nil {
+		return err
+	}
+	if a.revisionsProvider != nil {
+		if err := recordWrite(ctx, a.revisionsProvider, a, AzureProvider+"://"+a.containerName, fileName, opt); err != nil {
+			return err
 		}
+	}
+
+	containerClient := a.client.ServiceClient().NewContainerClient(a.containerName)
+	blobName := fmt.Sprintf("%s/%s", a.dir, fileName)
+	appendClient := containerClient.NewAppendBlobClient(blobName)
+
+	props, err := appendClient.GetProperties(ctx, nil)
+	if err != nil {
+		var storageErr *azcore.ResponseError
+		if !errors.As(err, &storageErr) || storageErr.StatusCode != 404 {
+			return err
+		}
+
+		if _, err = appendClient.Create(ctx, nil); err != nil {
+			return err
+		}
+	} else if props.BlobType == nil || *props.BlobType != blob.BlobTypeAppendBlob {
+		if err = convertToAppendBlob(ctx, containerClient, blobName, props); err != nil {
+			return err
+		}
+	} else if metadataChecksum(props.Metadata) != "" {
+		// The checksum of the whole blob isn't known without reading it, so it is removed.
+		if _, err = appendClient.SetMetadata(ctx, withoutChecksum(props.Metadata), nil); err != nil {
+			return err
+		}
+	}
+
+	if err = appendBlocks(ctx, appendClient, reader); err != nil {
+		return err
+	}
 
-		if err = writeRevisionInfo(ctx, a.revisionsProvider, fileName, info); err != nil {
-			return fmt.Errorf("failed to write revision info: %w", err)
+	if props.ContentLength == nil || *props.ContentLength < sniffLength {
+		// The content type is detected from the first bytes of the blob, which the append changed. Setting the HTTP
+		// headers replaces all of them, so the others are kept.
+		if contentType := a.sniffContentType(ctx, containerClient.NewBlockBlobClient(blobName)); contentType != "" {
+			_, err = appendClient.SetHTTPHeaders(ctx, blob.HTTPHeaders{
+				BlobCacheControl:       props.CacheControl,
+				BlobContentDisposition: props.ContentDisposition,
+				BlobContentEncoding:    props.ContentEncoding,
+				BlobContentLanguage:    props.ContentLanguage,
+				BlobContentType:        &contentType,
+			}, nil)
 		}
 	}
 
-	data, err := io.ReadAll(reader)
+	return err
+}
+
+// convertToAppendBlob replaces a block blob with an append blob holding the same content, keeping its content type,
+// metadata and expiry but not its checksum. The blob is only replaced if it hasn't changed since props were read.
+// While the content is appended to the new blob, readers can see it partially written.
+func convertToAppendBlob(ctx context.Context, containerClient *container.Client, blobName string, props blob.GetPropertiesResponse) error {
+	unchanged := &blob.AccessConditions{ModifiedAccessConditions: &blob.ModifiedAccessConditions{IfMatch: props.ETag}}
+
+	resp, err := containerClient.NewBlobClient(blobName).DownloadStream(ctx, &blob.DownloadStreamOptions{AccessConditions: unchanged})
 	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
 
-	blobClient := a.client.ServiceClient().NewContainerClient(a.containerName).NewBlockBlobClient(fmt.Sprintf("%s/%s", a.dir, fileName))
-	_, err = blobClient.UploadStream(ctx, bytes.NewReader(data), nil)
+	existing, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	appendClient := containerClient.NewAppendBlobClient(blobName)
+	if _, err = appendClient.Create(ctx, &appendblob.CreateOptions{
+		HTTPHeaders:      &blob.HTTPHeaders{BlobContentType: props.ContentType},
+		Metadata:         withoutChecksum(props.Metadata),
+		AccessConditions: unchanged,
+	}); err != nil {
+		return err
+	}
+
+	return appendBlocks(ctx, appendClient, bytes.NewReader(existing))
+}
+
+// appendBlocks appends the content of reader to an append blob, in blocks of at most appendBlockSize bytes.
+func appendBlocks(ctx context.Context, appendClient *appendblob.Client, reader io.Reader) error {
+	buf := make([]byte, appendBlockSize)
+	for {
+		n, err := io.ReadFull(reader, buf)
+		if n > 0 {
+			if _, err := appendClient.AppendBlock(ctx, streaming.NopCloser(bytes.NewReader(buf[:n])), nil); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// withoutChecksum returns a copy of the metadata of a blob without its checksum.
+func withoutChecksum(metadata map[string]*string) map[string]*string {
+	metadata = maps.Clone(metadata)
+	maps.DeleteFunc(metadata, func(key string, _ *string) bool {
+		return strings.EqualFold(key, checksumMetadataKey)
+	})
+	return metadata
+}
+
+func (a *azureProvider) CopyFile(ctx context.Context, fileName string, dest workspaceClient, destFileName string, opt WriteOptions) error {
+	fileName, destFileName = strings.TrimPrefix(fileName, "/"), strings.TrimPrefix(destFileName, "/")
+	if err := a.validatePath(fileName, false); err != nil {
+		return err
+	}
+
+	// Blobs can only be copied without credentials for the source within the same storage account.
+	destAzure, ok := dest.(*azureProvider)
+	if !ok || destAzure.client.URL() != a.client.URL() {
+		return streamFile(ctx, a, fileName, dest, destFileName, opt)
+	}
+
+	if err := destAzure.validatePath(destFileName, false); err != nil {
+		return err
+	}
+
+	return copyFile(ctx, a, fileName, dest, AzureProvider+"://"+destAzure.containerName, destFileName, opt, func(ctx context.Context) error {
+		source := a.client.ServiceClient().NewContainerClient(a.containerName).NewBlobClient(fmt.Sprintf("%s/%s", a.dir, fileName))
+		return copyBlob(ctx, source, destAzure.client.ServiceClient().NewContainerClient(destAzure.containerName).NewBlobClient(fmt.Sprintf("%s/%s", destAzure.dir, destFileName)))
+	})
+}
+
+func (a *azureProvider) MoveFile(ctx context.Context, from, to string, opt MoveOptions) error {
+	from, to = strings.TrimPrefix(from, "/"), strings.TrimPrefix(to, "/")
+	if err := a.validatePath(from, false); err != nil {
+		return err
+	}
+	if err := a.validatePath(to, false); err != nil {
+		return err
+	}
+
+	return moveFile(ctx, a, AzureProvider+"://"+a.containerName, from, to, opt, a.renameFile)
+}
+
+// renameFile copies the blob to its new name within the container and then deletes the original.
+func (a *azureProvider) renameFile(ctx context.Context, from, to string) error {
+	containerClient := a.client.ServiceClient().NewContainerClient(a.containerName)
+	source := containerClient.NewBlobClient(fmt.Sprintf("%s/%s", a.dir, from))
+	if err := copyBlob(ctx, source, containerClient.NewBlobClient(fmt.Sprintf("%s/%s", a.dir, to))); err != nil {
+		var storageErr *azcore.ResponseError
+		if errors.As(err, &storageErr) && storageErr.StatusCode == 404 {
+			return newNotFoundError(fmt.Sprintf("%s://%s/%s", AzureProvider, a.containerName, a.dir), from)
+		}
+		return err
+	}
+
+	_, err := source.Delete(ctx, nil)
 	return err
 }
 
+// copyBlob copies a blob within the storage account and waits for the copy to complete.
+func copyBlob(ctx context.Context, source, dest *blob.Client) error {
+	resp, err := dest.StartCopyFromURL(ctx, source.URL(), nil)
+	if err != nil {
+		return err
+	}
+
+	status := resp.CopyStatus
+	for status != nil && *status == blob.CopyStatusTypePending {
+		select {
+		case <-ctx.Done():
+			_, _ = dest.AbortCopyFromURL(ctx, *resp.CopyID, nil)
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		props, err := dest.GetProperties(ctx, nil)
+		if err != nil {
+			return err
+		}
+		status = props.CopyStatus
+	}
+
+	if status != nil && *status != blob.CopyStatusTypeSuccess {
+		return fmt.Errorf("failed to copy blob: copy status %s", *status)
+	}
+
+	return nil
+}
+
 func (a *azureProvider) StatFile(ctx context.Context, fileName string, opt StatOptions) (FileInfo, error) {
 	originalFileName := fileName
 	fileName = strings.TrimPrefix(fileName, "/")
@@ -283,27 +595,22 @@ func (a *azureProvider) StatFile(ctx context.Context, fileName string, opt StatO
 	if err != nil {
 		var storageErr *azcore.ResponseError
 		if errors.As(err, &storageErr) && storageErr.StatusCode == 404 {
+			if opt.withDirs {
+				return a.statDir(ctx, fileName, originalFileName)
+			}
 			// We need to use the original file name here, because that is how the gptscript sdk will determine whether this is a not found error.
 			return FileInfo{}, newNotFoundError(fmt.Sprintf("%s://%s/%s", AzureProvider, a.containerName, a.dir), originalFileName)
 		}
 		return FileInfo{}, err
 	}
 
-	var mime string
-	if props.ContentType != nil {
-		mime = *props.ContentType
-	}
-
-	// Get the first 3072 bytes of the blob to detect the mimetype
-	downloadOpts := &azblob.DownloadStreamOptions{}
-	downloadOpts.Range.Offset = 0
-	downloadOpts.Range.Count = 3072
-	resp, err := blobClient.DownloadStream(ctx, downloadOpts)
-	if err == nil {
-		defer resp.Body.Close()
-		mt, err := mimetype.DetectReader(resp.Body)
-		if err == nil {
-			mime = strings.Split(mt.String(), ";")[0]
+	checksum := metadataChecksum(props.Metadata)
+	mime := storedContentType(props.ContentType, checksum)
+	if mime == "" {
+		// Get the first 3072 bytes of the blob to detect the mimetype
+		mime = a.sniffContentType(ctx, blobClient)
+		if mime == "" && props.ContentType != nil {
+			mime = *props.ContentType
 		}
 	}
 
@@ -328,9 +635,187 @@ func (a *azureProvider) StatFile(ctx context.Context, fileName string, opt StatO
 		ModTime:     modTime,
 		MimeType:    mime,
 		RevisionID:  revision,
+		Checksum:    checksum,
+		Metadata:    userMetadata(props.Metadata),
+		ExpiresAt:   metadataExpiresAt(props.Metadata),
 	}, nil
 }
 
+// statDir returns the information about a directory, which exists if it has a marker or any blobs beneath it. Its
+// modification time is that of its marker, if it has one.
+func (a *azureProvider) statDir(ctx context.Context, dirName, originalFileName string) (FileInfo, error) {
+	blobs, err := a.listDir(ctx, dirName, 1)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	if len(blobs) == 0 {
+		// We need to use the original file name here, because that is how the gptscript sdk will determine whether this is a not found error.
+		return FileInfo{}, newNotFoundError(fmt.Sprintf("%s://%s/%s", AzureProvider, a.containerName, a.dir), originalFileName)
+	}
+
+	info := FileInfo{
+		WorkspaceID: fmt.Sprintf("%s://%s/%s", AzureProvider, a.containerName, a.dir),
+		Name:        dirName + "/",
+		IsDir:       true,
+	}
+	// The marker sorts before every other blob beneath the directory.
+	if *blobs[0].Name == a.dirMarkerName(dirName) && blobs[0].Properties != nil && blobs[0].Properties.LastModified != nil {
+		info.ModTime = *blobs[0].Properties.LastModified
+	}
+	return info, nil
+}
+
+// listDir returns up to limit blobs beneath a directory, including its marker.
+func (a *azureProvider) listDir(ctx context.Context, dirName string, limit int32) ([]*container.BlobItem, error) {
+	prefix := a.dirMarkerName(dirName)
+	resp, err := a.client.ServiceClient().NewContainerClient(a.containerName).NewListBlobsFlatPager(&container.ListBlobsFlatOptions{
+		Prefix:     &prefix,
+		MaxResults: &limit,
+	}).NextPage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Segment.BlobItems, nil
+}
+
+func (a *azureProvider) dirMarkerName(dirName string) string {
+	return fmt.Sprintf("%s/%s/", a.dir, dirName)
+}
+
+// Mkdir writes the marker of the directory. Its parents exist as long as it does.
+func (a *azureProvider) Mkdir(ctx context.Context, dirName string) error {
+	if err := a.validatePath(dirName, false); err != nil {
+		return err
+	}
+
+	blobClient := a.client.ServiceClient().NewContainerClient(a.containerName).NewBlockBlobClient(a.dirMarkerName(dirName))
+	_, err := blobClient.UploadStream(ctx, bytes.NewReader(nil), nil)
+	return err
+}
+
+func (a *azureProvider) RemoveDir(ctx context.Context, dirName string, recursive bool) error {
+	if err := a.validatePath(dirName, false); err != nil {
+		return err
+	}
+
+	// Listing two blobs is enough to tell whether there is anything other than the marker.
+	blobs, err := a.listDir(ctx, dirName, 2)
+	if err != nil {
+		return err
+	}
+	if len(blobs) == 0 {
+		return newNotFoundError(fmt.Sprintf("%s://%s/%s", AzureProvider, a.containerName, a.dir), dirName)
+	}
+
+	if recursive {
+		return a.RemoveAllWithPrefix(ctx, dirName)
+	}
+
+	for _, blob := range blobs {
+		if *blob.Name != a.dirMarkerName(dirName) {
+			return newDirectoryNotEmptyError(fmt.Sprintf("%s://%s/%s", AzureProvider, a.containerName, a.dir), dirName)
+		}
+	}
+
+	_, err = a.client.ServiceClient().NewContainerClient(a.containerName).NewBlockBlobClient(a.dirMarkerName(dirName)).Delete(ctx, nil)
+	if err != nil {
+		var storageErr *azcore.ResponseError
+		if errors.As(err, &storageErr) && storageErr.StatusCode == 404 {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// SetMetadata replaces the metadata of the blob, keeping its checksum.
+func (a *azureProvider) SetMetadata(ctx context.Context, fileName string, metadata map[string]string) error {
+	originalFileName := fileName
+	fileName = strings.TrimPrefix(fileName, "/")
+	if err := a.validatePath(fileName, false); err != nil {
+		return err
+	}
+	blobClient := a.client.ServiceClient().NewContainerClient(a.containerName).NewBlobClient(fmt.Sprintf("%s/%s", a.dir, fileName))
+
+	props, err := blobClient.GetProperties(ctx, nil)
+	if err != nil {
+		var storageErr *azcore.ResponseError
+		if errors.As(err, &storageErr) && storageErr.StatusCode == 404 {
+			return newNotFoundError(fmt.Sprintf("%s://%s/%s", AzureProvider, a.containerName, a.dir), originalFileName)
+		}
+		return err
+	}
+
+	_, err = blobClient.SetMetadata(ctx, azureMetadata(objectMetadata(metadata, metadataChecksum(props.Metadata), metadataExpiresAt(props.Metadata))), nil)
+	return err
+}
+
+// azureMetadata converts metadata to the form the Azure SDK takes.
+func azureMetadata(metadata map[string]string) map[string]*string {
+	result := make(map[string]*string, len(metadata))
+	for key, value := range metadata {
+		result[key] = &value
+	}
+	return result
+}
+
+func (a *azureProvider) BackfillContentType(ctx context.Context, fileName string) (bool, error) {
+	originalFileName := fileName
+	fileName = strings.TrimPrefix(fileName, "/")
+	if err := a.validatePath(fileName, false); err != nil {
+		return false, err
+	}
+	blobClient := a.client.ServiceClient().NewContainerClient(a.containerName).NewBlockBlobClient(fmt.Sprintf("%s/%s", a.dir, fileName))
+
+	props, err := blobClient.GetProperties(ctx, nil)
+	if err != nil {
+		var storageErr *azcore.ResponseError
+		if errors.As(err, &storageErr) && storageErr.StatusCode == 404 {
+			return false, newNotFoundError(fmt.Sprintf("%s://%s/%s", AzureProvider, a.containerName, a.dir), originalFileName)
+		}
+		return false, err
+	}
+
+	if storedContentType(props.ContentType, metadataChecksum(props.Metadata)) != "" {
+		return false, nil
+	}
+
+	contentType := a.sniffContentType(ctx, blobClient)
+	if contentType == "" {
+		return false, nil
+	}
+
+	// Setting the HTTP headers replaces all of them, so the others are kept.
+	_, err = blobClient.SetHTTPHeaders(ctx, blob.HTTPHeaders{
+		BlobCacheControl:       props.CacheControl,
+		BlobContentDisposition: props.ContentDisposition,
+		BlobContentEncoding:    props.ContentEncoding,
+		BlobContentLanguage:    props.ContentLanguage,
+		BlobContentMD5:         props.ContentMD5,
+		BlobContentType:        &contentType,
+	}, nil)
+	return err == nil, err
+}
+
+// sniffContentType detects the content type of a blob from its first bytes, or returns nothing if it can't be read.
+func (a *azureProvider) sniffContentType(ctx context.Context, blobClient *blockblob.Client) string {
+	downloadOpts := &azblob.DownloadStreamOptions{}
+	downloadOpts.Range.Offset = 0
+	downloadOpts.Range.Count = sniffLength
+	resp, err := blobClient.DownloadStream(ctx, downloadOpts)
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+
+	mt, err := mimetype.DetectReader(resp.Body)
+	if err != nil {
+		return ""
+	}
+
+	return strings.Split(mt.String(), ";")[0]
+}
+
 func (a *azureProvider) RemoveAllWithPrefix(ctx context.Context, prefix string) error {
 	prefix = strings.TrimPrefix(prefix, "/")
 	if err := a.validatePath(prefix, true); err != nil {
@@ -364,6 +849,153 @@ func (a *azureProvider) RemoveAllWithPrefix(ctx context.Context, prefix string)
 	return nil
 }
 
+func (a *azureProvider) LsWithInfo(ctx context.Context, prefix string) ([]FileInfo, error) {
+	prefix = strings.TrimPrefix(prefix, "/")
+	if prefix != "" {
+		prefix = fmt.Sprintf("%s/", strings.TrimSuffix(prefix, "/"))
+	}
+
+	return a.StatWithPrefix(ctx, prefix)
+}
+
+func (a *azureProvider) LsPage(ctx context.Context, prefix string, limit int, continuation string) ([]FileInfo, string, error) {
+	prefix = strings.TrimPrefix(prefix, "/")
+	if err := a.validatePath(prefix, true); err != nil {
+		return nil, "", err
+	}
+	if prefix != "" {
+		prefix = fmt.Sprintf("%s/%s/", a.dir, strings.TrimSuffix(prefix, "/"))
+	} else {
+		prefix = fmt.Sprintf("%s/", a.dir)
+	}
+
+	maxResults := int32(limit)
+	opts := &container.ListBlobsFlatOptions{
+		Prefix:     &prefix,
+		MaxResults: &maxResults,
+		Include:    container.ListBlobsInclude{Metadata: true},
+	}
+	if continuation != "" {
+		opts.Marker = &continuation
+	}
+
+	resp, err := a.client.ServiceClient().NewContainerClient(a.containerName).NewListBlobsFlatPager(opts).NextPage(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	files := make([]FileInfo, 0, len(resp.Segment.BlobItems))
+	for _, blob := range resp.Segment.BlobItems {
+		if isDirMarker(*blob.Name) {
+			continue
+		}
+		files = append(files, a.blobFileInfo(blob))
+	}
+
+	var next string
+	if resp.NextMarker != nil {
+		next = *resp.NextMarker
+	}
+
+	return files, next, nil
+}
+
+func (a *azureProvider) LsDir(ctx context.Context, prefix string) ([]FileInfo, error) {
+	prefix = strings.TrimPrefix(prefix, "/")
+	if err := a.validatePath(prefix, true); err != nil {
+		return nil, err
+	}
+	if prefix != "" {
+		prefix = fmt.Sprintf("%s/%s/", a.dir, strings.TrimSuffix(prefix, "/"))
+	} else {
+		prefix = fmt.Sprintf("%s/", a.dir)
+	}
+
+	containerClient := a.client.ServiceClient().NewContainerClient(a.containerName)
+	pager := containerClient.NewListBlobsHierarchyPager("/", &container.ListBlobsHierarchyOptions{
+		Prefix:  &prefix,
+		Include: container.ListBlobsInclude{Metadata: true},
+	})
+
+	var files []FileInfo
+	for pager.More() {
+		resp, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, blobPrefix := range resp.Segment.BlobPrefixes {
+			files = append(files, FileInfo{
+				WorkspaceID: fmt.Sprintf("%s://%s/%s", AzureProvider, a.containerName, a.dir),
+				Name:        strings.TrimPrefix(*blobPrefix.Name, a.dir+"/"),
+				IsDir:       true,
+			})
+		}
+		for _, blob := range resp.Segment.BlobItems {
+			if isDirMarker(*blob.Name) {
+				continue
+			}
+			files = append(files, a.blobFileInfo(blob))
+		}
+	}
+
+	return files, nil
+}
+
+func (a *azureProvider) StatWithPrefix(ctx context.Context, prefix string) ([]FileInfo, error) {
+	prefix = strings.TrimPrefix(prefix, "/")
+	if err := a.validatePath(prefix, true); err != nil {
+		return nil, err
+	}
+	prefix = fmt.Sprintf("%s/%s", a.dir, prefix)
+
+	containerClient := a.client.ServiceClient().NewContainerClient(a.containerName)
+	pager := containerClient.NewListBlobsFlatPager(&container.ListBlobsFlatOptions{
+		Prefix:  &prefix,
+		Include: container.ListBlobsInclude{Metadata: true},
+	})
+
+	var files []FileInfo
+	for pager.More() {
+		resp, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, blob := range resp.Segment.BlobItems {
+			if isDirMarker(*blob.Name) {
+				continue
+			}
+			files = append(files, a.blobFileInfo(blob))
+		}
+	}
+
+	return files, nil
+}
+
+// blobFileInfo returns the information about a blob from its listing.
+func (a *azureProvider) blobFileInfo(blob *container.BlobItem) FileInfo {
+	info := FileInfo{
+		WorkspaceID: fmt.Sprintf("%s://%s/%s", AzureProvider, a.containerName, a.dir),
+		Name:        strings.TrimPrefix(*blob.Name, a.dir+"/"),
+		Checksum:    metadataChecksum(blob.Metadata),
+		Metadata:    userMetadata(blob.Metadata),
+		ExpiresAt:   metadataExpiresAt(blob.Metadata),
+	}
+	if props := blob.Properties; props != nil {
+		if props.ContentLength != nil {
+			info.Size = *props.ContentLength
+		}
+		if props.LastModified != nil {
+			info.ModTime = *props.LastModified
+		}
+		if props.ContentType != nil {
+			info.MimeType = *props.ContentType
+		}
+	}
+	return info
+}
+
 func (a *azureProvider) ListRevisions(ctx context.Context, fileName string) ([]RevisionInfo, error) {
 	fileName = strings.TrimPrefix(fileName, "/")
 	if err := a.validatePath(fileName, false); err != nil {
@@ -385,7 +1017,7 @@ func (a *azureProvider) DeleteRevision(ctx context.Context, fileName, revisionID
 	if err := a.validatePath(fileName, false); err != nil {
 		return err
 	}
-	return deleteRevision(ctx, a.revisionsProvider, fileName, revisionID)
+	return removeRevision(ctx, a.revisionsProvider, fileName, revisionID)
 }
 
 func (a *azureProvider) RevisionClient() workspaceClient {
@@ -0,0 +1,372 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	BatchWrite  = "write"
+	BatchDelete = "delete"
+)
+
+// stagingDir is the directory, next to the manifests, that batches stage their content in while they are applied.
+const stagingDir = "staging"
+
+type BatchOperation struct {
+	// Op is either BatchWrite or BatchDelete.
+	Op       string
+	FileName string
+	// Content is the content to write.
+	Content io.Reader
+	// If LatestRevisionID is set, then the batch fails with a conflict error if that revision is not the latest.
+	LatestRevisionID string
+	// IfNotExists fails the batch if the file exists. It is only used by writes, and is mutually exclusive with
+	// LatestRevisionID.
+	IfNotExists bool
+	// Checksum, ContentType and Metadata are used by writes as in WriteOptions.
+	Checksum    string
+	ContentType string
+	Metadata    map[string]string
+}
+
+type BatchOptions struct {
+	// CreateRevision can be set to false to apply the batch without storing revisions of the files it writes.
+	CreateRevision *bool
+}
+
+// batchUndo reverts an operation of a batch that has been applied.
+type batchUndo func(context.Context) error
+
+// Batch applies writes and deletes to the files of a workspace, all or nothing. The preconditions of every operation
+// are checked and the content of every write is staged next to the workspace's manifest before any file is changed. The
+// operations are then applied in order, and if one fails, then the ones already applied are rolled back, including
+// their revisions.
+//
+// The batch is not isolated: other clients can see the files change one at a time while it is applied.
+func (c *Client) Batch(ctx context.Context, id string, ops []BatchOperation, opts ...BatchOptions) error {
+	var opt BatchOptions
+	for _, o := range opts {
+		if o.CreateRevision != nil {
+			opt.CreateRevision = o.CreateRevision
+		}
+	}
+
+	if err := validateBatch(ops); err != nil {
+		return err
+	}
+
+	factory, id, err := c.getWorkspaceFactory(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	wc, err := factory.New(id)
+	if err != nil {
+		return err
+	}
+
+	// The batch is charged against the quota before it is applied, but its revisions aren't counted, so the usage is
+	// recomputed afterward.
+	defer c.markUsageStale(ctx, id)
+
+	createRevision := opt.CreateRevision == nil || *opt.CreateRevision
+	if err = checkBatchPreconditions(ctx, wc, id, ops, createRevision); err != nil {
+		return err
+	}
+
+	// rc is the revision client that the writes record revisions in, if any.
+	rc := wc.RevisionClient()
+	if !createRevision {
+		rc = nil
+	}
+
+	sc, stageDir, err := stagingClient(factory, id)
+	if err != nil {
+		return err
+	}
+	stageDir += "/" + uuid.NewString()
+	defer func() {
+		// Best effort, using a context that isn't canceled so that the staged content is removed even if the batch was.
+		_ = sc.RemoveAllWithPrefix(context.WithoutCancel(ctx), stageDir)
+	}()
+
+	for i, op := range ops {
+		if op.Op != BatchWrite {
+			continue
+		}
+
+		if err = sc.WriteFile(ctx, stagedFileName(stageDir, i), op.Content, WriteOptions{
+			CreateRevision: &[]bool{false}[0],
+			Checksum:       op.Checksum,
+			ContentType:    op.ContentType,
+			Metadata:       op.Metadata,
+		}); err != nil {
+			if cme := (*ChecksumMismatchError)(nil); errors.As(err, &cme) {
+				// Report the mismatch against the file being written rather than its staged content.
+				return newChecksumMismatchError(id, op.FileName, cme.expected, cme.actual)
+			}
+			return fmt.Errorf("failed to stage %s: %w", op.FileName, err)
+		}
+	}
+
+	if err = c.chargeBatch(ctx, id, wc, sc, stageDir, ops, createRevision); err != nil {
+		return err
+	}
+
+	var undos []batchUndo
+	// deleted holds the revision info of the files that the batch deleted, by the index of their operation.
+	deleted := make([]revisionInfo, len(ops))
+	for i, op := range ops {
+		var undo batchUndo
+		if op.Op == BatchWrite {
+			undo, err = applyBatchWrite(ctx, wc, rc, sc, stageDir, i, op, opt, c.deltaKeyframeInterval())
+		} else {
+			undo, err = applyBatchDelete(ctx, wc, sc, id, stageDir, i, op, &deleted[i])
+		}
+		if undo != nil {
+			undos = append(undos, undo)
+		}
+		if err != nil {
+			if ce := (*ConflictError)(nil); errors.As(err, &ce) && op.IfNotExists {
+				err = &[]FileExistsError{FileExistsError(*ce)}[0]
+			}
+			return rollBackBatch(context.WithoutCancel(ctx), undos, err)
+		}
+	}
+
+	// The batch has been applied, so the revisions of the files it deleted are now removed.
+	if drc := wc.RevisionClient(); drc != nil {
+		for i, op := range ops {
+			if op.Op == BatchDelete {
+				// Best effort
+				deleteRevisions(ctx, drc, op.FileName, deleted[i])
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateBatch(ops []BatchOperation) error {
+	if len(ops) == 0 {
+		return fmt.Errorf("a batch requires at least one operation")
+	}
+
+	seen := make(map[string]struct{}, len(ops))
+	for _, op := range ops {
+		if op.FileName == "" {
+			return fmt.Errorf("a file name is required for each operation in a batch")
+		}
+		if _, ok := seen[op.FileName]; ok {
+			return fmt.Errorf("file %s is changed more than once in the batch", op.FileName)
+		}
+		seen[op.FileName] = struct{}{}
+
+		switch op.Op {
+		case BatchWrite:
+			if op.Content == nil {
+				return fmt.Errorf("content is required to write %s", op.FileName)
+			}
+			if op.IfNotExists && op.LatestRevisionID != "" {
+				return fmt.Errorf("latest revision and if not exists are mutually exclusive for %s", op.FileName)
+			}
+			if err := validateMetadata(op.Metadata); err != nil {
+				return err
+			}
+		case BatchDelete:
+			if op.IfNotExists {
+				return fmt.Errorf("if not exists cannot be used to delete %s", op.FileName)
+			}
+		default:
+			return fmt.Errorf("invalid batch operation for %s: %q", op.FileName, op.Op)
+		}
+	}
+
+	return nil
+}
+
+// checkBatchPreconditions returns an error if any of the operations can't be applied, before anything is changed. As
+// with WriteFile, the latest revision of a write is only checked when revisions are created.
+func checkBatchPreconditions(ctx context.Context, wc workspaceClient, workspaceID string, ops []BatchOperation, createRevision bool) error {
+	rc := wc.RevisionClient()
+	for _, op := range ops {
+		if op.Op == BatchDelete || op.IfNotExists {
+			_, err := wc.StatFile(ctx, op.FileName, StatOptions{})
+			if err != nil {
+				if nfe := (*NotFoundError)(nil); !errors.As(err, &nfe) || op.Op == BatchDelete {
+					return err
+				}
+			} else if op.IfNotExists {
+				return &FileExistsError{id: workspaceID, name: op.FileName}
+			}
+		}
+
+		if op.LatestRevisionID != "" && rc != nil && (createRevision || op.Op == BatchDelete) {
+			info, err := getRevisionInfo(ctx, rc, op.FileName)
+			if err != nil {
+				return err
+			}
+			if current := strconv.FormatInt(info.CurrentID, 10); op.LatestRevisionID != current {
+				return newConflictError(workspaceID, op.FileName, op.LatestRevisionID, current)
+			}
+		}
+	}
+
+	return nil
+}
+
+// applyBatchWrite copies the staged content of a write over the file, recording a revision unless rc is nil. The file
+// is first backed up to the staging client so that it can be restored, along with its revision info. The returned undo is set if the file
+// may have changed, even if an error is returned. The previous revision is re-encoded as a delta as in WriteFile if
+// keyframeInterval is set.
+func applyBatchWrite(ctx context.Context, wc, rc, sc workspaceClient, stageDir string, i int, op BatchOperation, opt BatchOptions, keyframeInterval int64) (batchUndo, error) {
+	exists := true
+	if _, err := wc.StatFile(ctx, op.FileName, StatOptions{}); err != nil {
+		if nfe := (*NotFoundError)(nil); !errors.As(err, &nfe) {
+			return nil, err
+		}
+		exists = false
+	}
+
+	var info revisionInfo
+	if rc != nil {
+		var err error
+		if info, err = getRevisionInfo(ctx, rc, op.FileName); err != nil {
+			return nil, err
+		}
+	}
+
+	backup := backupFileName(stageDir, i)
+	if exists {
+		if err := wc.CopyFile(ctx, op.FileName, sc, backup, WriteOptions{CreateRevision: &[]bool{false}[0]}); err != nil {
+			return nil, fmt.Errorf("failed to back up %s: %w", op.FileName, err)
+		}
+	}
+
+	undo := func(ctx context.Context) error {
+		if !exists {
+			// This also removes the revision info created by the write.
+			return wc.DeleteFile(ctx, op.FileName)
+		}
+
+		if err := sc.CopyFile(ctx, backup, wc, op.FileName, WriteOptions{CreateRevision: &[]bool{false}[0]}); err != nil {
+			return err
+		}
+		if rc == nil {
+			return nil
+		}
+
+		// The write may have re-encoded the latest revision before it as a delta against the revision being removed.
+		if err := restoreFullRevision(ctx, rc, op.FileName, info.CurrentID); err != nil {
+			return err
+		}
+
+		// Best effort, the revision only holds the content that was restored.
+		_ = deleteRevision(ctx, rc, op.FileName, strconv.FormatInt(info.CurrentID+1, 10))
+		if info.CurrentID == -1 {
+			return deleteRevisionInfo(ctx, rc, op.FileName)
+		}
+		return writeRevisionInfo(ctx, rc, op.FileName, info)
+	}
+
+	latestRevisionID := op.LatestRevisionID
+	if op.IfNotExists {
+		latestRevisionID = "-1"
+	}
+
+	err := sc.CopyFile(ctx, stagedFileName(stageDir, i), wc, op.FileName, WriteOptions{
+		CreateRevision:   opt.CreateRevision,
+		LatestRevisionID: latestRevisionID,
+		keyframeInterval: keyframeInterval,
+	})
+	if ce := (*ConflictError)(nil); errors.As(err, &ce) {
+		// The file was changed by someone else since its preconditions were checked, and this batch didn't change it.
+		return nil, err
+	}
+	return undo, err
+}
+
+// applyBatchDelete backs up the file to the staging client and deletes it, keeping its revisions so that both can be
+// restored if the batch is rolled back. The revision info of the file is set in info so that its revisions can be
+// removed once the whole batch has been applied.
+func applyBatchDelete(ctx context.Context, wc, sc workspaceClient, workspaceID, stageDir string, i int, op BatchOperation, info *revisionInfo) (batchUndo, error) {
+	rc := wc.RevisionClient()
+	info.CurrentID = -1
+	if rc != nil {
+		var err error
+		if *info, err = getRevisionInfo(ctx, rc, op.FileName); err != nil {
+			return nil, err
+		}
+		if current := strconv.FormatInt(info.CurrentID, 10); op.LatestRevisionID != "" && op.LatestRevisionID != current {
+			// The file was changed by someone else since its preconditions were checked.
+			return nil, newConflictError(workspaceID, op.FileName, op.LatestRevisionID, current)
+		}
+	}
+
+	backup := backupFileName(stageDir, i)
+	if err := wc.CopyFile(ctx, op.FileName, sc, backup, WriteOptions{CreateRevision: &[]bool{false}[0]}); err != nil {
+		// The file may have been deleted by someone else since its preconditions were checked.
+		return nil, fmt.Errorf("failed to back up %s: %w", op.FileName, err)
+	}
+
+	// Without its revision info, deleting the file leaves its revisions in place.
+	if info.CurrentID != -1 {
+		if err := deleteRevisionInfo(ctx, rc, op.FileName); err != nil {
+			return nil, err
+		}
+	}
+
+	undo := func(ctx context.Context) error {
+		if _, err := wc.StatFile(ctx, op.FileName, StatOptions{}); err != nil {
+			if nfe := (*NotFoundError)(nil); !errors.As(err, &nfe) {
+				return err
+			}
+			if err = sc.CopyFile(ctx, backup, wc, op.FileName, WriteOptions{CreateRevision: &[]bool{false}[0]}); err != nil {
+				return err
+			}
+		}
+		if info.CurrentID == -1 {
+			return nil
+		}
+		return writeRevisionInfo(ctx, rc, op.FileName, *info)
+	}
+
+	return undo, wc.DeleteFile(ctx, op.FileName)
+}
+
+// rollBackBatch reverts the applied operations of a batch in reverse order, and returns the error that caused the
+// rollback along with any errors from reverting.
+func rollBackBatch(ctx context.Context, undos []batchUndo, cause error) error {
+	errs := []error{cause}
+	for i := len(undos) - 1; i >= 0; i-- {
+		if err := undos[i](ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to roll back batch: %w", err))
+		}
+	}
+	if len(errs) == 1 {
+		return cause
+	}
+	return errors.Join(errs...)
+}
+
+func stagedFileName(stageDir string, i int) string {
+	return fmt.Sprintf("%s/%d", stageDir, i)
+}
+
+func backupFileName(stageDir string, i int) string {
+	return fmt.Sprintf("%s/%d.backup", stageDir, i)
+}
+
+// stagingClient returns the client that batches stage their content in for the workspace, along with the workspace's
+// directory in it.
+func stagingClient(factory workspaceFactory, id string) (workspaceClient, string, error) {
+	mc, name, err := factory.ManifestClient(id)
+	return mc, stagingDir + "/" + strings.TrimSuffix(name, ".json"), err
+}
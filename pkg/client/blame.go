@@ -0,0 +1,228 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// maxDiffEdits bounds the work done when diffing two versions of a file. Versions that differ by more lines than this
+// are treated as if every line between their common prefix and suffix changed. The trace of a diff grows with the
+// square of the number of edits, so this also bounds it to about a million entries.
+const maxDiffEdits = 1024
+
+var NotTextFileError = errors.New("file is not a text file")
+
+type BlameLine struct {
+	LineNumber int    `json:"lineNumber"`
+	Text       string `json:"text"`
+	// RevisionID is the ID of the earliest surviving revision that contains the line. It is empty if the line was
+	// introduced by the current content of the file.
+	RevisionID string `json:"revisionID"`
+	// WrittenAt is when the content that introduced the line was written, if known.
+	WrittenAt time.Time `json:"writtenAt"`
+}
+
+type blameOrigin struct {
+	revisionID string
+	writtenAt  time.Time
+}
+
+func blame(ctx context.Context, wc workspaceClient, fileName string) ([]BlameLine, error) {
+	f, err := wc.OpenFile(ctx, fileName, OpenOptions{})
+	if err != nil {
+		return nil, err
+	}
+	current, err := io.ReadAll(f)
+	_ = f.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if !isText(current) {
+		return nil, NotTextFileError
+	}
+
+	var info revisionInfo
+	if rc := wc.RevisionClient(); rc != nil {
+		if info, err = getRevisionInfo(ctx, rc, fileName); err != nil {
+			return nil, err
+		}
+	}
+
+	revisions, err := wc.ListRevisions(ctx, fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Walk the surviving revisions from oldest to newest. Lines introduced by a deleted revision first appear in the
+	// next surviving one, so they are attributed to it.
+	var (
+		lines   []string
+		origins []blameOrigin
+	)
+	for _, rev := range revisions {
+		f, err := wc.GetRevision(ctx, fileName, rev.RevisionID)
+		if err != nil {
+			if nfe := (*NotFoundError)(nil); errors.As(err, &nfe) {
+				continue
+			}
+			return nil, err
+		}
+
+		content, err := io.ReadAll(f)
+		_ = f.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		// Revision N holds the content that was written when N-1 became the current ID.
+		id, _ := parseRevisionID(rev.RevisionID)
+		next := splitLines(content)
+		origins = blameStep(lines, origins, next, blameOrigin{revisionID: rev.RevisionID, writtenAt: info.WrittenAt[id-1]})
+		lines = next
+	}
+
+	next := splitLines(current)
+	origins = blameStep(lines, origins, next, blameOrigin{writtenAt: info.WrittenAt[info.CurrentID]})
+
+	result := make([]BlameLine, 0, len(next))
+	for i, line := range next {
+		result = append(result, BlameLine{
+			LineNumber: i + 1,
+			Text:       line,
+			RevisionID: origins[i].revisionID,
+			WrittenAt:  origins[i].writtenAt,
+		})
+	}
+
+	return result, nil
+}
+
+// blameStep carries the origins of unchanged lines over to the next version and attributes every other line to origin.
+func blameStep(prev []string, prevOrigins []blameOrigin, next []string, origin blameOrigin) []blameOrigin {
+	origins := make([]blameOrigin, len(next))
+	for i, match := range matchLines(prev, next) {
+		if match == -1 {
+			origins[i] = origin
+		} else {
+			origins[i] = prevOrigins[match]
+		}
+	}
+	return origins
+}
+
+// matchLines returns, for each line of b, the index of the same line in a or -1 if the line was added. It uses the
+// Myers diff algorithm after trimming the common prefix and suffix.
+func matchLines(a, b []string) []int {
+	matches := make([]int, len(b))
+	for i := range matches {
+		matches[i] = -1
+	}
+
+	var prefix int
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		matches[prefix] = prefix
+		prefix++
+	}
+
+	var suffix int
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		matches[len(b)-1-suffix] = len(a) - 1 - suffix
+		suffix++
+	}
+
+	a, b = a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return matches
+	}
+
+	maxEdits := min(n+m, maxDiffEdits)
+	offset := maxEdits + 1
+	v := make([]int, 2*maxEdits+3)
+	trace := make([][]int, 0, 16)
+
+	for d := 0; d <= maxEdits; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				backtrack(trace, n, m, func(x, y int) {
+					matches[prefix+y] = prefix + x
+				})
+				return matches
+			}
+		}
+	}
+
+	// Too many edits, so the middle of the file is treated as entirely changed.
+	return matches
+}
+
+// backtrack walks the trace of a Myers diff from the end, calling match for each pair of equal lines.
+func backtrack(trace [][]int, x, y int, match func(x, y int)) {
+	for d := len(trace) - 1; d >= 0; d-- {
+		// trace[d] holds the furthest reaching x values for diagonals -d-1 through d+1.
+		v := func(k int) int { return trace[d][k+d+1] }
+
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v(k-1) < v(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+
+		prevX := v(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			match(x, y)
+		}
+
+		if d > 0 {
+			x, y = prevX, prevY
+		}
+	}
+}
+
+func splitLines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+}
+
+func isText(content []byte) bool {
+	if len(content) == 0 {
+		return true
+	}
+
+	for mt := mimetype.Detect(content); mt != nil; mt = mt.Parent() {
+		if mt.Is("text/plain") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,34 @@
+package client
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMatchLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     string
+		expected []int
+	}{
+		{name: "empty", a: "", b: "", expected: []int{}},
+		{name: "added to empty", a: "", b: "a b", expected: []int{-1, -1}},
+		{name: "identical", a: "a b c", b: "a b c", expected: []int{0, 1, 2}},
+		{name: "appended", a: "a b", b: "a b c", expected: []int{0, 1, -1}},
+		{name: "prepended", a: "a b", b: "c a b", expected: []int{-1, 0, 1}},
+		{name: "removed", a: "a b c", b: "a c", expected: []int{0, 2}},
+		{name: "replaced", a: "a b c", b: "a x c", expected: []int{0, -1, 2}},
+		{name: "moved", a: "a b c d", b: "b c a d", expected: []int{1, 2, -1, 3}},
+		{name: "interleaved", a: "a b c d e", b: "x a y c z e", expected: []int{-1, 0, -1, 2, -1, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches := matchLines(strings.Fields(tt.a), strings.Fields(tt.b))
+			if !reflect.DeepEqual(matches, tt.expected) {
+				t.Errorf("unexpected matches: %v, expected %v", matches, tt.expected)
+			}
+		})
+	}
+}
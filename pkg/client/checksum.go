@@ -0,0 +1,89 @@
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	// checksumMetadataKey is the S3 and Azure metadata key that the SHA-256 checksum of a file is stored under.
+	checksumMetadataKey = "sha256"
+	// checksumXattr is the extended attribute that the SHA-256 checksum of a file is stored in on disk.
+	checksumXattr = "user.workspace-provider.sha256"
+)
+
+// checksumOf returns the hex encoded SHA-256 checksum of the reader's content, and then seeks back to the start.
+func checksumOf(r io.ReadSeeker) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// verifyChecksum returns a ChecksumMismatchError if an expected checksum is given and the actual checksum differs.
+func verifyChecksum(workspaceID, fileName, expected, actual string) error {
+	if expected != "" && !strings.EqualFold(expected, actual) {
+		return newChecksumMismatchError(workspaceID, fileName, strings.ToLower(expected), actual)
+	}
+	return nil
+}
+
+// metadataChecksum returns the checksum from S3 or Azure metadata.
+func metadataChecksum[T string | *string](metadata map[string]T) string {
+	return metadataValue(metadata, checksumMetadataKey)
+}
+
+// fileChecksum returns the checksum stored with a file on disk, or nothing if there isn't one or the file system
+// doesn't support extended attributes.
+func fileChecksum(f *os.File) string {
+	return fileAttr(f, checksumXattr)
+}
+
+// pathChecksum returns the checksum stored with the file at the path, like fileChecksum.
+func pathChecksum(path string) string {
+	return pathAttr(path, checksumXattr)
+}
+
+// setFileChecksum stores the checksum with a file on disk, or removes it if the checksum is empty. This is best
+// effort, since not all file systems support extended attributes.
+func setFileChecksum(f *os.File, sum string) {
+	setFileAttr(f, checksumXattr, sum)
+}
+
+// checksumReader verifies the checksum of the content read through it, returning a ChecksumMismatchError instead of
+// io.EOF if it doesn't match.
+type checksumReader struct {
+	io.ReadCloser
+	hash                            hash.Hash
+	workspaceID, fileName, expected string
+}
+
+func newChecksumReader(rc io.ReadCloser, workspaceID, fileName, expected string) *checksumReader {
+	return &checksumReader{
+		ReadCloser:  rc,
+		hash:        sha256.New(),
+		workspaceID: workspaceID,
+		fileName:    fileName,
+		expected:    expected,
+	}
+}
+
+func (r *checksumReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.hash.Write(p[:n])
+	if err == io.EOF {
+		if err := verifyChecksum(r.workspaceID, r.fileName, r.expected, hex.EncodeToString(r.hash.Sum(nil))); err != nil {
+			return n, err
+		}
+	}
+	return n, err
+}
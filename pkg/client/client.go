@@ -5,10 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"iter"
 	"maps"
 	"path/filepath"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/adrg/xdg"
 )
@@ -17,21 +19,48 @@ const (
 	DirectoryProvider = "directory"
 	S3Provider        = "s3"
 	AzureProvider     = "azure"
+
+	// RevisionEncodingFull stores every revision as a full copy of the file.
+	RevisionEncodingFull = "full"
+	// RevisionEncodingDelta stores older revisions as deltas against the next revision, with periodic full keyframes.
+	RevisionEncodingDelta = "delta"
+
+	defaultRevisionKeyframeInterval = 10
 )
 
 type workspaceFactory interface {
 	New(string) (workspaceClient, error)
 	Create() string
 	Rm(context.Context, string) error
+	// List returns up to limit IDs of the provider's workspaces that sort after the given ID, sorted, or all of them if
+	// limit is 0.
+	List(ctx context.Context, after string, limit int) ([]string, error)
+	// ManifestClient returns the client that the manifest of a workspace is kept in, along with its file name, which is
+	// escaped for workspaces that aren't directly under the provider's data home, bucket or container. An error is
+	// returned for an ID that can't be a workspace, such as that of the data home itself.
+	ManifestClient(string) (workspaceClient, string, error)
+	// NamesClient returns the client that the names of the provider's workspaces are registered in.
+	NamesClient() workspaceClient
 }
 
 type workspaceClient interface {
 	Ls(context.Context, string) ([]string, error)
+	LsWithInfo(context.Context, string) ([]FileInfo, error)
+	LsDir(context.Context, string) ([]FileInfo, error)
+	LsPage(context.Context, string, int, string) ([]FileInfo, string, error)
+	BackfillContentType(context.Context, string) (bool, error)
+	SetMetadata(context.Context, string, map[string]string) error
 	OpenFile(context.Context, string, OpenOptions) (*File, error)
 	WriteFile(context.Context, string, io.Reader, WriteOptions) error
+	AppendFile(context.Context, string, io.Reader, WriteOptions) error
 	DeleteFile(context.Context, string) error
+	MoveFile(context.Context, string, string, MoveOptions) error
+	CopyFile(context.Context, string, workspaceClient, string, WriteOptions) error
 	StatFile(context.Context, string, StatOptions) (FileInfo, error)
 	RemoveAllWithPrefix(context.Context, string) error
+	Mkdir(context.Context, string) error
+	RemoveDir(context.Context, string, bool) error
+	StatWithPrefix(context.Context, string) ([]FileInfo, error)
 	ListRevisions(context.Context, string) ([]RevisionInfo, error)
 	GetRevision(context.Context, string, string) (*File, error)
 	DeleteRevision(context.Context, string, string) error
@@ -45,6 +74,17 @@ type Options struct {
 	S3UsePathStyle        bool
 	AzureContainerName    string
 	AzureConnectionString string
+	// RevisionEncoding is either RevisionEncodingFull (the default) or RevisionEncodingDelta.
+	RevisionEncoding string
+	// RevisionKeyframeInterval is how often a revision is kept as a full copy when using delta encoding.
+	RevisionKeyframeInterval int
+	// RevisionsStore is where revisions are stored, given in the same form as a workspace ID, such as
+	// "s3://bucket/prefix". By default, each provider keeps revisions in a "revisions" directory next to its workspaces.
+	RevisionsStore string
+	// Quotas are the quotas of the workspaces of each provider, by provider name. A quota set on a workspace replaces
+	// the limits of its provider's quota. Only the providers given here have quotas, so a quota without limits allows
+	// quotas to be set on single workspaces, and the usage of the workspaces of other providers isn't recorded.
+	Quotas map[string]Quota
 }
 
 func complete(opts ...Options) Options {
@@ -67,11 +107,29 @@ func complete(opts ...Options) Options {
 		if o.AzureConnectionString != "" {
 			opt.AzureConnectionString = o.AzureConnectionString
 		}
+		if o.RevisionEncoding != "" {
+			opt.RevisionEncoding = o.RevisionEncoding
+		}
+		if o.RevisionKeyframeInterval != 0 {
+			opt.RevisionKeyframeInterval = o.RevisionKeyframeInterval
+		}
+		if o.RevisionsStore != "" {
+			opt.RevisionsStore = o.RevisionsStore
+		}
+		if o.Quotas != nil {
+			opt.Quotas = o.Quotas
+		}
 	}
 
 	if opt.DirectoryDataHome == "" {
 		opt.DirectoryDataHome = filepath.Join(xdg.DataHome, "workspace-provider")
 	}
+	if opt.RevisionEncoding == "" {
+		opt.RevisionEncoding = RevisionEncodingFull
+	}
+	if opt.RevisionKeyframeInterval <= 0 {
+		opt.RevisionKeyframeInterval = defaultRevisionKeyframeInterval
+	}
 
 	return opt
 }
@@ -79,19 +137,38 @@ func complete(opts ...Options) Options {
 func New(ctx context.Context, opts ...Options) (*Client, error) {
 	opt := complete(opts...)
 
+	switch opt.RevisionEncoding {
+	case RevisionEncodingFull, RevisionEncodingDelta:
+	default:
+		return nil, fmt.Errorf("invalid revision encoding: %s", opt.RevisionEncoding)
+	}
+	for provider, quota := range opt.Quotas {
+		if err := quota.validate(); err != nil {
+			return nil, fmt.Errorf("invalid quota for provider %s: %w", provider, err)
+		}
+	}
+
+	var store revisionStore
+	if opt.RevisionsStore != "" {
+		var err error
+		if store, err = newRevisionStore(ctx, opt.RevisionsStore, opt); err != nil {
+			return nil, err
+		}
+	}
+
 	factories := map[string]workspaceFactory{
-		DirectoryProvider: newDirectory(opt.DirectoryDataHome),
+		DirectoryProvider: newDirectory(opt.DirectoryDataHome, store),
 	}
 
 	if opt.S3BucketName != "" {
-		factory, err := newS3(ctx, opt.S3BucketName, opt.S3BaseEndpoint, opt.S3UsePathStyle)
+		factory, err := newS3(ctx, opt.S3BucketName, opt.S3BaseEndpoint, opt.S3UsePathStyle, store)
 		if err != nil {
 			return nil, err
 		}
 		factories[S3Provider] = factory
 	}
 	if opt.AzureConnectionString != "" {
-		factory, err := newAzure(opt.AzureContainerName, opt.AzureConnectionString)
+		factory, err := newAzure(opt.AzureContainerName, opt.AzureConnectionString, store)
 		if err != nil {
 			return nil, err
 		}
@@ -99,22 +176,69 @@ func New(ctx context.Context, opts ...Options) (*Client, error) {
 	}
 
 	return &Client{
-		factories: factories,
+		factories:                factories,
+		revisionEncoding:         opt.RevisionEncoding,
+		revisionKeyframeInterval: int64(opt.RevisionKeyframeInterval),
+		quotas:                   opt.Quotas,
 	}, nil
 }
 
 type Client struct {
-	factories map[string]workspaceFactory
+	factories                map[string]workspaceFactory
+	revisionEncoding         string
+	revisionKeyframeInterval int64
+	quotas                   map[string]Quota
+}
+
+// deltaKeyframeInterval returns the keyframe interval that writes re-encode revisions with, or zero if revisions are
+// stored as full copies.
+func (c *Client) deltaKeyframeInterval() int64 {
+	if c.revisionEncoding != RevisionEncodingDelta {
+		return 0
+	}
+	return c.revisionKeyframeInterval
 }
 
 func (c *Client) Providers() []string {
 	return slices.Collect(maps.Keys(c.factories))
 }
 
+// Create creates a workspace, copying the files of the given workspaces into it along with their revisions.
 func (c *Client) Create(ctx context.Context, provider string, fromWorkspaces ...string) (string, error) {
+	return c.CreateWithOptions(ctx, provider, CreateOptions{FromWorkspaces: fromWorkspaces})
+}
+
+// CreateWithOptions creates a workspace like Create, and records the options in its manifest. The name is reserved
+// before anything is created, and if the workspace can't be created, then it is released along with whatever was.
+func (c *Client) CreateWithOptions(ctx context.Context, provider string, opts ...CreateOptions) (_ string, err error) {
+	opt := completeCreateOptions(opts...)
+	if err := validateLabels(opt.Labels); err != nil {
+		return "", err
+	}
+	if err := opt.Quota.validate(); err != nil {
+		return "", err
+	}
+	if opt.TTL != 0 {
+		if !opt.ExpiresAt.IsZero() {
+			return "", newInvalidArgumentError("expiry time and TTL are mutually exclusive")
+		}
+		if opt.TTL < 0 {
+			return "", newInvalidArgumentError("invalid TTL: %s", opt.TTL)
+		}
+		opt.ExpiresAt, opt.TTL = time.Now().Add(opt.TTL), 0
+	}
+	if opt.Name = strings.TrimPrefix(opt.Name, NamePrefix); opt.Name != "" {
+		if err := validateName(opt.Name); err != nil {
+			return "", err
+		}
+	}
+
 	if provider == "" {
 		provider = DirectoryProvider
 	}
+	if err := c.checkQuotasEnabled(provider, opt.Quota); err != nil {
+		return "", err
+	}
 
 	factory, err := c.getFactory(provider)
 	if err != nil {
@@ -122,13 +246,31 @@ func (c *Client) Create(ctx context.Context, provider string, fromWorkspaces ...
 	}
 
 	id := factory.Create()
+	if opt.Name != "" {
+		if err = c.registerName(ctx, factory, opt.Name, id); err != nil {
+			return "", err
+		}
+	}
+	defer func() {
+		if err != nil {
+			c.rollBackCreate(context.WithoutCancel(ctx), factory, id, opt.Name)
+		}
+	}()
+
 	destClient, err := factory.New(id)
 	if err != nil {
 		return "", err
 	}
 
-	for _, fromWorkspace := range fromWorkspaces {
-		sourceClient, err := c.getClient(fromWorkspace)
+	quota := c.quotas[provider].merge(opt.Quota)
+	if !quota.isZero() {
+		if err = c.checkCreateQuota(ctx, id, quota, opt.FromWorkspaces); err != nil {
+			return "", err
+		}
+	}
+
+	for _, fromWorkspace := range opt.FromWorkspaces {
+		sourceClient, _, err := c.getClient(ctx, fromWorkspace)
 		if err != nil {
 			return "", err
 		}
@@ -140,48 +282,356 @@ func (c *Client) Create(ctx context.Context, provider string, fromWorkspaces ...
 		}
 	}
 
+	if err = writeManifest(ctx, factory, WorkspaceInfo{
+		ID:             id,
+		Provider:       provider,
+		Name:           opt.Name,
+		CreatedAt:      time.Now(),
+		Labels:         opt.Labels,
+		Owner:          opt.Owner,
+		Description:    opt.Description,
+		FromWorkspaces: opt.FromWorkspaces,
+		ExpiresAt:      opt.ExpiresAt,
+	}); err != nil {
+		return "", err
+	}
+
+	if !quota.isZero() {
+		// The usage of copied files is computed when it is first needed.
+		if err = writeUsageRecord(ctx, factory, id, usageRecord{Quota: opt.Quota, Stale: len(opt.FromWorkspaces) > 0}); err != nil {
+			return "", err
+		}
+	}
+
 	return id, nil
 }
 
+// rollBackCreate removes what was created for a workspace that couldn't be, and releases its name. This is best
+// effort, like removing a workspace.
+func (c *Client) rollBackCreate(ctx context.Context, factory workspaceFactory, id, name string) {
+	_ = factory.Rm(ctx, id)
+	_ = deleteUsageRecord(ctx, factory, id)
+	_ = deleteManifest(ctx, factory, id)
+	if name != "" {
+		_ = unregisterName(ctx, factory, name, id)
+	}
+}
+
 func (c *Client) Rm(ctx context.Context, id string) error {
-	provider, _, ok := strings.Cut(id, "://")
-	if !ok {
-		return fmt.Errorf("invalid workspace id: %s", id)
+	f, id, err := c.getWorkspaceFactory(ctx, id)
+	if err != nil {
+		return err
 	}
 
-	f, err := c.getFactory(provider)
+	info, err := readManifest(ctx, f, id)
 	if err != nil {
 		return err
 	}
 
-	return f.Rm(ctx, id)
+	if err = f.Rm(ctx, id); err != nil {
+		return err
+	}
+
+	if info.Name != "" {
+		if err = unregisterName(ctx, f, info.Name, id); err != nil {
+			return err
+		}
+	}
+
+	if err = deleteUsageRecord(ctx, f, id); err != nil {
+		return err
+	}
+
+	// Best effort, the content staged by batches is only left behind if they were interrupted.
+	if sc, stageDir, err := stagingClient(f, id); err == nil {
+		_ = sc.RemoveAllWithPrefix(ctx, stageDir)
+	}
+
+	// Best effort, the entries only point to files that no longer exist.
+	if ec, expiryDir, err := expiryClient(f, id); err == nil {
+		_ = ec.RemoveAllWithPrefix(ctx, expiryDir)
+	}
+
+	return deleteManifest(ctx, f, id)
 }
 
-func (c *Client) Ls(ctx context.Context, id, prefix string) ([]string, error) {
-	wc, err := c.getClient(id)
+type LsOptions struct {
+	// AsOf lists the files that existed at the given time. Files that have since been deleted are not included.
+	AsOf time.Time
+	// Recursive lists the files in all subdirectories of the prefix, and is the default. When false, only the files
+	// immediately under the prefix are listed, along with its subdirectories, whose names end with "/".
+	Recursive *bool
+	// Include, if set, only lists the files that match one of these doublestar glob patterns, such as "**/*.md".
+	// Patterns are matched against the whole file name in the workspace, regardless of the prefix.
+	Include []string
+	// Exclude doesn't list the files that match any of these doublestar glob patterns.
+	Exclude []string
+	// Metadata, if set, only lists the files that have all of these metadata values. Directories are not listed.
+	Metadata map[string]string
+
+	// The following options are only used by LsWithInfo.
+
+	// MinSize and MaxSize, if set, only return files with a size in the given range, inclusive.
+	MinSize, MaxSize int64
+	// ModifiedAfter and ModifiedBefore, if set, only return files last modified in the given range.
+	ModifiedAfter, ModifiedBefore time.Time
+	// SortBy is one of LsSortByName (the default), LsSortBySize or LsSortByModTime.
+	SortBy string
+	// Descending reverses the sort order.
+	Descending bool
+
+	// The following options are only used by LsPage and LsIter.
+
+	// Limit is the maximum number of files in a page. The default is 1000, and larger limits than 5000 are reduced to
+	// 5000. Backends may return fewer files in a page, such as S3, which returns at most 1000.
+	Limit int
+	// Continuation lists the page after the one that returned it.
+	Continuation string
+}
+
+func (c *Client) Ls(ctx context.Context, id, prefix string, opts ...LsOptions) ([]string, error) {
+	opt := completeLsOptions(opts...)
+	if err := opt.validate(); err != nil {
+		return nil, err
+	}
+
+	if len(opt.Metadata) > 0 {
+		// Names are listed without metadata, so the files are listed with their info to filter them.
+		infos, err := c.LsWithInfo(ctx, id, prefix, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		files := make([]string, 0, len(infos))
+		for _, info := range infos {
+			files = append(files, info.Name)
+		}
+		return files, nil
+	}
+
+	factory, id, err := c.getWorkspaceFactory(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
-	return wc.Ls(ctx, prefix)
+	wc, err := factory.New(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if opt.Recursive != nil && !*opt.Recursive {
+		infos, err := lsDir(ctx, factory, wc, id, prefix, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		files := make([]string, 0, len(infos))
+		for _, info := range infos {
+			if matchesPatterns(info.Name, opt) && (!opt.AsOf.IsZero() || !isExpired(info.ExpiresAt)) {
+				files = append(files, info.Name)
+			}
+		}
+		return files, nil
+	}
+
+	files, err := wc.Ls(ctx, listPrefix(prefix, opt.Include))
+	if err != nil {
+		return nil, err
+	}
+
+	files = slices.DeleteFunc(files, func(file string) bool {
+		return !matchesPatterns(file, opt)
+	})
+
+	if !opt.AsOf.IsZero() {
+		return lsAsOf(ctx, wc, id, files, opt.AsOf)
+	}
+
+	// Names are listed without their expiry, so the expired files are found in the index.
+	expired, err := expiredFiles(ctx, factory, wc, id, listPrefix(prefix, opt.Include))
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(files, func(file string) bool {
+		_, ok := expired[file]
+		return ok
+	}), nil
+}
+
+// LsWithInfo lists the files in a workspace along with their size and modification time, as returned by the backend's
+// listing. Unlike StatFile, it does not detect the mime type of each file.
+func (c *Client) LsWithInfo(ctx context.Context, id, prefix string, opts ...LsOptions) ([]FileInfo, error) {
+	opt := completeLsOptions(opts...)
+	if err := opt.validate(); err != nil {
+		return nil, err
+	}
+
+	factory, id, err := c.getWorkspaceFactory(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	wc, err := factory.New(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if opt.Recursive != nil && !*opt.Recursive {
+		files, err := lsDir(ctx, factory, wc, id, prefix, opt)
+		if err != nil {
+			return nil, err
+		}
+		return sortFileInfos(filterFileInfos(files, opt), opt), nil
+	}
+
+	files, err := wc.LsWithInfo(ctx, listPrefix(prefix, opt.Include))
+	if err != nil {
+		return nil, err
+	}
+	if files, err = withIndexedExpiry(ctx, factory, wc, id, listPrefix(prefix, opt.Include), files, opt); err != nil {
+		return nil, err
+	}
+
+	if !opt.AsOf.IsZero() {
+		if files, err = lsWithInfoAsOf(ctx, wc, id, files, opt.AsOf); err != nil {
+			return nil, err
+		}
+	}
+	if files, err = fetchMetadata(ctx, wc, files, opt); err != nil {
+		return nil, err
+	}
+
+	return sortFileInfos(filterFileInfos(files, opt), opt), nil
+}
+
+// LsPage lists a page of the files in a workspace, along with a continuation to list the next page. Files are listed in
+// the backend's order instead of being sorted, and only recursive listings can be paged. The AsOf and filter options
+// are applied to each page, so a page can have fewer files than the limit even if it isn't the last one.
+func (c *Client) LsPage(ctx context.Context, id, prefix string, opts ...LsOptions) (LsPage, error) {
+	opt := completeLsOptions(opts...)
+	if err := opt.validate(); err != nil {
+		return LsPage{}, err
+	}
+	if opt.Recursive != nil && !*opt.Recursive {
+		return LsPage{}, fmt.Errorf("non-recursive listings cannot be paged")
+	}
+
+	factory, id, err := c.getWorkspaceFactory(ctx, id)
+	if err != nil {
+		return LsPage{}, err
+	}
+
+	wc, err := factory.New(id)
+	if err != nil {
+		return LsPage{}, err
+	}
+
+	return lsPage(ctx, factory, wc, id, prefix, opt)
+}
+
+// LsIter iterates over the files in a workspace, listing them a page at a time so that large workspaces aren't held in
+// memory. Like LsPage, files are listed in the backend's order. Iteration stops after the first error.
+func (c *Client) LsIter(ctx context.Context, id, prefix string, opts ...LsOptions) iter.Seq2[FileInfo, error] {
+	return func(yield func(FileInfo, error) bool) {
+		opt := completeLsOptions(opts...)
+		if err := opt.validate(); err != nil {
+			yield(FileInfo{}, err)
+			return
+		}
+
+		factory, id, err := c.getWorkspaceFactory(ctx, id)
+		if err != nil {
+			yield(FileInfo{}, err)
+			return
+		}
+
+		wc, err := factory.New(id)
+		if err != nil {
+			yield(FileInfo{}, err)
+			return
+		}
+
+		if opt.Recursive != nil && !*opt.Recursive {
+			files, err := lsDir(ctx, factory, wc, id, prefix, opt)
+			if err != nil {
+				yield(FileInfo{}, err)
+				return
+			}
+
+			for _, file := range filterFileInfos(files, opt) {
+				if !yield(file, nil) {
+					return
+				}
+			}
+			return
+		}
+
+		for {
+			page, err := lsPage(ctx, factory, wc, id, prefix, opt)
+			if err != nil {
+				yield(FileInfo{}, err)
+				return
+			}
+
+			for _, file := range page.Files {
+				if !yield(file, nil) {
+					return
+				}
+			}
+
+			if page.Continuation == "" {
+				return
+			}
+			opt.Continuation = page.Continuation
+		}
+	}
 }
 
 func (c *Client) DeleteFile(ctx context.Context, id, file string) error {
-	wc, err := c.getClient(id)
+	wc, id, err := c.getClient(ctx, id)
 	if err != nil {
 		return err
 	}
 
+	a, err := c.loadAccount(ctx, id, wc)
+	if err != nil {
+		return err
+	}
+	if a != nil {
+		return a.deleteFile(ctx, file)
+	}
+
 	return wc.DeleteFile(ctx, file)
 }
 
 type OpenOptions struct {
 	WithLatestRevisionID bool
+	// AsOf opens the content of the file as it was at the given time. Content that was overwritten without creating a
+	// revision can't be read, so reads as of the time it was current return the content written before it.
+	AsOf time.Time
+	// Offset is the byte offset to start reading from. Reading from beyond the end of the file returns no content.
+	Offset int64
+	// Length is the maximum number of bytes to read. Zero reads to the end of the file.
+	Length int64
+	// VerifyChecksum verifies the content against the checksum stored when the file was written, and returns a
+	// ChecksumMismatchError from the last read if it doesn't match. Ranges and files without a checksum aren't verified.
+	VerifyChecksum bool
 }
 
 type File struct {
 	io.ReadCloser
 	RevisionID string
+	// Size is the total size of the file. It is only set when a range is requested, and is -1 if it is not known.
+	Size int64
+	// Checksum is the hex encoded SHA-256 checksum of the whole file, if it was stored when the file was written.
+	Checksum string
+	// Metadata is the user-defined metadata of the file.
+	Metadata map[string]string
+	// ExpiresAt is when the file expires, if it was written with an expiry.
+	ExpiresAt time.Time
+
+	// etag is the entity tag of the content that was opened, for backends that have one.
+	etag string
 }
 
 func (f *File) GetRevisionID() (string, error) {
@@ -196,14 +646,43 @@ func (c *Client) OpenFile(ctx context.Context, id, fileName string, opts ...Open
 	var opt OpenOptions
 	for _, o := range opts {
 		opt.WithLatestRevisionID = opt.WithLatestRevisionID || o.WithLatestRevisionID
+		if !o.AsOf.IsZero() {
+			opt.AsOf = o.AsOf
+		}
+		if o.Offset != 0 {
+			opt.Offset = o.Offset
+		}
+		if o.Length != 0 {
+			opt.Length = o.Length
+		}
+		opt.VerifyChecksum = opt.VerifyChecksum || o.VerifyChecksum
+	}
+
+	if opt.Offset < 0 || opt.Length < 0 {
+		return nil, fmt.Errorf("invalid range: offset %d, length %d", opt.Offset, opt.Length)
 	}
 
-	wc, err := c.getClient(id)
+	wc, id, err := c.getClient(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
-	return wc.OpenFile(ctx, fileName, opt)
+	var file *File
+	if !opt.AsOf.IsZero() {
+		file, err = openFileAsOf(ctx, wc, id, fileName, opt)
+	} else {
+		file, err = wc.OpenFile(ctx, fileName, opt)
+		if err == nil && isExpired(file.ExpiresAt) {
+			_ = file.Close()
+			return nil, newNotFoundError(id, fileName)
+		}
+	}
+	if err != nil || !opt.VerifyChecksum || opt.hasRange() || file.Checksum == "" {
+		return file, err
+	}
+
+	file.ReadCloser = newChecksumReader(file.ReadCloser, id, fileName, file.Checksum)
+	return file, nil
 }
 
 type WriteOptions struct {
@@ -212,6 +691,29 @@ type WriteOptions struct {
 	LatestRevisionID string
 	// IfNotExists will only write if the file does not exist. Mutually exclusive with LatestRevisionID.
 	IfNotExists bool
+	// Checksum, if set, is the expected hex encoded SHA-256 checksum of the content. If the content doesn't match, then a
+	// ChecksumMismatchError is returned and the file is not written.
+	Checksum string
+	// ContentType is the mime type of the content. If it isn't set, then it is detected from the content.
+	ContentType string
+	// Metadata is user-defined metadata stored with the file, such as its source. Keys must be lowercase letters, digits
+	// and underscores. Writing a file replaces its metadata.
+	Metadata map[string]string
+	// ExpiresAt is when the file expires. Once it has, the file is hidden, and it is removed along with its revisions by
+	// SweepExpired. Writing a file replaces its expiry, while appending, copying and moving keep it.
+	ExpiresAt time.Time
+	// TTL sets ExpiresAt to this long after the write. It is mutually exclusive with ExpiresAt.
+	TTL time.Duration
+
+	// keyframeInterval, if set, re-encodes the previous revision as a delta when the revision of the write is recorded,
+	// keeping every revision that is a multiple of it as a full copy.
+	keyframeInterval int64
+	// exclusive creates the file only if it doesn't exist, atomically in the backend, and returns a FileExistsError
+	// otherwise. It is used for the records that coordinate clients, which don't have revisions.
+	exclusive bool
+	// ifMatch, if set, only replaces the file if its entity tag matches, and returns a ConflictError otherwise. It is
+	// used by backends that append by rewriting the file.
+	ifMatch string
 }
 
 func (c *Client) WriteFile(ctx context.Context, id, fileName string, reader io.Reader, opts ...WriteOptions) error {
@@ -226,17 +728,76 @@ func (c *Client) WriteFile(ctx context.Context, id, fileName string, reader io.R
 		if o.IfNotExists {
 			opt.IfNotExists = o.IfNotExists
 		}
+		if o.Checksum != "" {
+			opt.Checksum = o.Checksum
+		}
+		if o.ContentType != "" {
+			opt.ContentType = o.ContentType
+		}
+		if o.Metadata != nil {
+			opt.Metadata = o.Metadata
+		}
+		if !o.ExpiresAt.IsZero() {
+			opt.ExpiresAt = o.ExpiresAt
+		}
+		if o.TTL != 0 {
+			opt.TTL = o.TTL
+		}
 	}
 	if opt.IfNotExists {
 		opt.LatestRevisionID = "-1"
 	}
+	opt.keyframeInterval = c.deltaKeyframeInterval()
+	if err := validateMetadata(opt.Metadata); err != nil {
+		return err
+	}
+	if opt.TTL != 0 {
+		if !opt.ExpiresAt.IsZero() {
+			return newInvalidArgumentError("expiry time and TTL are mutually exclusive")
+		}
+		if opt.TTL < 0 {
+			return newInvalidArgumentError("invalid TTL: %s", opt.TTL)
+		}
+		opt.ExpiresAt, opt.TTL = time.Now().Add(opt.TTL), 0
+	}
 
-	wc, err := c.getClient(id)
+	factory, id, err := c.getWorkspaceFactory(ctx, id)
 	if err != nil {
 		return err
 	}
 
-	err = wc.WriteFile(ctx, fileName, reader, opt)
+	wc, err := factory.New(id)
+	if err != nil {
+		return err
+	}
+
+	if !opt.ExpiresAt.IsZero() {
+		if err = indexExpiry(ctx, factory, id, fileName, opt.ExpiresAt); err != nil {
+			return err
+		}
+	}
+
+	if opt.IfNotExists {
+		// An expired file doesn't exist as far as callers can tell.
+		if err = c.removeIfExpired(ctx, wc, id, fileName); err != nil {
+			return err
+		}
+	}
+
+	a, err := c.loadAccount(ctx, id, wc)
+	if err != nil {
+		return err
+	}
+	if a == nil {
+		err = wc.WriteFile(ctx, fileName, reader, opt)
+	} else {
+		var ch fileChange
+		if ch, err = a.change(ctx, fileName, false, opt.CreateRevision == nil || *opt.CreateRevision); err == nil {
+			err = a.write(ctx, ch, reader, func(r io.Reader) error {
+				return wc.WriteFile(ctx, fileName, r, opt)
+			})
+		}
+	}
 	if ce := (*ConflictError)(nil); err != nil && errors.As(err, &ce) && opt.IfNotExists {
 		err = &[]FileExistsError{FileExistsError(*ce)}[0]
 	}
@@ -244,44 +805,305 @@ func (c *Client) WriteFile(ctx context.Context, id, fileName string, reader io.R
 	return err
 }
 
+type AppendOptions struct {
+	// CreateRevision can be set to false to append without storing the previous content of the file as a revision.
+	CreateRevision *bool
+	// If LatestRevisionID is set, then a conflict error will be returned if that revision is not the latest.
+	LatestRevisionID string
+}
+
+// AppendFile appends to a file, creating it if it does not exist. Where the backend supports it, the existing content
+// is not rewritten.
+func (c *Client) AppendFile(ctx context.Context, id, fileName string, reader io.Reader, opts ...AppendOptions) error {
+	var opt WriteOptions
+	for _, o := range opts {
+		if o.CreateRevision != nil {
+			opt.CreateRevision = o.CreateRevision
+		}
+		if o.LatestRevisionID != "" {
+			opt.LatestRevisionID = o.LatestRevisionID
+		}
+	}
+	opt.keyframeInterval = c.deltaKeyframeInterval()
+
+	wc, id, err := c.getClient(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	// Appending to an expired file starts a new file.
+	if err = c.removeIfExpired(ctx, wc, id, fileName); err != nil {
+		return err
+	}
+
+	a, err := c.loadAccount(ctx, id, wc)
+	if err != nil {
+		return err
+	}
+	if a == nil {
+		err = wc.AppendFile(ctx, fileName, reader, opt)
+	} else {
+		var ch fileChange
+		if ch, err = a.change(ctx, fileName, true, opt.CreateRevision == nil || *opt.CreateRevision); err == nil {
+			err = a.write(ctx, ch, reader, func(r io.Reader) error {
+				return wc.AppendFile(ctx, fileName, r, opt)
+			})
+		}
+	}
+	return err
+}
+
+type CopyOptions struct {
+	CreateRevision *bool
+	// If LatestRevisionID is set, then a conflict error will be returned if that revision is not the latest of the
+	// destination.
+	LatestRevisionID string
+	// IfNotExists will only copy if the destination does not exist. Mutually exclusive with LatestRevisionID.
+	IfNotExists bool
+}
+
+// CopyFile copies a file to another file in the same or a different workspace, recording a revision of the
+// destination as WriteFile does. When both workspaces are on the same backend, the copy is done by the backend without
+// streaming the content through this process.
+func (c *Client) CopyFile(ctx context.Context, srcID, srcFile, dstID, dstFile string, opts ...CopyOptions) error {
+	var opt WriteOptions
+	for _, o := range opts {
+		if o.CreateRevision != nil {
+			opt.CreateRevision = o.CreateRevision
+		}
+		if o.LatestRevisionID != "" {
+			opt.LatestRevisionID = o.LatestRevisionID
+		}
+		if o.IfNotExists {
+			opt.IfNotExists = o.IfNotExists
+		}
+	}
+	if opt.IfNotExists {
+		opt.LatestRevisionID = "-1"
+	}
+	opt.keyframeInterval = c.deltaKeyframeInterval()
+
+	source, srcID, err := c.getClient(ctx, srcID)
+	if err != nil {
+		return err
+	}
+
+	factory, dstID, err := c.getWorkspaceFactory(ctx, dstID)
+	if err != nil {
+		return err
+	}
+
+	dest, err := factory.New(dstID)
+	if err != nil {
+		return err
+	}
+
+	a, err := c.loadAccount(ctx, dstID, dest)
+	if err != nil {
+		return err
+	}
+	if a == nil {
+		err = source.CopyFile(ctx, srcFile, dest, dstFile, opt)
+	} else {
+		err = a.copyFile(ctx, source, srcFile, dstFile, opt)
+	}
+	if ce := (*ConflictError)(nil); err != nil && errors.As(err, &ce) && opt.IfNotExists {
+		err = &[]FileExistsError{FileExistsError(*ce)}[0]
+	}
+	if err != nil {
+		return err
+	}
+
+	// The copy keeps the expiry of the file.
+	return indexCopiedExpiry(ctx, factory, dest, dstID, dstFile)
+}
+
+type MoveOptions struct {
+	// If LatestRevisionID is set, then a conflict error will be returned if that revision is not the latest of the source.
+	LatestRevisionID string
+	// If DestinationLatestRevisionID is set, then a conflict error will be returned if that revision is not the latest
+	// of the destination.
+	DestinationLatestRevisionID string
+	// IfNotExists will only move if the destination does not exist. Mutually exclusive with DestinationLatestRevisionID.
+	IfNotExists bool
+}
+
+// Move renames a file within a workspace, carrying its revisions with it. If the destination exists, then it is
+// replaced and its revisions are deleted.
+func (c *Client) Move(ctx context.Context, id, from, to string, opts ...MoveOptions) error {
+	var opt MoveOptions
+	for _, o := range opts {
+		if o.LatestRevisionID != "" {
+			opt.LatestRevisionID = o.LatestRevisionID
+		}
+		if o.DestinationLatestRevisionID != "" {
+			opt.DestinationLatestRevisionID = o.DestinationLatestRevisionID
+		}
+		if o.IfNotExists {
+			opt.IfNotExists = o.IfNotExists
+		}
+	}
+
+	factory, id, err := c.getWorkspaceFactory(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	wc, err := factory.New(id)
+	if err != nil {
+		return err
+	}
+	// The destination may be replaced and its revisions deleted.
+	defer c.markUsageStale(ctx, id)
+
+	if err = wc.MoveFile(ctx, from, to, opt); err != nil {
+		return err
+	}
+
+	// The moved file keeps its expiry.
+	return indexCopiedExpiry(ctx, factory, wc, id, to)
+}
+
 type StatOptions struct {
 	WithLatestRevisionID bool
+	// AsOf returns information about the file as it was at the given time.
+	AsOf time.Time
+	// withDirs returns the directory with the name if there isn't a file. Object stores take an extra request to check
+	// for one, so it is only set where a directory may be expected.
+	withDirs bool
 }
 
 func (c *Client) StatFile(ctx context.Context, id, fileName string, opts ...StatOptions) (FileInfo, error) {
 	var opt StatOptions
 	for _, o := range opts {
 		opt.WithLatestRevisionID = opt.WithLatestRevisionID || o.WithLatestRevisionID
+		if !o.AsOf.IsZero() {
+			opt.AsOf = o.AsOf
+		}
 	}
+	opt.withDirs = true
 
-	wc, err := c.getClient(id)
+	wc, id, err := c.getClient(ctx, id)
 	if err != nil {
 		return FileInfo{}, err
 	}
 
-	return wc.StatFile(ctx, fileName, opt)
+	// A trailing slash only matches a directory.
+	if dirName, ok := strings.CutSuffix(fileName, "/"); ok && dirName != "" {
+		info, err := wc.StatFile(ctx, dirName, StatOptions{withDirs: true})
+		if err == nil && !info.IsDir {
+			return FileInfo{}, newNotFoundError(id, fileName)
+		}
+		return info, err
+	}
+
+	if !opt.AsOf.IsZero() {
+		return statFileAsOf(ctx, wc, id, fileName, opt)
+	}
+
+	info, err := wc.StatFile(ctx, fileName, opt)
+	if err == nil && isExpired(info.ExpiresAt) {
+		return FileInfo{}, newNotFoundError(id, fileName)
+	}
+	return info, err
 }
 
 func (c *Client) RemoveAllWithPrefix(ctx context.Context, id, prefix string) error {
-	wc, err := c.getClient(id)
+	wc, id, err := c.getClient(ctx, id)
 	if err != nil {
 		return err
 	}
+	defer c.markUsageStale(ctx, id)
 
 	return wc.RemoveAllWithPrefix(ctx, prefix)
 }
 
-func (c *Client) ListRevisions(ctx context.Context, id, fileName string) ([]RevisionInfo, error) {
-	wc, err := c.getClient(id)
+type RemoveMatchingOptions struct {
+	// Include removes the files that match any of these doublestar glob patterns, such as "tmp/**/*.log". At least one
+	// pattern is required.
+	Include []string
+	// Exclude keeps the files that match any of these doublestar glob patterns.
+	Exclude []string
+	// DryRun returns the files that would be removed without removing them.
+	DryRun bool
+}
+
+// RemoveMatching removes the files in a workspace that match the patterns, along with their revisions, and returns
+// their names. The files are all listed before any are removed, so a failure part way leaves the rest in place.
+func (c *Client) RemoveMatching(ctx context.Context, id string, opts ...RemoveMatchingOptions) ([]string, error) {
+	var opt RemoveMatchingOptions
+	for _, o := range opts {
+		opt.Include = append(opt.Include, o.Include...)
+		opt.Exclude = append(opt.Exclude, o.Exclude...)
+		opt.DryRun = opt.DryRun || o.DryRun
+	}
+
+	if len(opt.Include) == 0 {
+		return nil, fmt.Errorf("at least one pattern is required to remove matching files")
+	}
+
+	files, err := c.Ls(ctx, id, "", LsOptions{Include: opt.Include, Exclude: opt.Exclude})
+	if err != nil || opt.DryRun {
+		return files, err
+	}
+
+	wc, id, err := c.getClient(ctx, id)
 	if err != nil {
 		return nil, err
 	}
+	defer c.markUsageStale(ctx, id)
+
+	for i, file := range files {
+		if err = wc.DeleteFile(ctx, file); err != nil {
+			return files[:i], fmt.Errorf("failed to remove %s: %w", file, err)
+		}
+	}
 
-	return wc.ListRevisions(ctx, fileName)
+	return files, nil
+}
+
+type ListRevisionsOptions struct {
+	// Limit is the maximum number of revisions to return. Zero means no limit.
+	Limit int
+	// Cursor is the ID of the last revision from a previous page. Only revisions after it, in the requested order, are returned.
+	Cursor string
+	// NewestFirst returns revisions in descending order of revision ID.
+	NewestFirst bool
+}
+
+func (c *Client) ListRevisions(ctx context.Context, id, fileName string, opts ...ListRevisionsOptions) ([]RevisionInfo, error) {
+	var opt ListRevisionsOptions
+	for _, o := range opts {
+		if o.Limit != 0 {
+			opt.Limit = o.Limit
+		}
+		if o.Cursor != "" {
+			opt.Cursor = o.Cursor
+		}
+		opt.NewestFirst = opt.NewestFirst || o.NewestFirst
+	}
+	if opt.Limit < 0 {
+		return nil, newInvalidArgumentError("invalid limit: %d", opt.Limit)
+	}
+	if _, ok := parseRevisionID(opt.Cursor); opt.Cursor != "" && !ok {
+		return nil, newInvalidArgumentError("invalid revision cursor: %s", opt.Cursor)
+	}
+
+	wc, id, err := c.getClient(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions, err := wc.ListRevisions(ctx, fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	return paginateRevisions(revisions, opt)
 }
 
 func (c *Client) GetRevision(ctx context.Context, id, fileName, revision string) (*File, error) {
-	wc, err := c.getClient(id)
+	wc, id, err := c.getClient(ctx, id)
 	if err != nil {
 		return nil, err
 	}
@@ -290,26 +1112,34 @@ func (c *Client) GetRevision(ctx context.Context, id, fileName, revision string)
 }
 
 func (c *Client) DeleteRevision(ctx context.Context, id, fileName, revision string) error {
-	wc, err := c.getClient(id)
+	wc, id, err := c.getClient(ctx, id)
 	if err != nil {
 		return err
 	}
+	defer c.markUsageStale(ctx, id)
 
 	return wc.DeleteRevision(ctx, fileName, revision)
 }
 
-func (c *Client) getClient(id string) (workspaceClient, error) {
-	provider, _, ok := strings.Cut(id, "://")
-	if !ok {
-		return nil, fmt.Errorf("invalid workspace id: %s", id)
+// Blame attributes each line of a text file to the earliest surviving revision that contains it.
+func (c *Client) Blame(ctx context.Context, id, fileName string) ([]BlameLine, error) {
+	wc, id, err := c.getClient(ctx, id)
+	if err != nil {
+		return nil, err
 	}
 
-	f, err := c.getFactory(provider)
+	return blame(ctx, wc, fileName)
+}
+
+// getClient returns the client of a workspace, along with its ID, which is resolved if the workspace was given by name.
+func (c *Client) getClient(ctx context.Context, id string) (workspaceClient, string, error) {
+	f, id, err := c.getWorkspaceFactory(ctx, id)
 	if err != nil {
-		return nil, err
+		return nil, id, err
 	}
 
-	return f.New(id)
+	wc, err := f.New(id)
+	return wc, id, err
 }
 
 func (c *Client) getFactory(provider string) (workspaceFactory, error) {
@@ -346,11 +1176,5 @@ func cp(ctx context.Context, source, dest workspaceClient) error {
 }
 
 func cpFile(ctx context.Context, entry string, source, dest workspaceClient) error {
-	sourceFile, err := source.OpenFile(ctx, entry, OpenOptions{})
-	if err != nil {
-		return err
-	}
-	defer sourceFile.Close()
-
-	return dest.WriteFile(ctx, entry, sourceFile, WriteOptions{})
+	return source.CopyFile(ctx, entry, dest, entry, WriteOptions{})
 }
@@ -3,10 +3,17 @@ package client
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"math"
 	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
@@ -20,6 +27,9 @@ var c, _ = New(context.Background(), Options{
 	AzureConnectionString: os.Getenv("WORKSPACE_PROVIDER_AZURE_CONNECTION_STRING"),
 })
 
+// quotaClient has quotas for the directory provider, without limits of its own, so that they can be set on workspaces.
+var quotaClient, _ = New(context.Background(), Options{Quotas: map[string]Quota{DirectoryProvider: {}}})
+
 func TestProviders(t *testing.T) {
 	providers := c.Providers()
 
@@ -1360,3 +1370,2513 @@ func TestStatFileS3Provider(t *testing.T) {
 		t.Errorf("unexpected revision id: %s", rev)
 	}
 }
+
+func TestListRevisionsPaginationDirectoryProvider(t *testing.T) {
+	id, err := c.Create(context.Background(), DirectoryProvider)
+	if err != nil {
+		t.Errorf("error creating workspace: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := c.Rm(context.Background(), id); err != nil {
+			t.Errorf("unexpected error when removing workspace: %v", err)
+		}
+	})
+
+	// Write the file five times to create four revisions
+	for i := range 5 {
+		if err = c.WriteFile(context.Background(), id, "test.txt", strings.NewReader(strings.Repeat("a", i+1))); err != nil {
+			t.Fatalf("error getting file to write: %v", err)
+		}
+	}
+
+	revisionIDs := func(revisions []RevisionInfo) []string {
+		ids := make([]string, 0, len(revisions))
+		for _, r := range revisions {
+			ids = append(ids, r.RevisionID)
+		}
+		return ids
+	}
+
+	revisions, err := c.ListRevisions(context.Background(), id, "test.txt")
+	if err != nil {
+		t.Errorf("unexpected error when listing revisions: %v", err)
+	}
+	if ids := revisionIDs(revisions); !reflect.DeepEqual(ids, []string{"1", "2", "3", "4"}) {
+		t.Errorf("unexpected revisions: %v", ids)
+	}
+	if len(revisions) == 4 && revisions[3].Size != 4 {
+		t.Errorf("unexpected size: %d", revisions[3].Size)
+	}
+
+	// Page through the revisions, oldest first
+	revisions, err = c.ListRevisions(context.Background(), id, "test.txt", ListRevisionsOptions{Limit: 3})
+	if err != nil {
+		t.Errorf("unexpected error when listing revisions: %v", err)
+	}
+	if ids := revisionIDs(revisions); !reflect.DeepEqual(ids, []string{"1", "2", "3"}) {
+		t.Errorf("unexpected revisions: %v", ids)
+	}
+
+	revisions, err = c.ListRevisions(context.Background(), id, "test.txt", ListRevisionsOptions{Limit: 3, Cursor: "3"})
+	if err != nil {
+		t.Errorf("unexpected error when listing revisions: %v", err)
+	}
+	if ids := revisionIDs(revisions); !reflect.DeepEqual(ids, []string{"4"}) {
+		t.Errorf("unexpected revisions: %v", ids)
+	}
+
+	// Page through the revisions, newest first
+	revisions, err = c.ListRevisions(context.Background(), id, "test.txt", ListRevisionsOptions{Limit: 2, NewestFirst: true})
+	if err != nil {
+		t.Errorf("unexpected error when listing revisions: %v", err)
+	}
+	if ids := revisionIDs(revisions); !reflect.DeepEqual(ids, []string{"4", "3"}) {
+		t.Errorf("unexpected revisions: %v", ids)
+	}
+
+	revisions, err = c.ListRevisions(context.Background(), id, "test.txt", ListRevisionsOptions{Limit: 2, Cursor: "3", NewestFirst: true})
+	if err != nil {
+		t.Errorf("unexpected error when listing revisions: %v", err)
+	}
+	if ids := revisionIDs(revisions); !reflect.DeepEqual(ids, []string{"2", "1"}) {
+		t.Errorf("unexpected revisions: %v", ids)
+	}
+
+	// Deleted revisions are skipped
+	if err = c.DeleteRevision(context.Background(), id, "test.txt", "2"); err != nil {
+		t.Errorf("unexpected error when deleting revision: %v", err)
+	}
+
+	revisions, err = c.ListRevisions(context.Background(), id, "test.txt", ListRevisionsOptions{Cursor: "1"})
+	if err != nil {
+		t.Errorf("unexpected error when listing revisions: %v", err)
+	}
+	if ids := revisionIDs(revisions); !reflect.DeepEqual(ids, []string{"3", "4"}) {
+		t.Errorf("unexpected revisions: %v", ids)
+	}
+
+	if _, err = c.ListRevisions(context.Background(), id, "test.txt", ListRevisionsOptions{Cursor: "latest"}); err == nil {
+		t.Errorf("expected error when listing revisions with an invalid cursor")
+	} else if iae := (*InvalidArgumentError)(nil); !errors.As(err, &iae) {
+		t.Errorf("expected invalid argument error, got: %v", err)
+	}
+	if _, err = c.ListRevisions(context.Background(), id, "test.txt", ListRevisionsOptions{Limit: -1}); err == nil {
+		t.Errorf("expected error when listing revisions with a negative limit")
+	} else if iae := (*InvalidArgumentError)(nil); !errors.As(err, &iae) {
+		t.Errorf("expected invalid argument error, got: %v", err)
+	}
+}
+
+func TestDeltaRevisionsDirectoryProvider(t *testing.T) {
+	deltaClient, err := New(context.Background(), Options{RevisionEncoding: RevisionEncodingDelta, RevisionKeyframeInterval: 3})
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+
+	id, err := deltaClient.Create(context.Background(), DirectoryProvider)
+	if err != nil {
+		t.Errorf("error creating workspace: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := deltaClient.Rm(context.Background(), id); err != nil {
+			t.Errorf("unexpected error when removing workspace: %v", err)
+		}
+	})
+
+	// Append to a file, creating a revision each time
+	var (
+		content  string
+		contents []string
+	)
+	for i := range 6 {
+		content += strings.Repeat(fmt.Sprintf("line %d of a log file that keeps growing\n", i), 10)
+		contents = append(contents, content)
+		if err = deltaClient.WriteFile(context.Background(), id, "log.txt", strings.NewReader(content)); err != nil {
+			t.Fatalf("error getting file to write: %v", err)
+		}
+	}
+
+	revisions, err := deltaClient.ListRevisions(context.Background(), id, "log.txt")
+	if err != nil {
+		t.Fatalf("unexpected error when listing revisions: %v", err)
+	}
+	if len(revisions) != 5 {
+		t.Fatalf("unexpected number of revisions: %d", len(revisions))
+	}
+
+	for i, rev := range revisions {
+		if rev.Size != int64(len(contents[i])) {
+			t.Errorf("unexpected size for revision %s: %d", rev.RevisionID, rev.Size)
+		}
+
+		switch rev.RevisionID {
+		case "3", "5":
+			// Keyframes and the latest revision are stored in full
+			if rev.StoredSize != rev.Size || rev.ReconstructionCost != 0 {
+				t.Errorf("expected revision %s to be stored in full: %+v", rev.RevisionID, rev)
+			}
+		default:
+			if rev.StoredSize >= rev.Size || rev.ReconstructionCost == 0 {
+				t.Errorf("expected revision %s to be stored as a delta: %+v", rev.RevisionID, rev)
+			}
+		}
+
+		readRev, err := deltaClient.GetRevision(context.Background(), id, "log.txt", rev.RevisionID)
+		if err != nil {
+			t.Errorf("unexpected error when getting revision: %v", err)
+			continue
+		}
+
+		b, err := io.ReadAll(readRev)
+		if err != nil {
+			t.Errorf("unexpected error when reading revision: %v", err)
+		}
+		_ = readRev.Close()
+
+		if string(b) != contents[i] {
+			t.Errorf("unexpected content for revision %s", rev.RevisionID)
+		}
+	}
+
+	if revisions[0].ReconstructionCost != 2 {
+		t.Errorf("unexpected reconstruction cost for revision 1: %d", revisions[0].ReconstructionCost)
+	}
+
+	// Deleting the base of a delta keeps the delta readable
+	if err = deltaClient.DeleteRevision(context.Background(), id, "log.txt", "2"); err != nil {
+		t.Errorf("unexpected error when deleting revision: %v", err)
+	}
+
+	readRev, err := deltaClient.GetRevision(context.Background(), id, "log.txt", "1")
+	if err != nil {
+		t.Fatalf("unexpected error when getting revision: %v", err)
+	}
+
+	b, err := io.ReadAll(readRev)
+	if err != nil {
+		t.Errorf("unexpected error when reading revision: %v", err)
+	}
+	_ = readRev.Close()
+
+	if string(b) != contents[0] {
+		t.Errorf("unexpected content for revision 1 after deleting its base")
+	}
+
+	// Deleting the file removes every revision, including deltas
+	if err = deltaClient.DeleteFile(context.Background(), id, "log.txt"); err != nil {
+		t.Errorf("unexpected error when deleting file: %v", err)
+	}
+
+	wc, _, err := deltaClient.getClient(context.Background(), id)
+	if err != nil {
+		t.Fatalf("unexpected error getting workspace client: %v", err)
+	}
+
+	if files, err := wc.RevisionClient().Ls(context.Background(), ""); err != nil {
+		t.Errorf("unexpected error when listing revision files: %v", err)
+	} else if len(files) != 0 {
+		t.Errorf("unexpected revision files remaining: %v", files)
+	}
+}
+
+func TestRestoreFullRevisionDirectoryProvider(t *testing.T) {
+	deltaClient, err := New(context.Background(), Options{RevisionEncoding: RevisionEncodingDelta})
+	if err != nil {
+		t.Fatalf("error creating client: %v", err)
+	}
+
+	id, err := deltaClient.Create(context.Background(), DirectoryProvider)
+	if err != nil {
+		t.Errorf("error creating workspace: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := deltaClient.Rm(context.Background(), id); err != nil {
+			t.Errorf("unexpected error when removing workspace: %v", err)
+		}
+	})
+
+	// Revision 1 is stored as a delta against revision 2
+	var contents []string
+	for i := range 3 {
+		contents = append(contents, strings.Repeat(fmt.Sprintf("line %d of a log file that keeps growing\n", i), 10*(i+1)))
+		if err = deltaClient.WriteFile(context.Background(), id, "log.txt", strings.NewReader(contents[i])); err != nil {
+			t.Fatalf("error getting file to write: %v", err)
+		}
+	}
+
+	wc, _, err := deltaClient.getClient(context.Background(), id)
+	if err != nil {
+		t.Fatalf("unexpected error getting workspace client: %v", err)
+	}
+
+	if err = restoreFullRevision(context.Background(), wc.RevisionClient(), "log.txt", 1); err != nil {
+		t.Fatalf("unexpected error when restoring revision: %v", err)
+	}
+
+	revisions, err := deltaClient.ListRevisions(context.Background(), id, "log.txt")
+	if err != nil {
+		t.Fatalf("unexpected error when listing revisions: %v", err)
+	}
+	if len(revisions) != 2 {
+		t.Fatalf("unexpected number of revisions: %d", len(revisions))
+	}
+	if revisions[0].StoredSize != revisions[0].Size || revisions[0].ReconstructionCost != 0 {
+		t.Errorf("expected revision 1 to be stored in full: %+v", revisions[0])
+	}
+
+	// The restored revision no longer depends on its former base
+	if err = deltaClient.DeleteRevision(context.Background(), id, "log.txt", "2"); err != nil {
+		t.Fatalf("unexpected error when deleting revision: %v", err)
+	}
+
+	readRev, err := deltaClient.GetRevision(context.Background(), id, "log.txt", "1")
+	if err != nil {
+		t.Fatalf("unexpected error when getting revision: %v", err)
+	}
+
+	b, err := io.ReadAll(readRev)
+	if err != nil {
+		t.Errorf("unexpected error when reading revision: %v", err)
+	}
+	_ = readRev.Close()
+
+	if string(b) != contents[0] {
+		t.Errorf("unexpected content for revision 1 after deleting its former base")
+	}
+}
+
+func TestAsOfDirectoryProvider(t *testing.T) {
+	id, err := c.Create(context.Background(), DirectoryProvider)
+	if err != nil {
+		t.Errorf("error creating workspace: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := c.Rm(context.Background(), id); err != nil {
+			t.Errorf("unexpected error when removing workspace: %v", err)
+		}
+	})
+
+	// Write a file three times, noting a time in between each write
+	var times []time.Time
+	for _, content := range []string{"first", "second", "third"} {
+		times = append(times, time.Now())
+		time.Sleep(10 * time.Millisecond)
+
+		if err = c.WriteFile(context.Background(), id, "test.txt", strings.NewReader(content)); err != nil {
+			t.Fatalf("error getting file to write: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	times = append(times, time.Now())
+
+	// Before the first write, the file does not exist
+	nfe := (*NotFoundError)(nil)
+	if _, err = c.OpenFile(context.Background(), id, "test.txt", OpenOptions{AsOf: times[0]}); !errors.As(err, &nfe) {
+		t.Errorf("expected not found error when opening file before it existed: %v", err)
+	}
+	if _, err = c.StatFile(context.Background(), id, "test.txt", StatOptions{AsOf: times[0]}); !errors.As(err, &nfe) {
+		t.Errorf("expected not found error when statting file before it existed: %v", err)
+	}
+	if files, err := c.Ls(context.Background(), id, "", LsOptions{AsOf: times[0]}); err != nil {
+		t.Errorf("unexpected error when listing files: %v", err)
+	} else if len(files) != 0 {
+		t.Errorf("unexpected files before the file existed: %v", files)
+	}
+
+	for i, expected := range []string{"first", "second", "third"} {
+		f, err := c.OpenFile(context.Background(), id, "test.txt", OpenOptions{AsOf: times[i+1]})
+		if err != nil {
+			t.Errorf("unexpected error when opening file: %v", err)
+			continue
+		}
+
+		content, err := io.ReadAll(f)
+		if err != nil {
+			t.Errorf("unexpected error when reading file: %v", err)
+		}
+		_ = f.Close()
+
+		if string(content) != expected {
+			t.Errorf("unexpected content as of write %d: %s", i, content)
+		}
+
+		info, err := c.StatFile(context.Background(), id, "test.txt", StatOptions{AsOf: times[i+1]})
+		if err != nil {
+			t.Errorf("unexpected error when statting file: %v", err)
+		} else if info.Size != int64(len(expected)) {
+			t.Errorf("unexpected size as of write %d: %d", i, info.Size)
+		} else if info.ModTime.Before(times[i]) || info.ModTime.After(times[i+1]) {
+			t.Errorf("unexpected mod time as of write %d: %s", i, info.ModTime)
+		}
+
+		if files, err := c.Ls(context.Background(), id, "", LsOptions{AsOf: times[i+1]}); err != nil {
+			t.Errorf("unexpected error when listing files: %v", err)
+		} else if !reflect.DeepEqual(files, []string{"test.txt"}) {
+			t.Errorf("unexpected files as of write %d: %v", i, files)
+		}
+	}
+
+	// Overwriting the file without a revision doesn't change what it held before
+	time.Sleep(10 * time.Millisecond)
+	if err = c.WriteFile(context.Background(), id, "test.txt", strings.NewReader("fourth"), WriteOptions{CreateRevision: &[]bool{false}[0]}); err != nil {
+		t.Fatalf("error getting file to write: %v", err)
+	}
+
+	for asOf, expected := range map[time.Time]string{times[3]: "second", time.Now(): "fourth"} {
+		f, err := c.OpenFile(context.Background(), id, "test.txt", OpenOptions{AsOf: asOf})
+		if err != nil {
+			t.Errorf("unexpected error when opening file: %v", err)
+			continue
+		}
+
+		content, err := io.ReadAll(f)
+		if err != nil {
+			t.Errorf("unexpected error when reading file: %v", err)
+		}
+		_ = f.Close()
+
+		if string(content) != expected {
+			t.Errorf("unexpected content after writing without a revision: %s", content)
+		}
+	}
+}
+
+func TestBlameDirectoryProvider(t *testing.T) {
+	id, err := c.Create(context.Background(), DirectoryProvider)
+	if err != nil {
+		t.Errorf("error creating workspace: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := c.Rm(context.Background(), id); err != nil {
+			t.Errorf("unexpected error when removing workspace: %v", err)
+		}
+	})
+
+	for _, content := range []string{
+		"one\ntwo\n",
+		"one\ntwo\nthree\n",
+		"zero\none\ntwo\nthree\n",
+		"zero\none\n2\nthree\nfour\n",
+	} {
+		if err = c.WriteFile(context.Background(), id, "test.txt", strings.NewReader(content)); err != nil {
+			t.Fatalf("error getting file to write: %v", err)
+		}
+	}
+
+	type attribution struct {
+		text, revisionID string
+	}
+	attributions := func(lines []BlameLine) []attribution {
+		result := make([]attribution, 0, len(lines))
+		for i, line := range lines {
+			if line.LineNumber != i+1 {
+				t.Errorf("unexpected line number: %d", line.LineNumber)
+			}
+			result = append(result, attribution{text: line.Text, revisionID: line.RevisionID})
+		}
+		return result
+	}
+
+	lines, err := c.Blame(context.Background(), id, "test.txt")
+	if err != nil {
+		t.Fatalf("unexpected error when blaming file: %v", err)
+	}
+
+	expected := []attribution{{"zero", "3"}, {"one", "1"}, {"2", ""}, {"three", "2"}, {"four", ""}}
+	if actual := attributions(lines); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("unexpected blame: %v", actual)
+	}
+
+	// Lines introduced by a deleted revision are attributed to the next surviving revision
+	if err = c.DeleteRevision(context.Background(), id, "test.txt", "2"); err != nil {
+		t.Errorf("unexpected error when deleting revision: %v", err)
+	}
+
+	lines, err = c.Blame(context.Background(), id, "test.txt")
+	if err != nil {
+		t.Fatalf("unexpected error when blaming file: %v", err)
+	}
+
+	expected = []attribution{{"zero", "3"}, {"one", "1"}, {"2", ""}, {"three", "3"}, {"four", ""}}
+	if actual := attributions(lines); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("unexpected blame after deleting revision: %v", actual)
+	}
+
+	// Binary files cannot be blamed
+	if err = c.WriteFile(context.Background(), id, "test.bin", strings.NewReader("\x00\x01\x02\x03")); err != nil {
+		t.Fatalf("error getting file to write: %v", err)
+	}
+
+	if _, err = c.Blame(context.Background(), id, "test.bin"); !errors.Is(err, NotTextFileError) {
+		t.Errorf("expected not text file error when blaming binary file: %v", err)
+	}
+}
+
+func TestMoveDirectoryProvider(t *testing.T) {
+	id, err := c.Create(context.Background(), DirectoryProvider)
+	if err != nil {
+		t.Fatalf("error creating workspace: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := c.Rm(context.Background(), id); err != nil {
+			t.Errorf("unexpected error when removing workspace: %v", err)
+		}
+	})
+
+	for _, content := range []string{"one", "two", "three"} {
+		if err = c.WriteFile(context.Background(), id, "test.txt", strings.NewReader(content)); err != nil {
+			t.Fatalf("error getting file to write: %v", err)
+		}
+	}
+	if err = c.WriteFile(context.Background(), id, "other.txt", strings.NewReader("other")); err != nil {
+		t.Fatalf("error getting file to write: %v", err)
+	}
+
+	// A stale revision guard on the source should fail.
+	if err = c.Move(context.Background(), id, "test.txt", "moved/test.txt", MoveOptions{LatestRevisionID: "1"}); err == nil {
+		t.Fatalf("expected conflict error when moving with a stale revision")
+	} else if ce := (*ConflictError)(nil); !errors.As(err, &ce) {
+		t.Fatalf("expected conflict error when moving with a stale revision, got: %v", err)
+	}
+
+	// The destination exists, so this should fail.
+	if err = c.Move(context.Background(), id, "test.txt", "other.txt", MoveOptions{IfNotExists: true}); err == nil {
+		t.Fatalf("expected file exists error when moving onto an existing file")
+	} else if fee := (*FileExistsError)(nil); !errors.As(err, &fee) {
+		t.Fatalf("expected file exists error when moving onto an existing file, got: %v", err)
+	}
+
+	if err = c.Move(context.Background(), id, "test.txt", "moved/test.txt", MoveOptions{LatestRevisionID: "2", IfNotExists: true}); err != nil {
+		t.Fatalf("unexpected error when moving file: %v", err)
+	}
+
+	if _, err = c.StatFile(context.Background(), id, "test.txt"); err == nil {
+		t.Errorf("expected not found error when statting moved file")
+	} else if nfe := (*NotFoundError)(nil); !errors.As(err, &nfe) {
+		t.Errorf("expected not found error when statting moved file, got: %v", err)
+	}
+
+	info, err := c.StatFile(context.Background(), id, "moved/test.txt", StatOptions{WithLatestRevisionID: true})
+	if err != nil {
+		t.Fatalf("unexpected error when statting moved file: %v", err)
+	}
+	if info.RevisionID != "2" {
+		t.Errorf("unexpected revision ID after move: %s", info.RevisionID)
+	}
+
+	revisions, err := c.ListRevisions(context.Background(), id, "moved/test.txt")
+	if err != nil {
+		t.Fatalf("unexpected error when listing revisions: %v", err)
+	}
+	if len(revisions) != 2 {
+		t.Fatalf("unexpected number of revisions: %d", len(revisions))
+	}
+
+	for i, expected := range []string{"one", "two"} {
+		f, err := c.GetRevision(context.Background(), id, "moved/test.txt", revisions[i].RevisionID)
+		if err != nil {
+			t.Fatalf("unexpected error when getting revision: %v", err)
+		}
+
+		content, err := io.ReadAll(f)
+		_ = f.Close()
+		if err != nil {
+			t.Fatalf("unexpected error when reading revision: %v", err)
+		}
+		if string(content) != expected {
+			t.Errorf("unexpected content of revision %s: %s", revisions[i].RevisionID, content)
+		}
+	}
+
+	if revisions, err = c.ListRevisions(context.Background(), id, "test.txt"); err != nil {
+		t.Fatalf("unexpected error when listing revisions: %v", err)
+	} else if len(revisions) != 0 {
+		t.Errorf("unexpected number of revisions left behind: %d", len(revisions))
+	}
+
+	// Writing to the moved file continues from its revision counter.
+	if err = c.WriteFile(context.Background(), id, "moved/test.txt", strings.NewReader("four"), WriteOptions{LatestRevisionID: "2"}); err != nil {
+		t.Fatalf("unexpected error when writing moved file: %v", err)
+	}
+
+	// Moving onto an existing file replaces it.
+	if err = c.Move(context.Background(), id, "moved/test.txt", "other.txt"); err != nil {
+		t.Fatalf("unexpected error when moving file: %v", err)
+	}
+
+	f, err := c.OpenFile(context.Background(), id, "other.txt")
+	if err != nil {
+		t.Fatalf("unexpected error when opening moved file: %v", err)
+	}
+	defer f.Close()
+
+	content, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatalf("unexpected error when reading moved file: %v", err)
+	}
+	if string(content) != "four" {
+		t.Errorf("unexpected content of moved file: %s", content)
+	}
+
+	if revisions, err = c.ListRevisions(context.Background(), id, "other.txt"); err != nil {
+		t.Fatalf("unexpected error when listing revisions: %v", err)
+	} else if len(revisions) != 3 {
+		t.Errorf("unexpected number of revisions after replacing a file: %d", len(revisions))
+	}
+}
+
+func TestCopyFileDirectoryProvider(t *testing.T) {
+	id, err := c.Create(context.Background(), DirectoryProvider)
+	if err != nil {
+		t.Fatalf("error creating workspace: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := c.Rm(context.Background(), id); err != nil {
+			t.Errorf("unexpected error when removing workspace: %v", err)
+		}
+	})
+
+	otherID, err := c.Create(context.Background(), DirectoryProvider)
+	if err != nil {
+		t.Fatalf("error creating workspace: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := c.Rm(context.Background(), otherID); err != nil {
+			t.Errorf("unexpected error when removing workspace: %v", err)
+		}
+	})
+
+	if err = c.WriteFile(context.Background(), id, "test.txt", strings.NewReader("test")); err != nil {
+		t.Fatalf("error getting file to write: %v", err)
+	}
+	if err = c.WriteFile(context.Background(), otherID, "copy.txt", strings.NewReader("old")); err != nil {
+		t.Fatalf("error getting file to write: %v", err)
+	}
+
+	if err = c.CopyFile(context.Background(), id, "missing.txt", otherID, "copy.txt"); err == nil {
+		t.Errorf("expected not found error when copying a missing file")
+	} else if nfe := (*NotFoundError)(nil); !errors.As(err, &nfe) {
+		t.Errorf("expected not found error when copying a missing file, got: %v", err)
+	}
+
+	if err = c.CopyFile(context.Background(), id, "test.txt", otherID, "copy.txt", CopyOptions{IfNotExists: true}); err == nil {
+		t.Errorf("expected file exists error when copying onto an existing file")
+	} else if fee := (*FileExistsError)(nil); !errors.As(err, &fee) {
+		t.Errorf("expected file exists error when copying onto an existing file, got: %v", err)
+	}
+
+	if err = c.CopyFile(context.Background(), id, "test.txt", otherID, "copy.txt", CopyOptions{LatestRevisionID: "0"}); err != nil {
+		t.Fatalf("unexpected error when copying file across workspaces: %v", err)
+	}
+	if err = c.CopyFile(context.Background(), id, "test.txt", id, "dir/copy.txt"); err != nil {
+		t.Fatalf("unexpected error when copying file within a workspace: %v", err)
+	}
+	if err = c.CopyFile(context.Background(), id, "test.txt", id, "test.txt"); err != nil {
+		t.Fatalf("unexpected error when copying file to itself: %v", err)
+	}
+
+	// Copies replace the destination once they are complete, so no temporary files are left behind.
+	if files, err := c.Ls(context.Background(), id, ""); err != nil {
+		t.Errorf("unexpected error when listing files: %v", err)
+	} else if !reflect.DeepEqual(files, []string{"dir/copy.txt", "test.txt"}) {
+		t.Errorf("unexpected files after copying: %v", files)
+	}
+
+	for _, file := range []struct{ id, name string }{{otherID, "copy.txt"}, {id, "dir/copy.txt"}, {id, "test.txt"}} {
+		f, err := c.OpenFile(context.Background(), file.id, file.name)
+		if err != nil {
+			t.Fatalf("unexpected error when opening %s: %v", file.name, err)
+		}
+
+		content, err := io.ReadAll(f)
+		_ = f.Close()
+		if err != nil {
+			t.Fatalf("unexpected error when reading %s: %v", file.name, err)
+		}
+		if string(content) != "test" {
+			t.Errorf("unexpected content of %s: %s", file.name, content)
+		}
+	}
+
+	// The overwritten content of the destination is kept as a revision.
+	revisions, err := c.ListRevisions(context.Background(), otherID, "copy.txt")
+	if err != nil {
+		t.Fatalf("unexpected error when listing revisions: %v", err)
+	}
+	if len(revisions) != 1 {
+		t.Fatalf("unexpected number of revisions: %d", len(revisions))
+	}
+
+	f, err := c.GetRevision(context.Background(), otherID, "copy.txt", revisions[0].RevisionID)
+	if err != nil {
+		t.Fatalf("unexpected error when getting revision: %v", err)
+	}
+	defer f.Close()
+
+	content, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatalf("unexpected error when reading revision: %v", err)
+	}
+	if string(content) != "old" {
+		t.Errorf("unexpected content of revision: %s", content)
+	}
+}
+
+func TestOpenFileRangeDirectoryProvider(t *testing.T) {
+	id, err := c.Create(context.Background(), DirectoryProvider)
+	if err != nil {
+		t.Fatalf("error creating workspace: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := c.Rm(context.Background(), id); err != nil {
+			t.Errorf("unexpected error when removing workspace: %v", err)
+		}
+	})
+
+	if err = c.WriteFile(context.Background(), id, "test.txt", strings.NewReader("0123456789")); err != nil {
+		t.Fatalf("error getting file to write: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name           string
+		offset, length int64
+		expected       string
+	}{
+		{name: "offset and length", offset: 2, length: 3, expected: "234"},
+		{name: "offset only", offset: 7, expected: "789"},
+		{name: "length only", length: 4, expected: "0123"},
+		{name: "length beyond end", offset: 8, length: 10, expected: "89"},
+		{name: "offset beyond end", offset: 20, length: 5, expected: ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := c.OpenFile(context.Background(), id, "test.txt", OpenOptions{Offset: tc.offset, Length: tc.length})
+			if err != nil {
+				t.Fatalf("unexpected error when opening file: %v", err)
+			}
+			defer f.Close()
+
+			content, err := io.ReadAll(f)
+			if err != nil {
+				t.Fatalf("unexpected error when reading file: %v", err)
+			}
+			if string(content) != tc.expected {
+				t.Errorf("unexpected content: %q", content)
+			}
+			if f.Size != 10 {
+				t.Errorf("unexpected size: %d", f.Size)
+			}
+		})
+	}
+
+	if _, err = c.OpenFile(context.Background(), id, "test.txt", OpenOptions{Offset: -1}); err == nil {
+		t.Errorf("expected error when opening file with a negative offset")
+	}
+
+	// Ranges also apply when reading the file as of a time that is stored as a revision.
+	before := time.Now()
+	if err = c.WriteFile(context.Background(), id, "test.txt", strings.NewReader("abcdefghij")); err != nil {
+		t.Fatalf("error getting file to write: %v", err)
+	}
+
+	f, err := c.OpenFile(context.Background(), id, "test.txt", OpenOptions{AsOf: before, Offset: 5, Length: 2})
+	if err != nil {
+		t.Fatalf("unexpected error when opening file as of a time: %v", err)
+	}
+	defer f.Close()
+
+	content, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatalf("unexpected error when reading file: %v", err)
+	}
+	if string(content) != "56" {
+		t.Errorf("unexpected content: %q", content)
+	}
+}
+
+func TestAppendFileDirectoryProvider(t *testing.T) {
+	id, err := c.Create(context.Background(), DirectoryProvider)
+	if err != nil {
+		t.Fatalf("error creating workspace: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := c.Rm(context.Background(), id); err != nil {
+			t.Errorf("unexpected error when removing workspace: %v", err)
+		}
+	})
+
+	// Appending to a file that does not exist creates it.
+	if err = c.AppendFile(context.Background(), id, "log.txt", strings.NewReader("one\n")); err != nil {
+		t.Fatalf("unexpected error when appending to file: %v", err)
+	}
+	if err = c.AppendFile(context.Background(), id, "log.txt", strings.NewReader("two\n"), AppendOptions{LatestRevisionID: "0"}); err != nil {
+		t.Fatalf("unexpected error when appending to file: %v", err)
+	}
+	if err = c.AppendFile(context.Background(), id, "log.txt", strings.NewReader("three\n"), AppendOptions{CreateRevision: &[]bool{false}[0]}); err != nil {
+		t.Fatalf("unexpected error when appending to file: %v", err)
+	}
+
+	if err = c.AppendFile(context.Background(), id, "log.txt", strings.NewReader("four\n"), AppendOptions{LatestRevisionID: "0"}); err == nil {
+		t.Errorf("expected conflict error when appending with a stale revision")
+	} else if ce := (*ConflictError)(nil); !errors.As(err, &ce) {
+		t.Errorf("expected conflict error when appending with a stale revision, got: %v", err)
+	}
+
+	f, err := c.OpenFile(context.Background(), id, "log.txt")
+	if err != nil {
+		t.Fatalf("unexpected error when opening file: %v", err)
+	}
+	defer f.Close()
+
+	content, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatalf("unexpected error when reading file: %v", err)
+	}
+	if string(content) != "one\ntwo\nthree\n" {
+		t.Errorf("unexpected content: %q", content)
+	}
+
+	// Only the appends that created a revision are kept as revisions.
+	revisions, err := c.ListRevisions(context.Background(), id, "log.txt")
+	if err != nil {
+		t.Fatalf("unexpected error when listing revisions: %v", err)
+	}
+	if len(revisions) != 1 {
+		t.Errorf("unexpected number of revisions: %d", len(revisions))
+	}
+}
+
+func TestLsWithInfoDirectoryProvider(t *testing.T) {
+	id, err := c.Create(context.Background(), DirectoryProvider)
+	if err != nil {
+		t.Fatalf("error creating workspace: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := c.Rm(context.Background(), id); err != nil {
+			t.Errorf("unexpected error when removing workspace: %v", err)
+		}
+	})
+
+	for name, content := range map[string]string{
+		"b.txt":       "bb",
+		"a.txt":       "aaaa",
+		"dir/c.txt":   "c",
+		"dir/sub/d.t": "ddd",
+	} {
+		if err = c.WriteFile(context.Background(), id, name, strings.NewReader(content)); err != nil {
+			t.Fatalf("error getting file to write: %v", err)
+		}
+	}
+
+	names := func(infos []FileInfo) []string {
+		result := make([]string, 0, len(infos))
+		for _, info := range infos {
+			result = append(result, info.Name)
+		}
+		return result
+	}
+
+	infos, err := c.LsWithInfo(context.Background(), id, "")
+	if err != nil {
+		t.Fatalf("unexpected error when listing files: %v", err)
+	}
+	if expected := []string{"a.txt", "b.txt", "dir/c.txt", "dir/sub/d.t"}; !reflect.DeepEqual(names(infos), expected) {
+		t.Errorf("unexpected files: %v", names(infos))
+	}
+	for _, info := range infos {
+		if info.Name == "a.txt" && (info.Size != 4 || info.ModTime.IsZero()) {
+			t.Errorf("unexpected info for a.txt: %+v", info)
+		}
+	}
+
+	if infos, err = c.LsWithInfo(context.Background(), id, "dir"); err != nil {
+		t.Fatalf("unexpected error when listing files: %v", err)
+	} else if expected := []string{"dir/c.txt", "dir/sub/d.t"}; !reflect.DeepEqual(names(infos), expected) {
+		t.Errorf("unexpected files with prefix: %v", names(infos))
+	}
+
+	if infos, err = c.LsWithInfo(context.Background(), id, "", LsOptions{SortBy: LsSortBySize, Descending: true}); err != nil {
+		t.Fatalf("unexpected error when listing files: %v", err)
+	} else if expected := []string{"a.txt", "dir/sub/d.t", "b.txt", "dir/c.txt"}; !reflect.DeepEqual(names(infos), expected) {
+		t.Errorf("unexpected order when sorting by size: %v", names(infos))
+	}
+
+	if infos, err = c.LsWithInfo(context.Background(), id, "", LsOptions{MinSize: 2, MaxSize: 3}); err != nil {
+		t.Fatalf("unexpected error when listing files: %v", err)
+	} else if expected := []string{"b.txt", "dir/sub/d.t"}; !reflect.DeepEqual(names(infos), expected) {
+		t.Errorf("unexpected files when filtering by size: %v", names(infos))
+	}
+
+	if _, err = c.LsWithInfo(context.Background(), id, "", LsOptions{SortBy: "color"}); err == nil {
+		t.Errorf("expected error when sorting by an unknown field")
+	} else if iae := (*InvalidArgumentError)(nil); !errors.As(err, &iae) {
+		t.Errorf("unexpected error when sorting by an unknown field: %v", err)
+	}
+}
+
+func TestLsNonRecursiveDirectoryProvider(t *testing.T) {
+	id, err := c.Create(context.Background(), DirectoryProvider)
+	if err != nil {
+		t.Fatalf("error creating workspace: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := c.Rm(context.Background(), id); err != nil {
+			t.Errorf("unexpected error when removing workspace: %v", err)
+		}
+	})
+
+	for name, content := range map[string]string{
+		"a.txt":          "aaaa",
+		"dir/c.txt":      "c",
+		"dir/sub/d.txt":  "ddd",
+		"other/e/f.json": "{}",
+	} {
+		if err = c.WriteFile(context.Background(), id, name, strings.NewReader(content)); err != nil {
+			t.Fatalf("error getting file to write: %v", err)
+		}
+	}
+
+	recursive := false
+	files, err := c.Ls(context.Background(), id, "", LsOptions{Recursive: &recursive})
+	if err != nil {
+		t.Fatalf("unexpected error when listing files: %v", err)
+	}
+	if expected := []string{"a.txt", "dir/", "other/"}; !reflect.DeepEqual(files, expected) {
+		t.Errorf("unexpected files: %v", files)
+	}
+
+	infos, err := c.LsWithInfo(context.Background(), id, "dir", LsOptions{Recursive: &recursive, MinSize: 2})
+	if err != nil {
+		t.Fatalf("unexpected error when listing files: %v", err)
+	}
+	if len(infos) != 1 || infos[0].Name != "dir/sub/" || !infos[0].IsDir {
+		t.Errorf("unexpected files with prefix: %+v", infos)
+	}
+
+	if files, err = c.Ls(context.Background(), id, "dir/"); err != nil {
+		t.Fatalf("unexpected error when listing files: %v", err)
+	} else if expected := []string{"dir/c.txt", "dir/sub/d.txt"}; !reflect.DeepEqual(files, expected) {
+		t.Errorf("unexpected files when listing recursively: %v", files)
+	}
+
+	if files, err = c.Ls(context.Background(), id, "missing", LsOptions{Recursive: &recursive}); err != nil {
+		t.Fatalf("unexpected error when listing files: %v", err)
+	} else if len(files) != 0 {
+		t.Errorf("unexpected files in missing directory: %v", files)
+	}
+}
+
+func TestLsPageDirectoryProvider(t *testing.T) {
+	id, err := c.Create(context.Background(), DirectoryProvider)
+	if err != nil {
+		t.Fatalf("error creating workspace: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := c.Rm(context.Background(), id); err != nil {
+			t.Errorf("unexpected error when removing workspace: %v", err)
+		}
+	})
+
+	// Files in the directory "a" are listed before "a.txt", as in a walk of the directory tree.
+	expected := []string{"a/b.txt", "a/c/d.txt", "a/e.txt", "a.txt", "a.zip", "f.txt"}
+	for _, name := range expected {
+		if err = c.WriteFile(context.Background(), id, name, strings.NewReader(name)); err != nil {
+			t.Fatalf("error getting file to write: %v", err)
+		}
+	}
+
+	var (
+		files []string
+		pages int
+		opt   = LsOptions{Limit: 2}
+	)
+	for {
+		page, err := c.LsPage(context.Background(), id, "", opt)
+		if err != nil {
+			t.Fatalf("unexpected error when listing page: %v", err)
+		}
+		if len(page.Files) > 2 {
+			t.Errorf("unexpected number of files in page: %d", len(page.Files))
+		}
+
+		pages++
+		for _, info := range page.Files {
+			files = append(files, info.Name)
+		}
+
+		if page.Continuation == "" {
+			break
+		}
+		opt.Continuation = page.Continuation
+	}
+
+	if pages != 3 {
+		t.Errorf("unexpected number of pages: %d", pages)
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("unexpected files: %v", files)
+	}
+
+	// The next page starts after the continuation even if that file has been deleted.
+	if err = c.DeleteFile(context.Background(), id, "a/c/d.txt"); err != nil {
+		t.Fatalf("unexpected error when deleting file: %v", err)
+	}
+	page, err := c.LsPage(context.Background(), id, "", LsOptions{Limit: 2, Continuation: "a/c/d.txt"})
+	if err != nil {
+		t.Fatalf("unexpected error when listing page: %v", err)
+	}
+	if len(page.Files) != 2 || page.Files[0].Name != "a/e.txt" || page.Files[1].Name != "a.txt" {
+		t.Errorf("unexpected files after deleted continuation: %+v", page.Files)
+	}
+
+	// Limits beyond the largest page are reduced rather than allocated.
+	page, err = c.LsPage(context.Background(), id, "", LsOptions{Limit: math.MaxInt})
+	if err != nil {
+		t.Fatalf("unexpected error when listing page: %v", err)
+	}
+	if len(page.Files) != len(expected)-1 || page.Continuation != "" {
+		t.Errorf("unexpected page with a large limit: %+v", page)
+	}
+
+	files = files[:0]
+	for info, err := range c.LsIter(context.Background(), id, "a", LsOptions{Limit: 1}) {
+		if err != nil {
+			t.Fatalf("unexpected error when iterating files: %v", err)
+		}
+		files = append(files, info.Name)
+	}
+	if expected := []string{"a/b.txt", "a/e.txt"}; !reflect.DeepEqual(files, expected) {
+		t.Errorf("unexpected files when iterating: %v", files)
+	}
+}
+
+func TestLsAndRemoveMatchingDirectoryProvider(t *testing.T) {
+	id, err := c.Create(context.Background(), DirectoryProvider)
+	if err != nil {
+		t.Fatalf("error creating workspace: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := c.Rm(context.Background(), id); err != nil {
+			t.Errorf("unexpected error when removing workspace: %v", err)
+		}
+	})
+
+	for _, name := range []string{"README.md", "docs/a.md", "docs/b.txt", "tmp/x.log", "tmp/sub/y.log", "tmp/keep.log"} {
+		if err = c.WriteFile(context.Background(), id, name, strings.NewReader(name)); err != nil {
+			t.Fatalf("error getting file to write: %v", err)
+		}
+	}
+
+	files, err := c.Ls(context.Background(), id, "", LsOptions{Include: []string{"**/*.md"}})
+	if err != nil {
+		t.Fatalf("unexpected error when listing files: %v", err)
+	}
+	if expected := []string{"README.md", "docs/a.md"}; !reflect.DeepEqual(files, expected) {
+		t.Errorf("unexpected files matching pattern: %v", files)
+	}
+
+	infos, err := c.LsWithInfo(context.Background(), id, "", LsOptions{Include: []string{"docs/*", "tmp/**"}, Exclude: []string{"**/*.log"}})
+	if err != nil {
+		t.Fatalf("unexpected error when listing files: %v", err)
+	}
+	if len(infos) != 2 || infos[0].Name != "docs/a.md" || infos[1].Name != "docs/b.txt" {
+		t.Errorf("unexpected files with include and exclude patterns: %+v", infos)
+	}
+
+	if _, err = c.Ls(context.Background(), id, "", LsOptions{Include: []string{"[a"}}); err == nil {
+		t.Errorf("expected error when listing with an invalid pattern")
+	}
+
+	opt := RemoveMatchingOptions{Include: []string{"tmp/**/*.log"}, Exclude: []string{"tmp/keep.log"}, DryRun: true}
+	removed, err := c.RemoveMatching(context.Background(), id, opt)
+	if err != nil {
+		t.Fatalf("unexpected error when previewing removal: %v", err)
+	}
+	if expected := []string{"tmp/sub/y.log", "tmp/x.log"}; !reflect.DeepEqual(removed, expected) {
+		t.Errorf("unexpected files to remove: %v", removed)
+	}
+	if _, err = c.StatFile(context.Background(), id, "tmp/x.log"); err != nil {
+		t.Errorf("unexpected error when statting file after dry run: %v", err)
+	}
+
+	opt.DryRun = false
+	if removed, err = c.RemoveMatching(context.Background(), id, opt); err != nil {
+		t.Fatalf("unexpected error when removing files: %v", err)
+	} else if len(removed) != 2 {
+		t.Errorf("unexpected removed files: %v", removed)
+	}
+
+	if files, err = c.Ls(context.Background(), id, "tmp"); err != nil {
+		t.Fatalf("unexpected error when listing files: %v", err)
+	} else if expected := []string{"tmp/keep.log"}; !reflect.DeepEqual(files, expected) {
+		t.Errorf("unexpected files after removal: %v", files)
+	}
+
+	if _, err = c.RemoveMatching(context.Background(), id); err == nil {
+		t.Errorf("expected error when removing without patterns")
+	}
+}
+
+func TestSearchDirectoryProvider(t *testing.T) {
+	id, err := c.Create(context.Background(), DirectoryProvider)
+	if err != nil {
+		t.Fatalf("error creating workspace: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := c.Rm(context.Background(), id); err != nil {
+			t.Errorf("unexpected error when removing workspace: %v", err)
+		}
+	})
+
+	for name, content := range map[string]string{
+		"notes.md":      "# Notes\nThe TODO list\nnothing here\nTODO: write tests\nend\n",
+		"docs/guide.md": "Nothing to do\n",
+		"src/main.go":   "package main\n\n// todo: remove\nfunc main() {}\n",
+		"image.png":     "\x89PNG\r\n\x1a\nTODO",
+	} {
+		if err = c.WriteFile(context.Background(), id, name, strings.NewReader(content)); err != nil {
+			t.Fatalf("error getting file to write: %v", err)
+		}
+	}
+
+	matches, err := c.Search(context.Background(), id, "TODO")
+	if err != nil {
+		t.Fatalf("unexpected error when searching: %v", err)
+	}
+	if len(matches) != 2 || matches[0].FileName != "notes.md" || matches[0].Line != 2 || matches[1].Line != 4 || matches[1].Text != "TODO: write tests" {
+		t.Errorf("unexpected matches: %+v", matches)
+	}
+
+	if matches, err = c.Search(context.Background(), id, "todo", SearchOptions{IgnoreCase: true, Include: []string{"**/*.go"}}); err != nil {
+		t.Fatalf("unexpected error when searching: %v", err)
+	} else if len(matches) != 1 || matches[0].FileName != "src/main.go" || matches[0].Line != 3 {
+		t.Errorf("unexpected matches with include pattern: %+v", matches)
+	}
+
+	if matches, err = c.Search(context.Background(), id, `^TODO:\s`, SearchOptions{Regex: true, ContextLines: 1}); err != nil {
+		t.Fatalf("unexpected error when searching: %v", err)
+	} else if len(matches) != 1 || !reflect.DeepEqual(matches[0].Before, []string{"nothing here"}) || !reflect.DeepEqual(matches[0].After, []string{"end"}) {
+		t.Errorf("unexpected matches with context: %+v", matches)
+	}
+
+	if matches, err = c.Search(context.Background(), id, "o", SearchOptions{MaxMatches: 2}); err != nil {
+		t.Fatalf("unexpected error when searching: %v", err)
+	} else if len(matches) != 2 {
+		t.Errorf("unexpected number of matches with maximum: %d", len(matches))
+	}
+
+	if _, err = c.Search(context.Background(), id, "(", SearchOptions{Regex: true}); err == nil {
+		t.Errorf("expected error when searching with an invalid regular expression")
+	}
+}
+
+func TestChecksumDirectoryProvider(t *testing.T) {
+	id, err := c.Create(context.Background(), DirectoryProvider)
+	if err != nil {
+		t.Fatalf("error creating workspace: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := c.Rm(context.Background(), id); err != nil {
+			t.Errorf("unexpected error when removing workspace: %v", err)
+		}
+	})
+
+	// The SHA-256 checksum of "test".
+	const checksum = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+	if err = c.WriteFile(context.Background(), id, "test.txt", strings.NewReader("test")); err != nil {
+		t.Fatalf("error getting file to write: %v", err)
+	}
+
+	info, err := c.StatFile(context.Background(), id, "test.txt")
+	if err != nil {
+		t.Fatalf("unexpected error when statting file: %v", err)
+	}
+	if info.Checksum != checksum {
+		t.Errorf("unexpected checksum: %s", info.Checksum)
+	}
+
+	infos, err := c.LsWithInfo(context.Background(), id, "")
+	if err != nil {
+		t.Fatalf("unexpected error when listing files: %v", err)
+	}
+	if len(infos) != 1 || infos[0].Checksum != checksum {
+		t.Errorf("unexpected checksum when listing files: %+v", infos)
+	}
+
+	err = c.WriteFile(context.Background(), id, "test.txt", strings.NewReader("corrupted"), WriteOptions{Checksum: checksum})
+	if cme := (*ChecksumMismatchError)(nil); !errors.As(err, &cme) {
+		t.Fatalf("expected checksum mismatch error when writing, got: %v", err)
+	}
+	file, err := c.OpenFile(context.Background(), id, "test.txt")
+	if err != nil {
+		t.Fatalf("unexpected error when opening file: %v", err)
+	}
+	content, err := io.ReadAll(file)
+	_ = file.Close()
+	if err != nil {
+		t.Fatalf("unexpected error when reading file: %v", err)
+	}
+	if string(content) != "test" {
+		t.Errorf("unexpected content after rejected write: %s", content)
+	}
+
+	if err = c.WriteFile(context.Background(), id, "copy.txt", strings.NewReader("test"), WriteOptions{Checksum: strings.ToUpper(checksum)}); err != nil {
+		t.Errorf("unexpected error when writing with matching checksum: %v", err)
+	}
+
+	// Corrupt the file behind the provider's back.
+	if err = os.WriteFile(filepath.Join(strings.TrimPrefix(id, DirectoryProvider+"://"), "test.txt"), []byte("tset"), 0o644); err != nil {
+		t.Fatalf("unexpected error when corrupting file: %v", err)
+	}
+
+	file, err = c.OpenFile(context.Background(), id, "test.txt", OpenOptions{VerifyChecksum: true})
+	if err != nil {
+		t.Fatalf("unexpected error when opening file: %v", err)
+	}
+	_, err = io.ReadAll(file)
+	_ = file.Close()
+	if cme := (*ChecksumMismatchError)(nil); !errors.As(err, &cme) {
+		t.Errorf("expected checksum mismatch error when reading, got: %v", err)
+	}
+
+	if err = c.AppendFile(context.Background(), id, "copy.txt", strings.NewReader("more")); err != nil {
+		t.Fatalf("unexpected error when appending to file: %v", err)
+	}
+	if info, err = c.StatFile(context.Background(), id, "copy.txt"); err != nil {
+		t.Fatalf("unexpected error when statting file: %v", err)
+	} else if info.Checksum != "" {
+		t.Errorf("unexpected checksum after append: %s", info.Checksum)
+	}
+}
+
+func TestContentTypeDirectoryProvider(t *testing.T) {
+	id, err := c.Create(context.Background(), DirectoryProvider)
+	if err != nil {
+		t.Fatalf("error creating workspace: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := c.Rm(context.Background(), id); err != nil {
+			t.Errorf("unexpected error when removing workspace: %v", err)
+		}
+	})
+
+	if err = c.WriteFile(context.Background(), id, "test.json", strings.NewReader(`{"test": true}`)); err != nil {
+		t.Fatalf("error getting file to write: %v", err)
+	}
+	if err = c.WriteFile(context.Background(), id, "test.txt", strings.NewReader("# Title"), WriteOptions{ContentType: "text/markdown"}); err != nil {
+		t.Fatalf("error getting file to write: %v", err)
+	}
+
+	dir := strings.TrimPrefix(id, DirectoryProvider+"://")
+
+	// Change the content behind the provider's back, which shows that the stored content type is returned.
+	if err = os.WriteFile(filepath.Join(dir, "test.json"), []byte("not json"), 0o644); err != nil {
+		t.Fatalf("unexpected error when changing file: %v", err)
+	}
+
+	for name, expected := range map[string]string{"test.json": "application/json", "test.txt": "text/markdown"} {
+		info, err := c.StatFile(context.Background(), id, name)
+		if err != nil {
+			t.Fatalf("unexpected error when statting file: %v", err)
+		}
+		if info.MimeType != expected {
+			t.Errorf("unexpected mime type of %s: %s", name, info.MimeType)
+		}
+	}
+
+	// A file written without a stored content type has it detected, and backfilled.
+	if err = os.WriteFile(filepath.Join(dir, "old.json"), []byte(`{"old": true}`), 0o644); err != nil {
+		t.Fatalf("unexpected error when writing file: %v", err)
+	}
+	if info, err := c.StatFile(context.Background(), id, "old.json"); err != nil {
+		t.Fatalf("unexpected error when statting file: %v", err)
+	} else if info.MimeType != "application/json" {
+		t.Errorf("unexpected detected mime type: %s", info.MimeType)
+	}
+
+	updated, err := c.BackfillContentTypes(context.Background(), id)
+	if err != nil {
+		t.Fatalf("unexpected error when backfilling content types: %v", err)
+	}
+	if updated != 1 {
+		t.Errorf("unexpected number of files backfilled: %d", updated)
+	}
+
+	if err = os.WriteFile(filepath.Join(dir, "old.json"), []byte("not json"), 0o644); err != nil {
+		t.Fatalf("unexpected error when changing file: %v", err)
+	}
+	if info, err := c.StatFile(context.Background(), id, "old.json"); err != nil {
+		t.Fatalf("unexpected error when statting file: %v", err)
+	} else if info.MimeType != "application/json" {
+		t.Errorf("unexpected backfilled mime type: %s", info.MimeType)
+	}
+
+	// Appending to a file whose first bytes change detects its content type again.
+	if err = c.WriteFile(context.Background(), id, "appended.json", strings.NewReader("")); err != nil {
+		t.Fatalf("error getting file to write: %v", err)
+	}
+	if err = c.AppendFile(context.Background(), id, "appended.json", strings.NewReader(`{"appended": true}`)); err != nil {
+		t.Fatalf("unexpected error when appending to file: %v", err)
+	}
+	if info, err := c.StatFile(context.Background(), id, "appended.json"); err != nil {
+		t.Fatalf("unexpected error when statting file: %v", err)
+	} else if info.MimeType != "application/json" {
+		t.Errorf("unexpected mime type after appending: %s", info.MimeType)
+	}
+}
+
+func TestMetadataDirectoryProvider(t *testing.T) {
+	id, err := c.Create(context.Background(), DirectoryProvider)
+	if err != nil {
+		t.Fatalf("error creating workspace: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := c.Rm(context.Background(), id); err != nil {
+			t.Errorf("unexpected error when removing workspace: %v", err)
+		}
+	})
+
+	if err = c.WriteFile(context.Background(), id, "test.txt", strings.NewReader("test"), WriteOptions{Metadata: map[string]string{"Source": "test"}}); err == nil {
+		t.Errorf("expected error when writing file with invalid metadata key")
+	}
+	if err = c.WriteFile(context.Background(), id, "test.txt", strings.NewReader("test"), WriteOptions{Metadata: map[string]string{"source": strings.Repeat("a", 2048)}}); err == nil {
+		t.Errorf("expected error when writing file with too much metadata")
+	} else if iae := (*InvalidArgumentError)(nil); !errors.As(err, &iae) {
+		t.Errorf("unexpected error when writing file with too much metadata: %v", err)
+	}
+
+	for name, metadata := range map[string]map[string]string{
+		"a.txt":     {"source": "https://example.com", "run_id": "1"},
+		"dir/b.txt": {"source": "https://example.com", "run_id": "2"},
+		"c.txt":     nil,
+	} {
+		if err = c.WriteFile(context.Background(), id, name, strings.NewReader(name), WriteOptions{Metadata: metadata}); err != nil {
+			t.Fatalf("error getting file to write: %v", err)
+		}
+	}
+
+	metadata, err := c.GetMetadata(context.Background(), id, "a.txt")
+	if err != nil {
+		t.Fatalf("unexpected error when getting metadata: %v", err)
+	}
+	if len(metadata) != 2 || metadata["source"] != "https://example.com" || metadata["run_id"] != "1" {
+		t.Errorf("unexpected metadata: %v", metadata)
+	}
+
+	files, err := c.Ls(context.Background(), id, "", LsOptions{Metadata: map[string]string{"source": "https://example.com"}})
+	if err != nil {
+		t.Fatalf("unexpected error when listing files: %v", err)
+	}
+	if !reflect.DeepEqual(files, []string{"a.txt", "dir/b.txt"}) {
+		t.Errorf("unexpected files with metadata: %v", files)
+	}
+
+	infos, err := c.LsWithInfo(context.Background(), id, "", LsOptions{Metadata: map[string]string{"run_id": "2"}})
+	if err != nil {
+		t.Fatalf("unexpected error when listing files: %v", err)
+	}
+	if len(infos) != 1 || infos[0].Name != "dir/b.txt" || infos[0].Metadata["run_id"] != "2" {
+		t.Errorf("unexpected files with metadata: %v", infos)
+	}
+
+	// Appending and moving keep the metadata, and setting it replaces it.
+	if err = c.AppendFile(context.Background(), id, "a.txt", strings.NewReader(" appended")); err != nil {
+		t.Fatalf("unexpected error when appending to file: %v", err)
+	}
+	if err = c.Move(context.Background(), id, "a.txt", "moved.txt"); err != nil {
+		t.Fatalf("unexpected error when moving file: %v", err)
+	}
+	if err = c.SetMetadata(context.Background(), id, "c.txt", map[string]string{"run_id": "1"}); err != nil {
+		t.Fatalf("unexpected error when setting metadata: %v", err)
+	}
+
+	files, err = c.Ls(context.Background(), id, "", LsOptions{Metadata: map[string]string{"run_id": "1"}})
+	if err != nil {
+		t.Fatalf("unexpected error when listing files: %v", err)
+	}
+	if !reflect.DeepEqual(files, []string{"c.txt", "moved.txt"}) {
+		t.Errorf("unexpected files with metadata: %v", files)
+	}
+
+	if info, err := c.StatFile(context.Background(), id, "moved.txt"); err != nil {
+		t.Fatalf("unexpected error when statting file: %v", err)
+	} else if info.Metadata["source"] != "https://example.com" {
+		t.Errorf("unexpected metadata after appending and moving: %v", info.Metadata)
+	}
+
+	if err = c.SetMetadata(context.Background(), id, "c.txt", nil); err != nil {
+		t.Fatalf("unexpected error when removing metadata: %v", err)
+	}
+	if metadata, err = c.GetMetadata(context.Background(), id, "c.txt"); err != nil {
+		t.Fatalf("unexpected error when getting metadata: %v", err)
+	} else if metadata != nil {
+		t.Errorf("unexpected metadata after removing it: %v", metadata)
+	}
+
+	if err = c.SetMetadata(context.Background(), id, "missing.txt", map[string]string{"run_id": "1"}); err == nil {
+		t.Errorf("expected error when setting metadata of missing file")
+	} else if nfe := (*NotFoundError)(nil); !errors.As(err, &nfe) {
+		t.Errorf("unexpected error when setting metadata of missing file: %v", err)
+	}
+}
+
+func TestBatchNestedWorkspaceDirectoryProvider(t *testing.T) {
+	parentID, err := c.Create(context.Background(), DirectoryProvider)
+	if err != nil {
+		t.Fatalf("error creating workspace: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := c.Rm(context.Background(), parentID); err != nil {
+			t.Errorf("unexpected error when removing workspace: %v", err)
+		}
+	})
+
+	// Batches stage their content next to the manifest, which nested workspaces keep under an escaped key.
+	id := parentID + "/nested"
+	if err = c.WriteFile(context.Background(), id, "a.txt", strings.NewReader("a1")); err != nil {
+		t.Fatalf("error getting file to write: %v", err)
+	}
+
+	// A batch that fails is rolled back from its staged backups.
+	if err = c.Batch(context.Background(), id, []BatchOperation{
+		{Op: BatchWrite, FileName: "a.txt", Content: strings.NewReader("a2")},
+		{Op: BatchWrite, FileName: "a.txt/b.txt", Content: strings.NewReader("b1")},
+	}); err == nil {
+		t.Errorf("expected error when applying batch that writes under a file")
+	}
+
+	f, err := c.OpenFile(context.Background(), id, "a.txt")
+	if err != nil {
+		t.Fatalf("unexpected error when opening file: %v", err)
+	}
+	content, err := io.ReadAll(f)
+	_ = f.Close()
+	if err != nil {
+		t.Fatalf("unexpected error when reading file: %v", err)
+	}
+	if string(content) != "a1" {
+		t.Errorf("unexpected content after rolling back batch: %s", content)
+	}
+
+	if err = c.Batch(context.Background(), id, []BatchOperation{
+		{Op: BatchWrite, FileName: "b.txt", Content: strings.NewReader("b1")},
+		{Op: BatchDelete, FileName: "a.txt"},
+	}); err != nil {
+		t.Fatalf("unexpected error when applying batch: %v", err)
+	}
+
+	files, err := c.Ls(context.Background(), id, "")
+	if err != nil {
+		t.Fatalf("unexpected error when listing files: %v", err)
+	}
+	if !reflect.DeepEqual(files, []string{"b.txt"}) {
+		t.Errorf("unexpected files after applying batch: %v", files)
+	}
+
+	factory, _, err := c.getWorkspaceFactory(context.Background(), id)
+	if err != nil {
+		t.Fatalf("unexpected error getting workspace factory: %v", err)
+	}
+	sc, stageDir, err := stagingClient(factory, id)
+	if err != nil {
+		t.Fatalf("unexpected error getting staging client: %v", err)
+	}
+	if staged, err := sc.Ls(context.Background(), stageDir); err != nil {
+		t.Errorf("unexpected error when listing staged files: %v", err)
+	} else if len(staged) != 0 {
+		t.Errorf("unexpected staged files left behind: %v", staged)
+	}
+}
+
+func TestBatchDirectoryProvider(t *testing.T) {
+	id, err := c.Create(context.Background(), DirectoryProvider)
+	if err != nil {
+		t.Fatalf("error creating workspace: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := c.Rm(context.Background(), id); err != nil {
+			t.Errorf("unexpected error when removing workspace: %v", err)
+		}
+	})
+
+	for name, content := range map[string]string{"a.txt": "a1", "b.txt": "b1", "c.txt": "c1"} {
+		if err = c.WriteFile(context.Background(), id, name, strings.NewReader(content)); err != nil {
+			t.Fatalf("error getting file to write: %v", err)
+		}
+	}
+
+	read := func(name string) (string, error) {
+		f, err := c.OpenFile(context.Background(), id, name)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+
+		content, err := io.ReadAll(f)
+		return string(content), err
+	}
+
+	info, err := c.StatFile(context.Background(), id, "a.txt", StatOptions{WithLatestRevisionID: true})
+	if err != nil {
+		t.Fatalf("unexpected error when statting file: %v", err)
+	}
+
+	if err = c.Batch(context.Background(), id, []BatchOperation{
+		{Op: BatchWrite, FileName: "a.txt", Content: strings.NewReader("a2"), LatestRevisionID: info.RevisionID},
+		{Op: BatchWrite, FileName: "dir/d.txt", Content: strings.NewReader("d1"), IfNotExists: true},
+		{Op: BatchDelete, FileName: "b.txt"},
+	}); err != nil {
+		t.Fatalf("unexpected error when applying batch: %v", err)
+	}
+
+	files, err := c.Ls(context.Background(), id, "")
+	if err != nil {
+		t.Fatalf("unexpected error when listing files: %v", err)
+	}
+	if !reflect.DeepEqual(files, []string{"a.txt", "c.txt", "dir/d.txt"}) {
+		t.Errorf("unexpected files after batch: %v", files)
+	}
+
+	for name, expected := range map[string]string{"a.txt": "a2", "dir/d.txt": "d1"} {
+		content, err := read(name)
+		if err != nil {
+			t.Fatalf("unexpected error when reading %s: %v", name, err)
+		}
+		if content != expected {
+			t.Errorf("unexpected content of %s: %s", name, content)
+		}
+	}
+
+	revisions, err := c.ListRevisions(context.Background(), id, "a.txt")
+	if err != nil {
+		t.Fatalf("unexpected error when listing revisions: %v", err)
+	}
+	if len(revisions) != 1 {
+		t.Errorf("unexpected number of revisions after batch: %d", len(revisions))
+	}
+
+	// A stale revision fails the batch before anything is changed.
+	err = c.Batch(context.Background(), id, []BatchOperation{
+		{Op: BatchDelete, FileName: "c.txt"},
+		{Op: BatchWrite, FileName: "a.txt", Content: strings.NewReader("a3"), LatestRevisionID: info.RevisionID},
+	})
+	if ce := (*ConflictError)(nil); err == nil || !errors.As(err, &ce) {
+		t.Errorf("expected conflict error when applying batch with stale revision: %v", err)
+	}
+	if _, err = c.StatFile(context.Background(), id, "c.txt"); err != nil {
+		t.Errorf("unexpected error when statting file after failed batch: %v", err)
+	}
+
+	// A failure while applying the batch rolls back the operations already applied.
+	if err = c.Batch(context.Background(), id, []BatchOperation{
+		{Op: BatchWrite, FileName: "a.txt", Content: strings.NewReader("a3")},
+		{Op: BatchDelete, FileName: "c.txt"},
+		{Op: BatchWrite, FileName: "e.txt", Content: strings.NewReader("e1")},
+		{Op: BatchWrite, FileName: "a.txt/nested.txt", Content: strings.NewReader("nested")},
+	}); err == nil {
+		t.Errorf("expected error when applying batch that writes beneath a file")
+	}
+
+	files, err = c.Ls(context.Background(), id, "")
+	if err != nil {
+		t.Fatalf("unexpected error when listing files: %v", err)
+	}
+	if !reflect.DeepEqual(files, []string{"a.txt", "c.txt", "dir/d.txt"}) {
+		t.Errorf("unexpected files after rolled back batch: %v", files)
+	}
+
+	for name, expected := range map[string]string{"a.txt": "a2", "c.txt": "c1"} {
+		content, err := read(name)
+		if err != nil {
+			t.Fatalf("unexpected error when reading %s: %v", name, err)
+		}
+		if content != expected {
+			t.Errorf("unexpected content of %s after rolled back batch: %s", name, content)
+		}
+	}
+
+	for name, expected := range map[string]int{"a.txt": 1, "c.txt": 0} {
+		revisions, err := c.ListRevisions(context.Background(), id, name)
+		if err != nil {
+			t.Fatalf("unexpected error when listing revisions: %v", err)
+		}
+		if len(revisions) != expected {
+			t.Errorf("unexpected number of revisions of %s after rolled back batch: %d", name, len(revisions))
+		}
+	}
+}
+
+func TestDirectoriesDirectoryProvider(t *testing.T) {
+	id, err := c.Create(context.Background(), DirectoryProvider)
+	if err != nil {
+		t.Fatalf("error creating workspace: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := c.Rm(context.Background(), id); err != nil {
+			t.Errorf("unexpected error when removing workspace: %v", err)
+		}
+	})
+
+	var (
+		nfe = (*NotFoundError)(nil)
+		fee = (*FileExistsError)(nil)
+		dne = (*DirectoryNotEmptyError)(nil)
+	)
+
+	if err = c.Mkdir(context.Background(), id, "kept/empty"); err != nil {
+		t.Fatalf("unexpected error when creating directory: %v", err)
+	}
+
+	info, err := c.StatFile(context.Background(), id, "kept/empty")
+	if err != nil {
+		t.Fatalf("unexpected error when statting directory: %v", err)
+	}
+	if !info.IsDir || info.Name != "kept/empty/" {
+		t.Errorf("unexpected directory info: %+v", info)
+	}
+
+	files, err := c.LsWithInfo(context.Background(), id, "kept", LsOptions{Recursive: &[]bool{false}[0]})
+	if err != nil {
+		t.Fatalf("unexpected error when listing files: %v", err)
+	}
+	if len(files) != 1 || files[0].Name != "kept/empty/" || !files[0].IsDir {
+		t.Errorf("unexpected files: %+v", files)
+	}
+
+	// Deleting the last file of a directory removes it, up to a directory created with Mkdir.
+	if err = c.WriteFile(context.Background(), id, "kept/empty/implicit/test.txt", strings.NewReader("test")); err != nil {
+		t.Fatalf("error getting file to write: %v", err)
+	}
+	if err = c.DeleteFile(context.Background(), id, "kept/empty/implicit/test.txt"); err != nil {
+		t.Fatalf("unexpected error when deleting file: %v", err)
+	}
+	if _, err = c.StatFile(context.Background(), id, "kept/empty/implicit"); !errors.As(err, &nfe) {
+		t.Errorf("expected not found error when statting pruned directory, got: %v", err)
+	}
+	if _, err = c.StatFile(context.Background(), id, "kept/empty/"); err != nil {
+		t.Errorf("unexpected error when statting kept directory: %v", err)
+	}
+
+	if err = c.WriteFile(context.Background(), id, "file.txt", strings.NewReader("test")); err != nil {
+		t.Fatalf("error getting file to write: %v", err)
+	}
+	if _, err = c.StatFile(context.Background(), id, "file.txt/"); !errors.As(err, &nfe) {
+		t.Errorf("expected not found error when statting file as directory, got: %v", err)
+	}
+	if err = c.Mkdir(context.Background(), id, "file.txt/sub"); !errors.As(err, &fee) {
+		t.Errorf("expected file exists error when creating directory beneath a file, got: %v", err)
+	}
+
+	if err = c.RemoveDir(context.Background(), id, "kept"); !errors.As(err, &dne) {
+		t.Errorf("expected directory not empty error when removing directory, got: %v", err)
+	}
+	if err = c.RemoveDir(context.Background(), id, "kept/empty"); err != nil {
+		t.Errorf("unexpected error when removing directory: %v", err)
+	}
+	// Its parent wasn't created with Mkdir, so it was removed with it.
+	if _, err = c.StatFile(context.Background(), id, "kept"); !errors.As(err, &nfe) {
+		t.Errorf("expected not found error when statting pruned directory, got: %v", err)
+	}
+
+	if err = c.WriteFile(context.Background(), id, "tree/a/test.txt", strings.NewReader("test")); err != nil {
+		t.Fatalf("error getting file to write: %v", err)
+	}
+	if err = c.WriteFile(context.Background(), id, "tree/a/test.txt", strings.NewReader("test2")); err != nil {
+		t.Fatalf("error getting file to write: %v", err)
+	}
+	if err = c.RemoveDir(context.Background(), id, "tree", RemoveDirOptions{Recursive: true}); err != nil {
+		t.Fatalf("unexpected error when removing directory recursively: %v", err)
+	}
+	if _, err = c.StatFile(context.Background(), id, "tree"); !errors.As(err, &nfe) {
+		t.Errorf("expected not found error when statting removed directory, got: %v", err)
+	}
+
+	// The revisions of the removed files were removed with them.
+	revisions, err := c.ListRevisions(context.Background(), id, "tree/a/test.txt")
+	if err != nil {
+		t.Fatalf("unexpected error when listing revisions: %v", err)
+	}
+	if len(revisions) != 0 {
+		t.Errorf("expected no revisions, got: %+v", revisions)
+	}
+
+	if err = c.RemoveDir(context.Background(), id, "tree"); !errors.As(err, &nfe) {
+		t.Errorf("expected not found error when removing missing directory, got: %v", err)
+	}
+}
+
+func TestExpiryDirectoryProvider(t *testing.T) {
+	id, err := c.Create(context.Background(), DirectoryProvider)
+	if err != nil {
+		t.Fatalf("error creating workspace: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := c.Rm(context.Background(), id); err != nil {
+			t.Errorf("unexpected error when removing workspace: %v", err)
+		}
+	})
+
+	nfe := (*NotFoundError)(nil)
+
+	if err = c.WriteFile(context.Background(), id, "kept.txt", strings.NewReader("test")); err != nil {
+		t.Fatalf("error getting file to write: %v", err)
+	}
+	if err = c.WriteFile(context.Background(), id, "later.txt", strings.NewReader("test"), WriteOptions{TTL: time.Hour}); err != nil {
+		t.Fatalf("error getting file to write: %v", err)
+	}
+	// The file is written twice so that it has a revision.
+	if err = c.WriteFile(context.Background(), id, "scratch/expired.txt", strings.NewReader("test")); err != nil {
+		t.Fatalf("error getting file to write: %v", err)
+	}
+	if err = c.WriteFile(context.Background(), id, "scratch/expired.txt", strings.NewReader("test"), WriteOptions{ExpiresAt: time.Now().Add(-time.Minute)}); err != nil {
+		t.Fatalf("error getting file to write: %v", err)
+	}
+	// Appending keeps the expiry.
+	if err = c.AppendFile(context.Background(), id, "later.txt", strings.NewReader("more")); err != nil {
+		t.Fatalf("unexpected error when appending to file: %v", err)
+	}
+
+	if err = c.WriteFile(context.Background(), id, "both.txt", strings.NewReader("test"), WriteOptions{ExpiresAt: time.Now(), TTL: time.Hour}); err == nil {
+		t.Errorf("expected error when writing file with both an expiry time and a TTL")
+	} else if iae := (*InvalidArgumentError)(nil); !errors.As(err, &iae) {
+		t.Errorf("expected invalid argument error when writing file with both an expiry time and a TTL, got: %v", err)
+	}
+
+	info, err := c.StatFile(context.Background(), id, "later.txt")
+	if err != nil {
+		t.Fatalf("unexpected error when statting file: %v", err)
+	}
+	if info.Size != 8 || info.ExpiresAt.IsZero() || info.ExpiresAt.Before(time.Now()) {
+		t.Errorf("unexpected file info: %+v", info)
+	}
+
+	if _, err = c.StatFile(context.Background(), id, "scratch/expired.txt"); !errors.As(err, &nfe) {
+		t.Errorf("expected not found error when statting expired file, got: %v", err)
+	}
+	if _, err = c.OpenFile(context.Background(), id, "scratch/expired.txt"); !errors.As(err, &nfe) {
+		t.Errorf("expected not found error when opening expired file, got: %v", err)
+	}
+
+	files, err := c.Ls(context.Background(), id, "")
+	if err != nil {
+		t.Fatalf("unexpected error when listing files: %v", err)
+	}
+	if !reflect.DeepEqual(files, []string{"kept.txt", "later.txt"}) {
+		t.Errorf("unexpected files: %v", files)
+	}
+
+	infos, err := c.LsWithInfo(context.Background(), id, "scratch", LsOptions{Recursive: &[]bool{false}[0]})
+	if err != nil {
+		t.Fatalf("unexpected error when listing files: %v", err)
+	}
+	if len(infos) != 0 {
+		t.Errorf("unexpected files: %+v", infos)
+	}
+
+	removed, err := c.SweepExpired(context.Background(), id)
+	if err != nil {
+		t.Fatalf("unexpected error when sweeping expired files: %v", err)
+	}
+	if !reflect.DeepEqual(removed, []string{"scratch/expired.txt"}) {
+		t.Errorf("unexpected removed files: %v", removed)
+	}
+
+	// The file is removed along with its revisions and its directory.
+	if revisions, err := c.ListRevisions(context.Background(), id, "scratch/expired.txt"); err != nil {
+		t.Errorf("unexpected error when listing revisions: %v", err)
+	} else if len(revisions) != 0 {
+		t.Errorf("unexpected number of revisions left behind: %d", len(revisions))
+	}
+	if _, err = c.StatFile(context.Background(), id, "scratch/"); !errors.As(err, &nfe) {
+		t.Errorf("expected not found error when statting directory of swept file, got: %v", err)
+	}
+
+	// A moved file keeps its expiry, and is swept under its new name.
+	if err = c.WriteFile(context.Background(), id, "short.txt", strings.NewReader("test"), WriteOptions{ExpiresAt: time.Now().Add(-time.Minute)}); err != nil {
+		t.Fatalf("error getting file to write: %v", err)
+	}
+	if err = c.Move(context.Background(), id, "short.txt", "moved.txt"); err != nil {
+		t.Fatalf("unexpected error when moving file: %v", err)
+	}
+	if files, err = c.Ls(context.Background(), id, ""); err != nil {
+		t.Fatalf("unexpected error when listing files: %v", err)
+	}
+	if !reflect.DeepEqual(files, []string{"kept.txt", "later.txt"}) {
+		t.Errorf("unexpected files: %v", files)
+	}
+	if removed, err = c.SweepExpired(context.Background(), id); err != nil {
+		t.Fatalf("unexpected error when sweeping expired files: %v", err)
+	}
+	if !reflect.DeepEqual(removed, []string{"moved.txt"}) {
+		t.Errorf("unexpected removed files: %v", removed)
+	}
+
+	// Writing a file again replaces its expiry.
+	if err = c.WriteFile(context.Background(), id, "later.txt", strings.NewReader("test")); err != nil {
+		t.Fatalf("error getting file to write: %v", err)
+	}
+	if info, err = c.StatFile(context.Background(), id, "later.txt"); err != nil {
+		t.Fatalf("unexpected error when statting file: %v", err)
+	}
+	if !info.ExpiresAt.IsZero() {
+		t.Errorf("unexpected expiry: %v", info.ExpiresAt)
+	}
+}
+
+func TestManifestDirectoryProvider(t *testing.T) {
+	parentID, err := c.Create(context.Background(), DirectoryProvider)
+	if err != nil {
+		t.Fatalf("error creating workspace: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := c.Rm(context.Background(), parentID); err != nil {
+			t.Errorf("unexpected error when removing workspace: %v", err)
+		}
+	})
+
+	before := time.Now()
+	id, err := c.CreateWithOptions(context.Background(), DirectoryProvider, CreateOptions{
+		FromWorkspaces: []string{parentID},
+		Labels:         map[string]string{"team": "search"},
+		Owner:          "alice",
+		Description:    "test workspace",
+	})
+	if err != nil {
+		t.Fatalf("error creating workspace: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := c.Rm(context.Background(), id); err != nil {
+			t.Errorf("unexpected error when removing workspace: %v", err)
+		}
+	})
+
+	info, err := c.Info(context.Background(), id)
+	if err != nil {
+		t.Fatalf("unexpected error when getting workspace info: %v", err)
+	}
+	if info.ID != id || info.Provider != DirectoryProvider || info.CreatedAt.Before(before) || info.Owner != "alice" || info.Description != "test workspace" {
+		t.Errorf("unexpected workspace info: %+v", info)
+	}
+	if !reflect.DeepEqual(info.Labels, map[string]string{"team": "search"}) {
+		t.Errorf("unexpected labels: %v", info.Labels)
+	}
+	if !reflect.DeepEqual(info.FromWorkspaces, []string{parentID}) {
+		t.Errorf("unexpected source workspaces: %v", info.FromWorkspaces)
+	}
+
+	// The manifest isn't a file in the workspace.
+	files, err := c.Ls(context.Background(), id, "")
+	if err != nil {
+		t.Fatalf("unexpected error when listing files: %v", err)
+	}
+	if len(files) != 0 {
+		t.Errorf("unexpected files: %v", files)
+	}
+
+	if err = c.SetLabels(context.Background(), id, map[string]string{"team": "ranking", "env": "dev"}); err != nil {
+		t.Fatalf("unexpected error when setting labels: %v", err)
+	}
+	if info, err = c.Info(context.Background(), id); err != nil {
+		t.Fatalf("unexpected error when getting workspace info: %v", err)
+	}
+	if !reflect.DeepEqual(info.Labels, map[string]string{"team": "ranking", "env": "dev"}) || info.Owner != "alice" {
+		t.Errorf("unexpected workspace info after setting labels: %+v", info)
+	}
+
+	if err = c.SetLabels(context.Background(), id, map[string]string{"Team": "search"}); err == nil {
+		t.Errorf("expected error when setting an invalid label")
+	}
+
+	// Removing the workspace removes its manifest, and one isn't written for a workspace that doesn't exist.
+	if err = c.Rm(context.Background(), id); err != nil {
+		t.Fatalf("unexpected error when removing workspace: %v", err)
+	}
+	nfe := (*NotFoundError)(nil)
+	if _, err = c.Info(context.Background(), id); !errors.As(err, &nfe) {
+		t.Errorf("expected not found error when getting info of removed workspace, got: %v", err)
+	}
+	if err = c.SetLabels(context.Background(), id, map[string]string{"team": "search"}); !errors.As(err, &nfe) {
+		t.Errorf("expected not found error when setting labels of removed workspace, got: %v", err)
+	}
+
+	// Workspaces that aren't directly under the data home don't exist until they have files either.
+	outside := DirectoryProvider + "://" + filepath.Join(t.TempDir(), "other")
+	if err = c.SetLabels(context.Background(), outside, map[string]string{"team": "search"}); !errors.As(err, &nfe) {
+		t.Errorf("expected not found error when setting labels of missing workspace outside of the data home, got: %v", err)
+	}
+	if err = c.SetLabels(context.Background(), parentID+"/nested", map[string]string{"team": "search"}); !errors.As(err, &nfe) {
+		t.Errorf("expected not found error when setting labels of missing nested workspace, got: %v", err)
+	}
+}
+
+func TestNestedWorkspaceDirectoryProvider(t *testing.T) {
+	parentID, err := c.Create(context.Background(), DirectoryProvider)
+	if err != nil {
+		t.Fatalf("error creating workspace: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := c.Rm(context.Background(), parentID); err != nil {
+			t.Errorf("unexpected error when removing workspace: %v", err)
+		}
+	})
+
+	// Other tests share the data home, so the workspaces of this test are found by a label unique to it.
+	labels := map[string]string{"run": fmt.Sprintf("nested_%d", time.Now().UnixNano())}
+
+	outsideDir := filepath.Join(t.TempDir(), "outside")
+	for _, id := range []string{parentID + "/nested", DirectoryProvider + "://" + outsideDir} {
+		if err = c.WriteFile(context.Background(), id, "test.txt", strings.NewReader("test")); err != nil {
+			t.Fatalf("unexpected error when writing file to %s: %v", id, err)
+		}
+		if err = c.SetLabels(context.Background(), id, labels); err != nil {
+			t.Fatalf("unexpected error when setting labels of %s: %v", id, err)
+		}
+
+		info, err := c.Info(context.Background(), id)
+		if err != nil {
+			t.Fatalf("unexpected error when getting info of %s: %v", id, err)
+		}
+		if !reflect.DeepEqual(info.Labels, labels) {
+			t.Errorf("unexpected labels of %s: %v", id, info.Labels)
+		}
+	}
+
+	// Only the workspaces directly under the data home are listed.
+	page, err := c.List(context.Background(), DirectoryProvider, ListOptions{Labels: labels})
+	if err != nil {
+		t.Fatalf("unexpected error when listing workspaces: %v", err)
+	}
+	if len(page.Workspaces) != 0 {
+		t.Errorf("unexpected workspaces listed: %+v", page.Workspaces)
+	}
+
+	for _, id := range []string{parentID + "/nested", DirectoryProvider + "://" + outsideDir} {
+		if err = c.Rm(context.Background(), id); err != nil {
+			t.Fatalf("unexpected error when removing %s: %v", id, err)
+		}
+	}
+
+	// The nested workspace and its manifest are removed.
+	if _, err = c.Info(context.Background(), parentID+"/nested"); err == nil {
+		t.Errorf("expected nested workspace to be removed")
+	} else if nfe := (*NotFoundError)(nil); !errors.As(err, &nfe) {
+		t.Errorf("expected not found error when getting info of removed nested workspace, got: %v", err)
+	}
+	if files, err := c.Ls(context.Background(), parentID, ""); err != nil {
+		t.Errorf("unexpected error when listing files: %v", err)
+	} else if len(files) != 0 {
+		t.Errorf("unexpected files in parent workspace: %v", files)
+	}
+
+	// Directories outside the data home are left in place, but their manifests are removed.
+	if _, err = os.Stat(filepath.Join(outsideDir, "test.txt")); err != nil {
+		t.Errorf("expected file outside of the data home to be kept: %v", err)
+	}
+	if info, err := c.Info(context.Background(), DirectoryProvider+"://"+outsideDir); err != nil {
+		t.Errorf("unexpected error when getting info of workspace outside of the data home: %v", err)
+	} else if info.Labels != nil {
+		t.Errorf("expected manifest of workspace outside of the data home to be removed: %+v", info)
+	}
+}
+
+func TestListDirectoryProvider(t *testing.T) {
+	// Other tests share the data home, so the workspaces of this test are found by a label unique to it.
+	run := fmt.Sprintf("list_%d", time.Now().UnixNano())
+
+	var ids []string
+	for i := range 4 {
+		opt := CreateOptions{Labels: map[string]string{"run": run}}
+		if i == 3 {
+			opt.Labels["other"] = "true"
+		}
+
+		id, err := c.CreateWithOptions(context.Background(), DirectoryProvider, opt)
+		if err != nil {
+			t.Fatalf("error creating workspace: %v", err)
+		}
+		ids = append(ids, id)
+
+		t.Cleanup(func() {
+			if err := c.Rm(context.Background(), id); err != nil {
+				t.Errorf("unexpected error when removing workspace: %v", err)
+			}
+		})
+	}
+
+	// A workspace with files is listed once, along with its manifest.
+	if err := c.WriteFile(context.Background(), ids[0], "test.txt", strings.NewReader("test")); err != nil {
+		t.Fatalf("error getting file to write: %v", err)
+	}
+
+	var listed []string
+	opt := ListOptions{Labels: map[string]string{"run": run}, Limit: 3}
+	for pages := 0; ; pages++ {
+		if pages > 1 {
+			t.Fatalf("unexpected number of pages")
+		}
+
+		page, err := c.List(context.Background(), DirectoryProvider, opt)
+		if err != nil {
+			t.Fatalf("unexpected error when listing workspaces: %v", err)
+		}
+		if pages == 0 && (len(page.Workspaces) != 3 || page.Continuation == "") {
+			t.Errorf("unexpected first page: %+v", page)
+		}
+
+		for _, info := range page.Workspaces {
+			if info.CreatedAt.IsZero() || info.Labels["run"] != run {
+				t.Errorf("unexpected workspace info: %+v", info)
+			}
+			listed = append(listed, info.ID)
+		}
+
+		if page.Continuation == "" {
+			break
+		}
+		opt.Continuation = page.Continuation
+	}
+
+	slices.Sort(ids)
+	if !reflect.DeepEqual(listed, ids) {
+		t.Errorf("unexpected workspaces: %v, expected: %v", listed, ids)
+	}
+
+	page, err := c.List(context.Background(), DirectoryProvider, ListOptions{Labels: map[string]string{"run": run, "other": "true"}})
+	if err != nil {
+		t.Fatalf("unexpected error when listing workspaces: %v", err)
+	}
+	if len(page.Workspaces) != 1 || page.Continuation != "" {
+		t.Errorf("unexpected workspaces with labels: %+v", page)
+	}
+}
+
+func TestUsageDirectoryProvider(t *testing.T) {
+	id, err := quotaClient.CreateWithOptions(context.Background(), DirectoryProvider, CreateOptions{Quota: Quota{MaxBytes: 20, MaxFiles: 2, MaxFileSize: 10}})
+	if err != nil {
+		t.Fatalf("error creating workspace: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := quotaClient.Rm(context.Background(), id); err != nil {
+			t.Errorf("unexpected error when removing workspace: %v", err)
+		}
+	})
+
+	checkUsage := func(expected Usage) {
+		t.Helper()
+		usage, err := quotaClient.Usage(context.Background(), id)
+		if err != nil {
+			t.Fatalf("unexpected error when getting usage: %v", err)
+		}
+		if usage != expected {
+			t.Errorf("unexpected usage: %+v, expected: %+v", usage, expected)
+		}
+	}
+	checkQuotaExceeded := func(err error, limit string) {
+		t.Helper()
+		if qee := (*QuotaExceededError)(nil); !errors.As(err, &qee) || qee.Limit() != limit {
+			t.Errorf("expected %s quota to be exceeded, got: %v", limit, err)
+		}
+	}
+
+	if err = quotaClient.WriteFile(context.Background(), id, "a.txt", strings.NewReader("hello")); err != nil {
+		t.Fatalf("unexpected error when writing file: %v", err)
+	}
+	if err = quotaClient.WriteFile(context.Background(), id, "a.txt", strings.NewReader("hello!")); err != nil {
+		t.Fatalf("unexpected error when writing file: %v", err)
+	}
+	checkUsage(Usage{Files: 1, Bytes: 6, RevisionFiles: 1, RevisionBytes: 5})
+
+	err = quotaClient.WriteFile(context.Background(), id, "b.txt", strings.NewReader("hello world"))
+	checkQuotaExceeded(err, QuotaMaxFileSize)
+
+	// Content that can't seek is counted as it is written, and nothing is written if it exceeds the quota.
+	err = quotaClient.WriteFile(context.Background(), id, "b.txt", io.MultiReader(strings.NewReader("hello"), strings.NewReader("world")))
+	checkQuotaExceeded(err, QuotaMaxBytes)
+	if _, err = quotaClient.StatFile(context.Background(), id, "b.txt"); err == nil {
+		t.Errorf("expected file not to be written")
+	}
+
+	if err = quotaClient.WriteFile(context.Background(), id, "b.txt", io.MultiReader(strings.NewReader("abc"))); err != nil {
+		t.Fatalf("unexpected error when writing file: %v", err)
+	}
+	checkUsage(Usage{Files: 2, Bytes: 9, RevisionFiles: 1, RevisionBytes: 5})
+
+	err = quotaClient.WriteFile(context.Background(), id, "c.txt", strings.NewReader("c"))
+	checkQuotaExceeded(err, QuotaMaxFiles)
+
+	// Appending with a revision also stores the previous content.
+	err = quotaClient.AppendFile(context.Background(), id, "b.txt", strings.NewReader("defgh"))
+	checkQuotaExceeded(err, QuotaMaxBytes)
+	if err = quotaClient.AppendFile(context.Background(), id, "b.txt", strings.NewReader("defgh"), AppendOptions{CreateRevision: &[]bool{false}[0]}); err != nil {
+		t.Fatalf("unexpected error when appending to file: %v", err)
+	}
+	checkUsage(Usage{Files: 2, Bytes: 14, RevisionFiles: 1, RevisionBytes: 5})
+
+	if err = quotaClient.DeleteFile(context.Background(), id, "a.txt"); err != nil {
+		t.Fatalf("unexpected error when deleting file: %v", err)
+	}
+	checkUsage(Usage{Files: 1, Bytes: 8})
+
+	usage, err := quotaClient.Usage(context.Background(), id, UsageOptions{Recompute: true})
+	if err != nil {
+		t.Fatalf("unexpected error when recomputing usage: %v", err)
+	}
+	if usage != (Usage{Files: 1, Bytes: 8}) {
+		t.Errorf("unexpected recomputed usage: %+v", usage)
+	}
+
+	// Bulk removals aren't counted, so the usage is recomputed.
+	if _, err = quotaClient.RemoveMatching(context.Background(), id, RemoveMatchingOptions{Include: []string{"**"}}); err != nil {
+		t.Fatalf("unexpected error when removing files: %v", err)
+	}
+	checkUsage(Usage{})
+
+	if err = quotaClient.WriteFile(context.Background(), id, "b.txt", strings.NewReader("12345678")); err != nil {
+		t.Fatalf("unexpected error when writing file: %v", err)
+	}
+
+	_, err = quotaClient.CreateWithOptions(context.Background(), DirectoryProvider, CreateOptions{FromWorkspaces: []string{id}, Quota: Quota{MaxBytes: 5}})
+	checkQuotaExceeded(err, QuotaMaxBytes)
+
+	if err = quotaClient.SetQuota(context.Background(), id, Quota{MaxFileSize: 4}); err != nil {
+		t.Fatalf("unexpected error when setting quota: %v", err)
+	}
+	if quota, err := quotaClient.Quota(context.Background(), id); err != nil || quota != (Quota{MaxFileSize: 4}) {
+		t.Errorf("unexpected quota: %+v, %v", quota, err)
+	}
+	err = quotaClient.AppendFile(context.Background(), id, "b.txt", strings.NewReader("9"))
+	checkQuotaExceeded(err, QuotaMaxFileSize)
+
+	if err = quotaClient.SetQuota(context.Background(), id, Quota{MaxBytes: -1}); err == nil {
+		t.Errorf("expected error when setting a negative quota")
+	} else if iae := (*InvalidArgumentError)(nil); !errors.As(err, &iae) {
+		t.Errorf("expected invalid argument error, got: %v", err)
+	}
+
+	// Batches are charged their net change before they are applied.
+	if err = quotaClient.SetQuota(context.Background(), id, Quota{MaxBytes: 10}); err != nil {
+		t.Fatalf("unexpected error when setting quota: %v", err)
+	}
+	err = quotaClient.Batch(context.Background(), id, []BatchOperation{{Op: BatchWrite, FileName: "c.txt", Content: strings.NewReader("abc")}})
+	checkQuotaExceeded(err, QuotaMaxBytes)
+	if _, err = quotaClient.StatFile(context.Background(), id, "c.txt"); err == nil {
+		t.Errorf("expected file not to be written")
+	}
+	if err = quotaClient.Batch(context.Background(), id, []BatchOperation{
+		{Op: BatchDelete, FileName: "b.txt"},
+		{Op: BatchWrite, FileName: "c.txt", Content: strings.NewReader("abcdefghij")},
+	}); err != nil {
+		t.Fatalf("unexpected error when applying batch: %v", err)
+	}
+	checkUsage(Usage{Files: 1, Bytes: 10})
+}
+
+func TestUsageWithoutQuotasDirectoryProvider(t *testing.T) {
+	id, err := c.Create(context.Background(), DirectoryProvider)
+	if err != nil {
+		t.Fatalf("error creating workspace: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := c.Rm(context.Background(), id); err != nil {
+			t.Errorf("unexpected error when removing workspace: %v", err)
+		}
+	})
+
+	if err = c.WriteFile(context.Background(), id, "a.txt", strings.NewReader("hello")); err != nil {
+		t.Fatalf("unexpected error when writing file: %v", err)
+	}
+
+	// Without quotas, the usage is computed when it is asked for instead of being recorded.
+	factory, _, err := c.getWorkspaceFactory(context.Background(), id)
+	if err != nil {
+		t.Fatalf("unexpected error getting workspace factory: %v", err)
+	}
+	uc, name, err := usageClient(factory, id)
+	if err != nil {
+		t.Fatalf("unexpected error getting usage client: %v", err)
+	}
+	if _, err = uc.StatFile(context.Background(), name, StatOptions{}); err == nil {
+		t.Errorf("expected usage not to be recorded")
+	} else if nfe := (*NotFoundError)(nil); !errors.As(err, &nfe) {
+		t.Errorf("expected not found error when statting usage record, got: %v", err)
+	}
+
+	if usage, err := c.Usage(context.Background(), id); err != nil {
+		t.Errorf("unexpected error when getting usage: %v", err)
+	} else if usage.Files != 1 || usage.Bytes != 5 {
+		t.Errorf("unexpected usage: %+v", usage)
+	}
+	if quota, err := c.Quota(context.Background(), id); err != nil || quota != (Quota{}) {
+		t.Errorf("unexpected quota: %+v, %v", quota, err)
+	}
+
+	// Quotas can't be set on workspaces of a provider without quotas.
+	iae := (*InvalidArgumentError)(nil)
+	if err = c.SetQuota(context.Background(), id, Quota{MaxFiles: 1}); !errors.As(err, &iae) {
+		t.Errorf("expected invalid argument error when setting quota, got: %v", err)
+	}
+	if _, err = c.CreateWithOptions(context.Background(), DirectoryProvider, CreateOptions{Quota: Quota{MaxFiles: 1}}); !errors.As(err, &iae) {
+		t.Errorf("expected invalid argument error when creating workspace with a quota, got: %v", err)
+	}
+}
+
+func TestUsageConcurrentWritesDirectoryProvider(t *testing.T) {
+	id, err := quotaClient.CreateWithOptions(context.Background(), DirectoryProvider, CreateOptions{Quota: Quota{MaxFiles: 3}})
+	if err != nil {
+		t.Fatalf("error creating workspace: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := quotaClient.Rm(context.Background(), id); err != nil {
+			t.Errorf("unexpected error when removing workspace: %v", err)
+		}
+	})
+
+	// The usage is locked while it is checked and updated, so concurrent writes can't all pass the quota check.
+	var wg sync.WaitGroup
+	errs := make([]error, 50)
+	for i := range errs {
+		wg.Go(func() {
+			errs[i] = quotaClient.WriteFile(context.Background(), id, fmt.Sprintf("%d.txt", i), io.MultiReader(strings.NewReader("test")))
+		})
+	}
+	wg.Wait()
+
+	var written int
+	for _, err := range errs {
+		if err == nil {
+			written++
+		} else if qee := (*QuotaExceededError)(nil); !errors.As(err, &qee) || qee.Limit() != QuotaMaxFiles {
+			t.Errorf("expected files quota to be exceeded, got: %v", err)
+		}
+	}
+	if written != 3 {
+		t.Errorf("unexpected number of files written: %d", written)
+	}
+
+	usage, err := quotaClient.Usage(context.Background(), id)
+	if err != nil {
+		t.Fatalf("unexpected error when getting usage: %v", err)
+	}
+	if usage != (Usage{Files: 3, Bytes: 12}) {
+		t.Errorf("unexpected usage: %+v", usage)
+	}
+}
+
+func TestNamesDirectoryProvider(t *testing.T) {
+	// Other tests share the data home, so the names of this test are unique to it.
+	name := fmt.Sprintf("names-%d", time.Now().UnixNano())
+
+	id, err := c.CreateWithOptions(context.Background(), DirectoryProvider, CreateOptions{Name: name})
+	if err != nil {
+		t.Fatalf("error creating workspace: %v", err)
+	}
+	removed := false
+	t.Cleanup(func() {
+		if removed {
+			return
+		}
+		if err := c.Rm(context.Background(), id); err != nil {
+			t.Errorf("unexpected error when removing workspace: %v", err)
+		}
+	})
+
+	for _, n := range []string{name, NamePrefix + name} {
+		if resolved, err := c.Resolve(context.Background(), n); err != nil || resolved != id {
+			t.Errorf("unexpected resolved ID for %s: %s, %v", n, resolved, err)
+		}
+	}
+
+	// The name can be given instead of the ID.
+	if err = c.WriteFile(context.Background(), NamePrefix+name, "test.txt", strings.NewReader("test")); err != nil {
+		t.Fatalf("unexpected error when writing file by name: %v", err)
+	}
+	if _, err = c.StatFile(context.Background(), id, "test.txt"); err != nil {
+		t.Errorf("unexpected error when statting file: %v", err)
+	}
+
+	info, err := c.Info(context.Background(), NamePrefix+name)
+	if err != nil {
+		t.Fatalf("unexpected error when getting workspace info: %v", err)
+	}
+	if info.ID != id || info.Name != name {
+		t.Errorf("unexpected workspace info: %+v", info)
+	}
+
+	_, err = c.CreateWithOptions(context.Background(), DirectoryProvider, CreateOptions{Name: name})
+	if nee := (*NameExistsError)(nil); !errors.As(err, &nee) {
+		t.Errorf("expected name exists error, got: %v", err)
+	}
+
+	if _, err = c.CreateWithOptions(context.Background(), DirectoryProvider, CreateOptions{Name: "Not a name"}); err == nil {
+		t.Errorf("expected error when creating workspace with an invalid name")
+	}
+
+	newName := name + "-renamed"
+	if err = c.Rename(context.Background(), NamePrefix+name, newName); err != nil {
+		t.Fatalf("unexpected error when renaming workspace: %v", err)
+	}
+	if _, err = c.Resolve(context.Background(), name); err == nil {
+		t.Errorf("expected old name not to resolve")
+	}
+
+	names, err := c.Names(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error when listing names: %v", err)
+	}
+	if !slices.Contains(names, WorkspaceName{Name: newName, ID: id}) || slices.ContainsFunc(names, func(n WorkspaceName) bool { return n.Name == name }) {
+		t.Errorf("unexpected names: %v", names)
+	}
+
+	// Removing the workspace removes its name.
+	if err = c.Rm(context.Background(), NamePrefix+newName); err != nil {
+		t.Fatalf("unexpected error when removing workspace by name: %v", err)
+	}
+	removed = true
+
+	_, err = c.Resolve(context.Background(), newName)
+	if nfe := (*NotFoundError)(nil); !errors.As(err, &nfe) {
+		t.Errorf("expected not found error, got: %v", err)
+	}
+}
+
+func TestNamesConcurrentDirectoryProvider(t *testing.T) {
+	// Other tests share the data home, so the names of this test are unique to it.
+	name := fmt.Sprintf("names-concurrent-%d", time.Now().UnixNano())
+
+	// Names are registered atomically, so only one of the workspaces created concurrently with the same name gets it.
+	var wg sync.WaitGroup
+	ids, errs := make([]string, 10), make([]error, 10)
+	for i := range errs {
+		wg.Go(func() {
+			ids[i], errs[i] = c.CreateWithOptions(context.Background(), DirectoryProvider, CreateOptions{Name: name})
+		})
+	}
+	wg.Wait()
+
+	var id string
+	for i, err := range errs {
+		if err == nil {
+			if id != "" {
+				t.Errorf("expected only one workspace to be created with name %s", name)
+			}
+			id = ids[i]
+		} else if nee := (*NameExistsError)(nil); !errors.As(err, &nee) {
+			t.Errorf("expected name exists error, got: %v", err)
+		}
+	}
+	if id == "" {
+		t.Fatalf("expected a workspace to be created with name %s", name)
+	}
+	t.Cleanup(func() {
+		if err := c.Rm(context.Background(), id); err != nil {
+			t.Errorf("unexpected error when removing workspace: %v", err)
+		}
+	})
+
+	if resolved, err := c.Resolve(context.Background(), name); err != nil || resolved != id {
+		t.Errorf("unexpected resolved ID: %s, %v", resolved, err)
+	}
+
+	if err := c.WriteFile(context.Background(), id, "test.txt", strings.NewReader("test")); err != nil {
+		t.Fatalf("unexpected error when writing file: %v", err)
+	}
+
+	// The name is released if the workspace can't be created.
+	otherName := name + "-other"
+	_, err := quotaClient.CreateWithOptions(context.Background(), DirectoryProvider, CreateOptions{Name: otherName, FromWorkspaces: []string{id}, Quota: Quota{MaxBytes: 1}})
+	if qee := (*QuotaExceededError)(nil); !errors.As(err, &qee) {
+		t.Errorf("expected quota exceeded error, got: %v", err)
+	}
+	if _, err = c.Resolve(context.Background(), otherName); err == nil {
+		t.Errorf("expected name of the workspace that wasn't created not to resolve")
+	}
+
+	otherID, err := c.CreateWithOptions(context.Background(), DirectoryProvider, CreateOptions{Name: otherName})
+	if err != nil {
+		t.Fatalf("error creating workspace: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := c.Rm(context.Background(), otherID); err != nil {
+			t.Errorf("unexpected error when removing workspace: %v", err)
+		}
+	})
+
+	// Renaming to a taken name keeps the workspace's name.
+	if err = c.Rename(context.Background(), otherID, name); err == nil {
+		t.Errorf("expected error when renaming to a taken name")
+	} else if nee := (*NameExistsError)(nil); !errors.As(err, &nee) {
+		t.Errorf("expected name exists error, got: %v", err)
+	}
+	if resolved, err := c.Resolve(context.Background(), otherName); err != nil || resolved != otherID {
+		t.Errorf("unexpected resolved ID: %s, %v", resolved, err)
+	}
+
+	// A workspace that doesn't exist can't be renamed.
+	missing, err := c.Create(context.Background(), DirectoryProvider)
+	if err != nil {
+		t.Fatalf("error creating workspace: %v", err)
+	}
+	if err = c.Rm(context.Background(), missing); err != nil {
+		t.Fatalf("unexpected error when removing workspace: %v", err)
+	}
+	if err = c.Rename(context.Background(), missing, name+"-missing"); err == nil {
+		t.Errorf("expected error when renaming a workspace that doesn't exist")
+	} else if nfe := (*NotFoundError)(nil); !errors.As(err, &nfe) {
+		t.Errorf("expected not found error, got: %v", err)
+	}
+	if _, err = c.Resolve(context.Background(), name+"-missing"); err == nil {
+		t.Errorf("expected name of the workspace that doesn't exist not to resolve")
+	}
+}
+
+func TestReapSeparateRevisionsStoreDirectoryProvider(t *testing.T) {
+	store := t.TempDir()
+	rc, err := New(context.Background(), Options{DirectoryDataHome: t.TempDir(), RevisionsStore: DirectoryProvider + "://" + store})
+	if err != nil {
+		t.Fatalf("unexpected error when creating client: %v", err)
+	}
+
+	var revisionsPaths []string
+	for range 2 {
+		id, err := rc.CreateWithOptions(context.Background(), DirectoryProvider, CreateOptions{ExpiresAt: time.Now().Add(-time.Second)})
+		if err != nil {
+			t.Fatalf("error creating workspace: %v", err)
+		}
+
+		// Writing twice records a revision in the separate store.
+		for _, content := range []string{"one", "two"} {
+			if err = rc.WriteFile(context.Background(), id, "test.txt", strings.NewReader(content)); err != nil {
+				t.Fatalf("unexpected error when writing file: %v", err)
+			}
+		}
+		revisionsPath := filepath.Join(store, DirectoryProvider, filepath.Base(id))
+		if _, err = os.Stat(revisionsPath); err != nil {
+			t.Fatalf("expected revisions to exist: %v", err)
+		}
+		revisionsPaths = append(revisionsPaths, revisionsPath)
+
+		if len(revisionsPaths) == 2 {
+			// The revisions of a workspace whose directory is already gone are still removed.
+			if err = os.RemoveAll(strings.TrimPrefix(id, DirectoryProvider+"://")); err != nil {
+				t.Fatalf("unexpected error when removing workspace directory: %v", err)
+			}
+		}
+	}
+
+	removed, err := rc.ReapExpired(context.Background(), DirectoryProvider)
+	if err != nil {
+		t.Fatalf("unexpected error when reaping workspaces: %v", err)
+	}
+	if len(removed) != 2 {
+		t.Errorf("unexpected reaped workspaces: %v", removed)
+	}
+
+	for _, revisionsPath := range revisionsPaths {
+		if _, err = os.Stat(revisionsPath); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("expected revisions to be removed: %v", err)
+		}
+	}
+}
+
+func TestReapDirectoryProvider(t *testing.T) {
+	expired, err := c.CreateWithOptions(context.Background(), DirectoryProvider, CreateOptions{ExpiresAt: time.Now().Add(-time.Second)})
+	if err != nil {
+		t.Fatalf("error creating workspace: %v", err)
+	}
+	live, err := c.CreateWithOptions(context.Background(), DirectoryProvider, CreateOptions{TTL: time.Hour})
+	if err != nil {
+		t.Fatalf("error creating workspace: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, id := range []string{expired, live} {
+			if err := c.Rm(context.Background(), id); err != nil {
+				t.Errorf("unexpected error when removing workspace: %v", err)
+			}
+		}
+	})
+
+	if _, err = c.CreateWithOptions(context.Background(), DirectoryProvider, CreateOptions{ExpiresAt: time.Now(), TTL: time.Hour}); err == nil {
+		t.Errorf("expected error when creating workspace with both an expiry time and TTL")
+	} else if iae := (*InvalidArgumentError)(nil); !errors.As(err, &iae) {
+		t.Errorf("expected invalid argument error, got: %v", err)
+	}
+	if _, err = c.CreateWithOptions(context.Background(), DirectoryProvider, CreateOptions{TTL: -time.Hour}); err == nil {
+		t.Errorf("expected error when creating workspace with a negative TTL")
+	} else if iae := (*InvalidArgumentError)(nil); !errors.As(err, &iae) {
+		t.Errorf("expected invalid argument error, got: %v", err)
+	}
+
+	info, err := c.Info(context.Background(), live)
+	if err != nil {
+		t.Fatalf("unexpected error when getting workspace info: %v", err)
+	}
+	if info.ExpiresAt.Before(time.Now().Add(59*time.Minute)) || info.ExpiresAt.After(time.Now().Add(time.Hour)) {
+		t.Errorf("unexpected expiry: %v", info.ExpiresAt)
+	}
+
+	// Writing twice records a revision, which is removed along with the workspace.
+	for _, content := range []string{"one", "two"} {
+		if err = c.WriteFile(context.Background(), expired, "test.txt", strings.NewReader(content)); err != nil {
+			t.Fatalf("unexpected error when writing file: %v", err)
+		}
+	}
+	revisionsPath := filepath.Join(filepath.Dir(strings.TrimPrefix(expired, DirectoryProvider+"://")), revisionsDir, filepath.Base(expired))
+	if _, err = os.Stat(revisionsPath); err != nil {
+		t.Fatalf("expected revisions to exist: %v", err)
+	}
+
+	removed, err := c.ReapExpired(context.Background(), DirectoryProvider, ReapOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("unexpected error when reaping workspaces: %v", err)
+	}
+	if !slices.Contains(removed, expired) || slices.Contains(removed, live) {
+		t.Errorf("unexpected workspaces to reap: %v", removed)
+	}
+	if _, err = c.StatFile(context.Background(), expired, "test.txt"); err != nil {
+		t.Errorf("expected workspace not to be removed by a dry run: %v", err)
+	}
+
+	if removed, err = c.ReapExpired(context.Background(), DirectoryProvider); err != nil {
+		t.Fatalf("unexpected error when reaping workspaces: %v", err)
+	}
+	if !slices.Contains(removed, expired) || slices.Contains(removed, live) {
+		t.Errorf("unexpected reaped workspaces: %v", removed)
+	}
+
+	if _, err = c.StatFile(context.Background(), expired, "test.txt"); err == nil {
+		t.Errorf("expected workspace to be removed")
+	}
+	if _, err = os.Stat(revisionsPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected revisions to be removed: %v", err)
+	}
+	if _, err = c.Info(context.Background(), expired); err == nil {
+		t.Errorf("expected manifest to be removed")
+	} else if nfe := (*NotFoundError)(nil); !errors.As(err, &nfe) {
+		t.Errorf("expected not found error when getting info of reaped workspace, got: %v", err)
+	}
+}
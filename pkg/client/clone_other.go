@@ -0,0 +1,12 @@
+//go:build !linux
+
+package client
+
+import (
+	"errors"
+	"os"
+)
+
+func cloneFile(_, _ *os.File) error {
+	return errors.ErrUnsupported
+}
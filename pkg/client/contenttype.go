@@ -0,0 +1,118 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// contentTypeXattr is the extended attribute that the content type of a file is stored in on disk.
+const contentTypeXattr = "user.workspace-provider.content-type"
+
+// fileContentType returns the content type stored with a file on disk, or nothing if there isn't one or the file system
+// doesn't support extended attributes.
+func fileContentType(f *os.File) string {
+	return fileAttr(f, contentTypeXattr)
+}
+
+// pathContentType returns the content type stored with the file at the path, like fileContentType.
+func pathContentType(path string) string {
+	return pathAttr(path, contentTypeXattr)
+}
+
+// setFileContentType stores the content type with a file on disk, or removes it if the content type is empty. This is
+// best effort, since not all file systems support extended attributes.
+func setFileContentType(f *os.File, contentType string) {
+	setFileAttr(f, contentTypeXattr, contentType)
+}
+
+// detectContentType returns the mime type of content from its first bytes, without parameters such as the charset.
+func detectContentType(head []byte) string {
+	return strings.Split(mimetype.Detect(head).String(), ";")[0]
+}
+
+// sniffContentType returns the content type of the reader's content, unless one is given, along with a reader of the
+// whole content.
+func sniffContentType(r io.Reader, contentType string) (string, io.Reader) {
+	if contentType != "" {
+		return contentType, r
+	}
+
+	br := bufio.NewReaderSize(r, sniffLength)
+	// An error reading is returned when the content is read.
+	head, _ := br.Peek(sniffLength)
+	return detectContentType(head), br
+}
+
+// seekContentType returns the content type of the reader's content, unless one is given, and then seeks back to the
+// start.
+func seekContentType(r io.ReadSeeker, contentType string) (string, error) {
+	if contentType != "" {
+		return contentType, nil
+	}
+
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", err
+	}
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	return detectContentType(head[:n]), nil
+}
+
+// storedContentType returns the content type that S3 or Azure stored with an object, or nothing if the object was
+// written without one. Objects written without one have a default content type and no checksum.
+func storedContentType(contentType *string, checksum string) string {
+	if contentType == nil {
+		return ""
+	}
+
+	switch *contentType {
+	case "":
+		return ""
+	case "application/octet-stream", "binary/octet-stream":
+		if checksum == "" {
+			return ""
+		}
+	}
+
+	return *contentType
+}
+
+// BackfillContentTypes stores the content type of each file in a workspace that was written before content types were
+// stored, so that statting it no longer reads its content. It returns the number of files that were updated.
+func (c *Client) BackfillContentTypes(ctx context.Context, id string) (int, error) {
+	wc, id, err := c.getClient(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+
+	var updated int
+	for info, err := range c.LsIter(ctx, id, "") {
+		if err != nil {
+			return updated, err
+		}
+
+		ok, err := wc.BackfillContentType(ctx, info.Name)
+		if err != nil {
+			if nfe := (*NotFoundError)(nil); errors.As(err, &nfe) {
+				// The file was deleted since it was listed.
+				continue
+			}
+			return updated, err
+		}
+		if ok {
+			updated++
+		}
+	}
+
+	return updated, nil
+}
@@ -0,0 +1,36 @@
+package client
+
+import (
+	"context"
+)
+
+// copyFile writes a copy of a file to destFileName in dest, recording a revision of the destination as WriteFile does.
+// copyContent copies the content itself without streaming it through this process.
+func copyFile(ctx context.Context, source workspaceClient, fileName string, dest workspaceClient, destID, destFileName string, opt WriteOptions, copyContent func(context.Context) error) error {
+	// Check that the source exists before recording a revision of the destination.
+	if _, err := statNonDir(ctx, source, fileName); err != nil {
+		return err
+	}
+
+	if rc := dest.RevisionClient(); rc != nil {
+		if err := recordWrite(ctx, rc, dest, destID, destFileName, opt); err != nil {
+			return err
+		}
+	}
+
+	return copyContent(ctx)
+}
+
+// streamFile writes a copy of a file to destFileName in dest by reading it through this process. It is used when the
+// source and destination are on different backends.
+func streamFile(ctx context.Context, source workspaceClient, fileName string, dest workspaceClient, destFileName string, opt WriteOptions) error {
+	f, err := source.OpenFile(ctx, fileName, OpenOptions{})
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// The copy keeps the metadata and expiry of the file, as copies within a backend do.
+	opt.Metadata, opt.ExpiresAt = f.Metadata, f.ExpiresAt
+	return dest.WriteFile(ctx, destFileName, f, opt)
+}
@@ -0,0 +1,151 @@
+package client
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	deltaMagic     = "WPD1"
+	deltaBlockSize = 16
+	deltaHashPrime = 16777619
+
+	deltaOpInsert byte = 0
+	deltaOpCopy   byte = 1
+)
+
+// encodeDelta returns a delta that produces target when applied to base. The encoding is a sequence of copy
+// operations, referencing ranges of base, and insert operations, carrying literal bytes from target.
+func encodeDelta(base, target []byte) []byte {
+	out := append([]byte(deltaMagic), binary.AppendUvarint(nil, uint64(len(target)))...)
+
+	if len(base) < deltaBlockSize || len(target) < deltaBlockSize {
+		return appendInsert(out, target)
+	}
+
+	// Index the base by the hash of each aligned block.
+	index := make(map[uint32]int, len(base)/deltaBlockSize)
+	for off := 0; off+deltaBlockSize <= len(base); off += deltaBlockSize {
+		h := blockHash(base[off : off+deltaBlockSize])
+		if _, ok := index[h]; !ok {
+			index[h] = off
+		}
+	}
+
+	// The multiplier of the byte that leaves the rolling hash window.
+	var outFactor uint32 = 1
+	for range deltaBlockSize - 1 {
+		outFactor *= deltaHashPrime
+	}
+
+	var (
+		insertStart int
+		i           int
+		h           = blockHash(target[:deltaBlockSize])
+	)
+	for i+deltaBlockSize <= len(target) {
+		off, ok := index[h]
+		if ok && bytes.Equal(base[off:off+deltaBlockSize], target[i:i+deltaBlockSize]) {
+			// Extend the match backwards into the pending insert, and then forwards as far as possible.
+			for i > insertStart && off > 0 && base[off-1] == target[i-1] {
+				i--
+				off--
+			}
+			n := deltaBlockSize
+			for off+n < len(base) && i+n < len(target) && base[off+n] == target[i+n] {
+				n++
+			}
+
+			out = appendInsert(out, target[insertStart:i])
+			out = append(out, deltaOpCopy)
+			out = binary.AppendUvarint(out, uint64(off))
+			out = binary.AppendUvarint(out, uint64(n))
+
+			i += n
+			insertStart = i
+			if i+deltaBlockSize <= len(target) {
+				h = blockHash(target[i : i+deltaBlockSize])
+			}
+			continue
+		}
+
+		if i+deltaBlockSize < len(target) {
+			h = (h-uint32(target[i])*outFactor)*deltaHashPrime + uint32(target[i+deltaBlockSize])
+		}
+		i++
+	}
+
+	return appendInsert(out, target[insertStart:])
+}
+
+// applyDelta reconstructs the target from base and a delta produced by encodeDelta.
+func applyDelta(base, delta []byte) ([]byte, error) {
+	if !bytes.HasPrefix(delta, []byte(deltaMagic)) {
+		return nil, errors.New("invalid delta: missing header")
+	}
+	delta = delta[len(deltaMagic):]
+
+	size, n := binary.Uvarint(delta)
+	if n <= 0 {
+		return nil, errors.New("invalid delta: bad target size")
+	}
+	delta = delta[n:]
+
+	target := make([]byte, 0, size)
+	for len(delta) > 0 {
+		op := delta[0]
+		delta = delta[1:]
+
+		switch op {
+		case deltaOpInsert:
+			length, n := binary.Uvarint(delta)
+			if n <= 0 || uint64(len(delta)-n) < length {
+				return nil, errors.New("invalid delta: bad insert")
+			}
+			target = append(target, delta[n:n+int(length)]...)
+			delta = delta[n+int(length):]
+		case deltaOpCopy:
+			off, n := binary.Uvarint(delta)
+			if n <= 0 {
+				return nil, errors.New("invalid delta: bad copy offset")
+			}
+			delta = delta[n:]
+
+			length, n := binary.Uvarint(delta)
+			if n <= 0 || off > uint64(len(base)) || uint64(len(base))-off < length {
+				return nil, errors.New("invalid delta: bad copy length")
+			}
+			delta = delta[n:]
+
+			target = append(target, base[off:off+length]...)
+		default:
+			return nil, fmt.Errorf("invalid delta: unknown operation %d", op)
+		}
+	}
+
+	if uint64(len(target)) != size {
+		return nil, fmt.Errorf("invalid delta: expected %d bytes, got %d", size, len(target))
+	}
+
+	return target, nil
+}
+
+func appendInsert(out, data []byte) []byte {
+	if len(data) == 0 {
+		return out
+	}
+
+	out = append(out, deltaOpInsert)
+	out = binary.AppendUvarint(out, uint64(len(data)))
+	return append(out, data...)
+}
+
+func blockHash(b []byte) uint32 {
+	var h uint32
+	for _, c := range b {
+		h = h*deltaHashPrime + uint32(c)
+	}
+	return h
+}
@@ -0,0 +1,60 @@
+package client
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestDeltaRoundTrip(t *testing.T) {
+	random := make([]byte, 4096)
+	_, _ = rand.New(rand.NewSource(1)).Read(random)
+
+	log := strings.Repeat("2024-01-01T00:00:00Z INFO something happened\n", 100)
+
+	tests := []struct {
+		name         string
+		base, target []byte
+		maxDeltaSize int
+	}{
+		{name: "empty", base: nil, target: nil},
+		{name: "small", base: []byte("abc"), target: []byte("abcd")},
+		{name: "identical", base: random, target: random, maxDeltaSize: 16},
+		{name: "appended", base: []byte(log), target: []byte(log + "2024-01-01T00:00:01Z INFO something else happened\n"), maxDeltaSize: 80},
+		{name: "truncated", base: random, target: random[:3000], maxDeltaSize: 16},
+		{name: "prepended", base: random, target: append([]byte("header\n"), random...), maxDeltaSize: 32},
+		{name: "modified middle", base: random, target: append(append(append([]byte{}, random[:2000]...), []byte("changed")...), random[2010:]...), maxDeltaSize: 48},
+		{name: "unrelated", base: random[:2048], target: random[2048:]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delta := encodeDelta(tt.base, tt.target)
+			if tt.maxDeltaSize > 0 && len(delta) > tt.maxDeltaSize {
+				t.Errorf("delta is too large: %d bytes", len(delta))
+			}
+
+			result, err := applyDelta(tt.base, delta)
+			if err != nil {
+				t.Fatalf("unexpected error applying delta: %v", err)
+			}
+
+			if !bytes.Equal(result, tt.target) {
+				t.Errorf("unexpected result: got %d bytes, expected %d bytes", len(result), len(tt.target))
+			}
+		})
+	}
+}
+
+func TestApplyInvalidDelta(t *testing.T) {
+	if _, err := applyDelta(nil, []byte("not a delta")); err == nil {
+		t.Errorf("expected error when applying invalid delta")
+	}
+
+	// A copy that goes past the end of the base
+	delta := encodeDelta([]byte(strings.Repeat("a", 64)), []byte(strings.Repeat("a", 64)))
+	if _, err := applyDelta([]byte("short"), delta); err == nil {
+		t.Errorf("expected error when applying delta to the wrong base")
+	}
+}
@@ -2,6 +2,8 @@ package client
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -9,30 +11,45 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/adrg/xdg"
 	"github.com/gabriel-vasile/mimetype"
 	"github.com/google/safeopen"
 	"github.com/google/uuid"
+	"github.com/pkg/xattr"
 )
 
-func newDirectory(dataHome string) workspaceFactory {
+func newDirectory(dataHome string, store revisionStore) workspaceFactory {
 	if dataHome == "" {
 		dataHome = filepath.Join(xdg.DataHome, "workspace-provider")
 	}
 	return &directoryProvider{
-		dataHome: dataHome,
-		revisionsProvider: &directoryProvider{
-			dataHome: filepath.Join(dataHome, revisionsDir),
-		},
+		dataHome:      dataHome,
+		revisionStore: store,
 	}
 }
 
 type directoryProvider struct {
 	dataHome          string
 	revisionsProvider workspaceClient
+	revisionStore     revisionStore
+}
+
+// revisionClient returns the client for the revisions of the workspace in dir. Unless a separate revision store is
+// configured, revisions are kept in the revisions directory of the data home.
+func (d *directoryProvider) revisionClient(dir string) workspaceClient {
+	if d.revisionStore != nil {
+		return d.revisionStore(DirectoryProvider, "", dir)
+	}
+	return &directoryProvider{
+		dataHome: filepath.Join(d.dataHome, revisionsDir, dir),
+	}
 }
 
 func (d *directoryProvider) New(id string) (workspaceClient, error) {
@@ -41,9 +58,12 @@ func (d *directoryProvider) New(id string) (workspaceClient, error) {
 		id = filepath.Join(d.dataHome, id)
 	}
 
-	if path.Base(id) == revisionsDir {
+	if d.revisionStore == nil && path.Base(id) == revisionsDir {
 		return nil, errors.New("cannot create a workspace client for the revisions directory")
 	}
+	if path.Base(id) == manifestsDir {
+		return nil, errors.New("cannot create a workspace client for the manifests directory")
+	}
 
 	dir := strings.TrimPrefix(id, d.dataHome+string(filepath.Separator))
 	base := d.dataHome
@@ -56,10 +76,8 @@ func (d *directoryProvider) New(id string) (workspaceClient, error) {
 	f, err := safeopen.OpenBeneath(base, dir)
 	if errors.Is(err, fs.ErrNotExist) {
 		return &directoryProvider{
-			dataHome: id,
-			revisionsProvider: &directoryProvider{
-				dataHome: filepath.Join(d.dataHome, revisionsDir, dir),
-			},
+			dataHome:          id,
+			revisionsProvider: d.revisionClient(dir),
 		}, nil
 	} else if err != nil {
 		return nil, err
@@ -70,10 +88,8 @@ func (d *directoryProvider) New(id string) (workspaceClient, error) {
 	}
 
 	return &directoryProvider{
-		dataHome: id,
-		revisionsProvider: &directoryProvider{
-			dataHome: filepath.Join(d.dataHome, revisionsDir, dir),
-		},
+		dataHome:          id,
+		revisionsProvider: d.revisionClient(dir),
 	}, nil
 }
 
@@ -88,23 +104,90 @@ func (d *directoryProvider) Rm(ctx context.Context, id string) error {
 		id = filepath.Join(d.dataHome, id)
 	}
 
-	// Check that the directory is safe to delete
-	f, err := safeopen.OpenBeneath(d.dataHome, strings.TrimPrefix(id, d.dataHome))
-	if err != nil {
+	if rel, err := filepath.Rel(d.dataHome, id); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		// Directories outside the data home aren't removed.
 		return nil
 	}
-	if err = f.Close(); err != nil {
+
+	// Check that the directory is safe to delete. It may not exist, but its revisions are still removed, since they can
+	// be kept in a separate store.
+	f, err := safeopen.OpenBeneath(d.dataHome, strings.TrimPrefix(id, d.dataHome))
+	if err == nil {
+		err = f.Close()
+	}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
 		return err
 	}
 
-	if d.revisionsProvider != nil {
-		// Best effort
-		_ = d.revisionsProvider.RemoveAllWithPrefix(ctx, strings.TrimPrefix(id, d.dataHome))
+	// The revisions are removed first so that removing the workspace can be retried if they can't be.
+	if err = d.revisionClient("").RemoveAllWithPrefix(ctx, strings.TrimPrefix(id, d.dataHome+string(filepath.Separator))); err != nil {
+		return fmt.Errorf("failed to remove revisions: %w", err)
 	}
 
 	return os.RemoveAll(id)
 }
 
+// List returns the IDs of the workspaces in the data home, sorted. The data home and its manifests directory are read
+// whole, since the file system doesn't list them in order.
+func (d *directoryProvider) List(_ context.Context, after string, limit int) ([]string, error) {
+	entries, err := os.ReadDir(d.dataHome)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	var dirs []string
+	for _, entry := range entries {
+		if entry.IsDir() && isManifestName(entry.Name()) {
+			dirs = append(dirs, entry.Name())
+		}
+	}
+
+	if entries, err = os.ReadDir(filepath.Join(d.dataHome, manifestsDir)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	var manifests []string
+	for _, entry := range entries {
+		if dir, ok := strings.CutSuffix(entry.Name(), ".json"); ok && !entry.IsDir() {
+			manifests = append(manifests, dir)
+		}
+	}
+
+	if after != "" {
+		after = filepath.Base(strings.TrimPrefix(after, DirectoryProvider+"://"))
+	}
+	return workspaceIDs(dirs, manifests, after, limit, func(dir string) string {
+		return DirectoryProvider + "://" + filepath.Join(d.dataHome, dir)
+	}), nil
+}
+
+// ManifestClient returns the client for the manifests directory of the data home. Workspaces outside the data home are
+// kept under their absolute path.
+func (d *directoryProvider) ManifestClient(id string) (workspaceClient, string, error) {
+	dir := strings.TrimPrefix(id, DirectoryProvider+"://")
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(d.dataHome, dir)
+	}
+
+	rel, err := filepath.Rel(d.dataHome, filepath.Clean(dir))
+	if err != nil {
+		return nil, "", newInvalidArgumentError("invalid workspace id: %s", id)
+	}
+	rel = filepath.ToSlash(rel)
+	if first, _, _ := strings.Cut(rel, "/"); first == ".." {
+		rel = filepath.ToSlash(filepath.Clean(dir))
+	} else if !isManifestName(first) {
+		return nil, "", newInvalidArgumentError("invalid workspace id: %s", id)
+	}
+
+	return &directoryProvider{dataHome: filepath.Join(d.dataHome, manifestsDir)}, manifestKey(rel) + ".json", nil
+}
+
+// NamesClient returns the client for the names directory next to the manifests in the data home.
+func (d *directoryProvider) NamesClient() workspaceClient {
+	return &directoryProvider{dataHome: filepath.Join(d.dataHome, manifestsDir, namesDir)}
+}
+
 func (d *directoryProvider) RevisionClient() workspaceClient {
 	return d.revisionsProvider
 }
@@ -123,10 +206,7 @@ func (d *directoryProvider) DeleteFile(ctx context.Context, file string) error {
 		return err
 	}
 
-	for i := info.CurrentID; i > 0; i-- {
-		// Best effort
-		_ = deleteRevision(ctx, d.revisionsProvider, file, fmt.Sprintf("%d", i))
-	}
+	deleteRevisions(ctx, d.revisionsProvider, file, info)
 
 	// Best effort
 	_ = deleteRevisionInfo(ctx, d.revisionsProvider, file)
@@ -149,51 +229,125 @@ func (d *directoryProvider) OpenFile(ctx context.Context, file string, opt OpenO
 		revision = strconv.FormatInt(rev.CurrentID, 10)
 	}
 
+	var (
+		checksum  string
+		metadata  map[string]string
+		expiresAt time.Time
+	)
+	if file, ok := f.(*os.File); ok {
+		checksum, metadata, expiresAt = fileChecksum(file), fileMetadata(file), fileExpiresAt(file)
+	}
+
+	var size int64
+	if opt.hasRange() {
+		if f, size, err = seekRange(f, opt.Offset, opt.Length); err != nil {
+			return nil, err
+		}
+	}
+
 	return &File{
 		ReadCloser: f,
 		RevisionID: revision,
+		Size:       size,
+		Checksum:   checksum,
+		Metadata:   metadata,
+		ExpiresAt:  expiresAt,
 	}, nil
 }
 
+// WriteFile writes the content to a temporary file next to the file, and then renames it over the file, so that the
+// file is only replaced once the content has been written and its checksum verified.
 func (d *directoryProvider) WriteFile(ctx context.Context, fileName string, reader io.Reader, opt WriteOptions) error {
-	if d.revisionsProvider != nil && (opt.CreateRevision == nil || *opt.CreateRevision) {
-		info, err := getRevisionInfo(ctx, d.revisionsProvider, fileName)
-		if err != nil {
-			if nfe := (*NotFoundError)(nil); !errors.As(err, &nfe) {
-				return err
-			}
+	tmpFileName, err := d.writeFile(fileName, reader, opt)
+	if err != nil {
+		return err
+	}
+	// This fails once the temporary file has been renamed.
+	defer os.Remove(filepath.Join(d.dataHome, tmpFileName))
+
+	if d.revisionsProvider != nil {
+		if err := recordWrite(ctx, d.revisionsProvider, d, DirectoryProvider+"://"+d.dataHome, fileName, opt); err != nil {
+			return err
 		}
+	}
 
-		if opt.LatestRevisionID != "" {
-			requiredLatestRevision, err := strconv.ParseInt(opt.LatestRevisionID, 10, 64)
-			if err != nil {
-				return fmt.Errorf("failed to parse latest revision for write: %w", err)
-			}
+	if opt.exclusive {
+		return d.linkFile(tmpFileName, fileName)
+	}
+	return d.renameFile(ctx, tmpFileName, fileName)
+}
 
-			if requiredLatestRevision != info.CurrentID {
-				return newConflictError(DirectoryProvider+"://"+d.dataHome, fileName, opt.LatestRevisionID, fmt.Sprintf("%d", info.CurrentID))
-			}
-		}
+func (d *directoryProvider) MoveFile(ctx context.Context, from, to string, opt MoveOptions) error {
+	return moveFile(ctx, d, DirectoryProvider+"://"+d.dataHome, from, to, opt, d.renameFile)
+}
 
-		info.CurrentID++
-		if err = writeRevision(ctx, d.revisionsProvider, d, fileName, info); err != nil {
-			if nfe := (*NotFoundError)(nil); !errors.As(err, &nfe) {
-				return fmt.Errorf("failed to write revision: %w", err)
-			}
-		}
+func (d *directoryProvider) CopyFile(ctx context.Context, fileName string, dest workspaceClient, destFileName string, opt WriteOptions) error {
+	destDir, ok := dest.(*directoryProvider)
+	if !ok {
+		return streamFile(ctx, d, fileName, dest, destFileName, opt)
+	}
 
-		if err = writeRevisionInfo(ctx, d.revisionsProvider, fileName, info); err != nil {
-			return fmt.Errorf("failed to write revision info: %w", err)
+	return copyFile(ctx, d, fileName, dest, DirectoryProvider+"://"+destDir.dataHome, destFileName, opt, func(ctx context.Context) error {
+		return d.copyFileTo(ctx, fileName, destDir, destFileName)
+	})
+}
+
+func (d *directoryProvider) AppendFile(ctx context.Context, fileName string, reader io.Reader, opt WriteOptions) error {
+	if d.revisionsProvider != nil {
+		if err := recordWrite(ctx, d.revisionsProvider, d, DirectoryProvider+"://"+d.dataHome, fileName, opt); err != nil {
+			return err
 		}
 	}
 
-	return d.writeFile(fileName, reader)
+	return d.appendFile(fileName, reader)
 }
 
 func (d *directoryProvider) StatFile(ctx context.Context, s string, opt StatOptions) (FileInfo, error) {
 	return d.statFile(ctx, s, opt)
 }
 
+func (d *directoryProvider) BackfillContentType(_ context.Context, fileName string) (bool, error) {
+	f, err := safeopen.OpenBeneath(d.dataHome, fileName)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, newNotFoundError(DirectoryProvider+"://"+d.dataHome, fileName)
+		}
+		return false, err
+	}
+	defer f.Close()
+
+	if fileContentType(f) != "" {
+		return false, nil
+	}
+
+	mt, err := mimetype.DetectReader(f)
+	if err != nil {
+		return false, err
+	}
+
+	if err = xattr.FSet(f, contentTypeXattr, []byte(strings.Split(mt.String(), ";")[0])); err != nil {
+		if errors.Is(err, syscall.ENOTSUP) || errors.Is(err, syscall.EOPNOTSUPP) {
+			// The file system can't store the content type, so it is detected whenever the file is statted.
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (d *directoryProvider) SetMetadata(_ context.Context, fileName string, metadata map[string]string) error {
+	f, err := safeopen.OpenBeneath(d.dataHome, fileName)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return newNotFoundError(DirectoryProvider+"://"+d.dataHome, fileName)
+		}
+		return err
+	}
+	defer f.Close()
+
+	return setFileMetadata(f, metadata)
+}
+
 func (d *directoryProvider) Ls(ctx context.Context, prefix string) ([]string, error) {
 	if prefix != "" {
 		// Ensure that the provided prefix is safe to open.
@@ -216,6 +370,167 @@ func (d *directoryProvider) Ls(ctx context.Context, prefix string) ([]string, er
 	return files, nil
 }
 
+func (d *directoryProvider) LsWithInfo(ctx context.Context, prefix string) ([]FileInfo, error) {
+	if prefix != "" {
+		// Ensure that the provided prefix is safe to open.
+		file, err := safeopen.OpenBeneath(d.dataHome, strings.TrimSuffix(prefix, "/"))
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		if err = file.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	return d.lsWithInfo(ctx, prefix)
+}
+
+// LsPage lists the files in the order of a walk of the directory tree. The continuation is the name of the last file
+// of the previous page, so the listing picks up where it left off even if that file has since been deleted.
+func (d *directoryProvider) LsPage(_ context.Context, prefix string, limit int, continuation string) ([]FileInfo, string, error) {
+	if prefix != "" {
+		// Ensure that the provided prefix is safe to open.
+		file, err := safeopen.OpenBeneath(d.dataHome, strings.TrimSuffix(prefix, "/"))
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, "", nil
+			}
+			return nil, "", err
+		}
+		if err = file.Close(); err != nil {
+			return nil, "", err
+		}
+	}
+
+	// One extra file is listed to know whether there is another page.
+	files := make([]FileInfo, 0, limit+1)
+	if err := d.walkFilesAfter(strings.TrimSuffix(prefix, "/"), continuation, func(info FileInfo) bool {
+		files = append(files, info)
+		return len(files) <= limit
+	}); err != nil {
+		return nil, "", err
+	}
+
+	if len(files) <= limit {
+		return files, "", nil
+	}
+
+	return files[:limit], files[limit-1].Name, nil
+}
+
+func (d *directoryProvider) LsDir(_ context.Context, prefix string) ([]FileInfo, error) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix != "" {
+		// Ensure that the provided prefix is safe to open.
+		file, err := safeopen.OpenBeneath(d.dataHome, prefix)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		if err = file.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Join(d.dataHome, prefix))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	files := make([]FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			files = append(files, FileInfo{
+				WorkspaceID: DirectoryProvider + "://" + d.dataHome,
+				Name:        filepath.Join(prefix, entry.Name()) + "/",
+				IsDir:       true,
+			})
+			continue
+		}
+		if isTempFile(entry.Name()) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+
+		files = append(files, FileInfo{
+			WorkspaceID: DirectoryProvider + "://" + d.dataHome,
+			Name:        filepath.Join(prefix, entry.Name()),
+			Size:        info.Size(),
+			ModTime:     info.ModTime(),
+			MimeType:    pathContentType(filepath.Join(d.dataHome, prefix, entry.Name())),
+			Checksum:    pathChecksum(filepath.Join(d.dataHome, prefix, entry.Name())),
+			Metadata:    pathMetadata(filepath.Join(d.dataHome, prefix, entry.Name())),
+			ExpiresAt:   pathExpiresAt(filepath.Join(d.dataHome, prefix, entry.Name())),
+		})
+	}
+
+	return files, nil
+}
+
+func (d *directoryProvider) StatWithPrefix(_ context.Context, prefix string) ([]FileInfo, error) {
+	dir, base := path.Split(prefix)
+	if dir != "" {
+		// Ensure that the directory of the provided prefix is safe to open.
+		file, err := safeopen.OpenBeneath(d.dataHome, strings.TrimSuffix(dir, "/"))
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		if err = file.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Join(d.dataHome, dir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var files []FileInfo
+	for _, entry := range entries {
+		if entry.IsDir() || isTempFile(entry.Name()) || !strings.HasPrefix(entry.Name(), base) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+
+		files = append(files, FileInfo{
+			WorkspaceID: DirectoryProvider + "://" + d.dataHome,
+			Name:        filepath.Join(dir, entry.Name()),
+			Size:        info.Size(),
+			ModTime:     info.ModTime(),
+		})
+	}
+
+	return files, nil
+}
+
 func (d *directoryProvider) ListRevisions(ctx context.Context, fileName string) ([]RevisionInfo, error) {
 	return listRevisions(ctx, d.revisionsProvider, fmt.Sprintf("%s://%s", DirectoryProvider, d.dataHome), fileName)
 }
@@ -225,7 +540,7 @@ func (d *directoryProvider) GetRevision(ctx context.Context, fileName, revisionI
 }
 
 func (d *directoryProvider) DeleteRevision(ctx context.Context, fileName, revisionID string) error {
-	return deleteRevision(ctx, d.revisionsProvider, fileName, revisionID)
+	return removeRevision(ctx, d.revisionsProvider, fileName, revisionID)
 }
 
 func (d *directoryProvider) openFile(fileName string) (io.ReadCloser, error) {
@@ -237,22 +552,226 @@ func (d *directoryProvider) openFile(fileName string) (io.ReadCloser, error) {
 	return f, err
 }
 
-func (d *directoryProvider) writeFile(fileName string, reader io.Reader) error {
-	fullFilePath := filepath.Join(d.dataHome, fileName)
-	if err := os.MkdirAll(filepath.Dir(fullFilePath), 0o755); err != nil {
-		return err
+// seekRange restricts an open file to a byte range, returning it along with the total size of the file.
+func seekRange(f io.ReadCloser, offset, length int64) (io.ReadCloser, int64, error) {
+	file, ok := f.(*os.File)
+	if !ok {
+		rc, err := limitRange(f, offset, length)
+		return rc, -1, err
 	}
 
-	file, err := safeopen.OpenFileBeneath(d.dataHome, fileName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	info, err := file.Stat()
 	if err != nil {
+		_ = file.Close()
+		return nil, 0, err
+	}
+
+	if _, err = file.Seek(offset, io.SeekStart); err != nil {
+		_ = file.Close()
+		return nil, 0, err
+	}
+
+	if length == 0 {
+		return file, info.Size(), nil
+	}
+
+	return rangeReadCloser{Reader: io.LimitReader(file, length), Closer: file}, info.Size(), nil
+}
+
+// tempFilePattern matches the names of the temporary files that writeFile writes content to before renaming them.
+var tempFilePattern = regexp.MustCompile(`^\..+\.[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\.tmp$`)
+
+// isTempFile reports whether a file is the temporary file of a write in progress, which listings leave out.
+func isTempFile(name string) bool {
+	return tempFilePattern.MatchString(name)
+}
+
+// writeFile writes the content to a new temporary file next to the file and stores its checksum, content type and
+// metadata with it. It returns the name of the temporary file, which is removed if the content doesn't match the expected checksum.
+func (d *directoryProvider) writeFile(fileName string, reader io.Reader, opt WriteOptions) (string, error) {
+	tmpFileName := filepath.Join(filepath.Dir(fileName), fmt.Sprintf(".%s.%s.tmp", filepath.Base(fileName), uuid.NewString()))
+
+	var file *os.File
+	err := withDir(filepath.Dir(filepath.Join(d.dataHome, fileName)), func() (err error) {
+		file, err = safeopen.OpenFileBeneath(d.dataHome, tmpFileName, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
 		return err
+	})
+	if err != nil {
+		return "", err
 	}
 	defer file.Close()
 
-	_, err = io.Copy(file, reader)
+	contentType, reader := sniffContentType(reader, opt.ContentType)
+
+	h := sha256.New()
+	if _, err = io.Copy(io.MultiWriter(file, h), reader); err == nil {
+		sum := hex.EncodeToString(h.Sum(nil))
+		if err = verifyChecksum(DirectoryProvider+"://"+d.dataHome, fileName, opt.Checksum, sum); err == nil {
+			if err = setFileMetadata(file, opt.Metadata); err == nil {
+				if err = setFileExpiresAt(file, opt.ExpiresAt); err == nil {
+					setFileChecksum(file, sum)
+					setFileContentType(file, contentType)
+					return tmpFileName, nil
+				}
+			}
+		}
+	}
+
+	_ = os.Remove(filepath.Join(d.dataHome, tmpFileName))
+	return "", err
+}
+
+func (d *directoryProvider) renameFile(_ context.Context, from, to string) error {
+	f, err := safeopen.OpenBeneath(d.dataHome, from)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return newNotFoundError(DirectoryProvider+"://"+d.dataHome, from)
+		}
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return fmt.Errorf("failed to close file: %w", err)
+	}
+
+	fullToPath := filepath.Join(d.dataHome, to)
+	if err = withDir(filepath.Dir(fullToPath), func() error {
+		// Check that the destination directory is safe to write to
+		if dir := filepath.Dir(to); dir != "." {
+			f, err := safeopen.OpenBeneath(d.dataHome, dir)
+			if err != nil {
+				return err
+			}
+			if err = f.Close(); err != nil {
+				return fmt.Errorf("failed to close directory: %w", err)
+			}
+		}
+
+		return os.Rename(filepath.Join(d.dataHome, from), fullToPath)
+	}); err != nil {
+		return err
+	}
+
+	if filepath.Dir(from) != filepath.Dir(to) {
+		d.pruneDirs(filepath.Dir(from))
+	}
+	return nil
+}
+
+// linkFile links a file to another name, which fails with a FileExistsError if the name is taken, so that the file is
+// created atomically.
+func (d *directoryProvider) linkFile(from, to string) error {
+	fullToPath := filepath.Join(d.dataHome, to)
+	err := withDir(filepath.Dir(fullToPath), func() error {
+		// Check that the destination directory is safe to write to
+		if dir := filepath.Dir(to); dir != "." {
+			f, err := safeopen.OpenBeneath(d.dataHome, dir)
+			if err != nil {
+				return err
+			}
+			if err = f.Close(); err != nil {
+				return fmt.Errorf("failed to close directory: %w", err)
+			}
+		}
+
+		return os.Link(filepath.Join(d.dataHome, from), fullToPath)
+	})
+	if errors.Is(err, fs.ErrExist) {
+		return &FileExistsError{id: DirectoryProvider + "://" + d.dataHome, name: to}
+	}
 	return err
 }
 
+// copyFileTo copies a file using a reflink where the filesystem supports it. Otherwise, the copy is done by the kernel
+// with copy_file_range where available.
+func (d *directoryProvider) copyFileTo(ctx context.Context, fileName string, dest *directoryProvider, destFileName string) error {
+	source, err := safeopen.OpenBeneath(d.dataHome, fileName)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return newNotFoundError(DirectoryProvider+"://"+d.dataHome, fileName)
+		}
+		return err
+	}
+	defer source.Close()
+
+	// The copy is written to a temporary file that replaces the destination once it is complete, so that a failed copy
+	// leaves the destination as it was and copying a file to itself doesn't truncate it first.
+	tmpFileName := filepath.Join(filepath.Dir(destFileName), fmt.Sprintf(".%s.%s.tmp", filepath.Base(destFileName), uuid.NewString()))
+
+	var file *os.File
+	if err = withDir(filepath.Dir(filepath.Join(dest.dataHome, destFileName)), func() (err error) {
+		file, err = safeopen.OpenFileBeneath(dest.dataHome, tmpFileName, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		return err
+	}); err != nil {
+		return err
+	}
+	// This fails once the temporary file has been renamed.
+	defer os.Remove(filepath.Join(dest.dataHome, tmpFileName))
+
+	if err = copyFileContent(file, source); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return fmt.Errorf("failed to close file: %w", err)
+	}
+
+	return dest.renameFile(ctx, tmpFileName, destFileName)
+}
+
+// copyFileContent copies the content of source to file along with its checksum, content type, expiry and metadata.
+func copyFileContent(file, source *os.File) error {
+	setFileChecksum(file, fileChecksum(source))
+	setFileContentType(file, fileContentType(source))
+	if err := setFileMetadata(file, fileMetadata(source)); err != nil {
+		return err
+	}
+	if err := setFileExpiresAt(file, fileExpiresAt(source)); err != nil {
+		return err
+	}
+
+	if err := cloneFile(file, source); err == nil {
+		return nil
+	}
+
+	_, err := io.Copy(file, source)
+	return err
+}
+
+func (d *directoryProvider) appendFile(fileName string, reader io.Reader) error {
+	var file *os.File
+	if err := withDir(filepath.Dir(filepath.Join(d.dataHome, fileName)), func() (err error) {
+		file, err = safeopen.OpenFileBeneath(d.dataHome, fileName, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+		return err
+	}); err != nil {
+		return err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	// The checksum of the whole file isn't known without reading it, so it is removed.
+	setFileChecksum(file, "")
+
+	if _, err = io.Copy(file, reader); err != nil {
+		return err
+	}
+
+	if stat.Size() < sniffLength {
+		// The content type is detected from the first bytes of the file, which the append changed.
+		head := make([]byte, sniffLength)
+		n, err := file.ReadAt(head, 0)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		setFileContentType(file, detectContentType(head[:n]))
+	}
+
+	return nil
+}
+
 func (d *directoryProvider) deleteFile(fileName string) error {
 	f, err := safeopen.OpenBeneath(d.dataHome, fileName)
 	if err != nil {
@@ -261,14 +780,21 @@ func (d *directoryProvider) deleteFile(fileName string) error {
 		}
 		return err
 	}
-	if err = f.Close(); err != nil {
-		return fmt.Errorf("failed to close file: %w", err)
+	defer f.Close()
+
+	// As in the object stores, deleting a file doesn't remove a directory with its name.
+	if stat, err := f.Stat(); err != nil || stat.IsDir() {
+		return err
 	}
 
-	if err = os.Remove(filepath.Join(d.dataHome, fileName)); !errors.Is(err, fs.ErrNotExist) {
+	if err = os.Remove(filepath.Join(d.dataHome, fileName)); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
 		return err
 	}
 
+	d.pruneDirs(filepath.Dir(fileName))
 	return nil
 }
 
@@ -287,12 +813,24 @@ func (d *directoryProvider) statFile(ctx context.Context, s string, opt StatOpti
 		return FileInfo{}, err
 	}
 
-	// Get Mimetype
-	mt, err := mimetype.DetectReader(f)
-	if err != nil {
-		return FileInfo{}, err
+	if stat.IsDir() {
+		return FileInfo{
+			WorkspaceID: DirectoryProvider + "://" + d.dataHome,
+			Name:        strings.TrimSuffix(s, "/") + "/",
+			ModTime:     stat.ModTime(),
+			IsDir:       true,
+		}, nil
+	}
+
+	// Files written before content types were stored have their mimetype detected instead.
+	mime := fileContentType(f)
+	if mime == "" {
+		mt, err := mimetype.DetectReader(f)
+		if err != nil {
+			return FileInfo{}, err
+		}
+		mime = strings.Split(mt.String(), ";")[0]
 	}
-	mime := strings.Split(mt.String(), ";")[0]
 
 	var revision string
 	if opt.WithLatestRevisionID {
@@ -310,6 +848,9 @@ func (d *directoryProvider) statFile(ctx context.Context, s string, opt StatOpti
 		ModTime:     stat.ModTime(),
 		MimeType:    mime,
 		RevisionID:  revision,
+		Checksum:    fileChecksum(f),
+		Metadata:    fileMetadata(f),
+		ExpiresAt:   fileExpiresAt(f),
 	}, nil
 }
 
@@ -331,7 +872,7 @@ func (d *directoryProvider) ls(ctx context.Context, prefix string) ([]string, er
 			}
 
 			files = append(files, subFiles...)
-		} else if !entry.IsDir() {
+		} else if !isTempFile(entry.Name()) {
 			files = append(files, filepath.Join(prefix, entry.Name()))
 		}
 	}
@@ -339,6 +880,116 @@ func (d *directoryProvider) ls(ctx context.Context, prefix string) ([]string, er
 	return files, nil
 }
 
+func (d *directoryProvider) lsWithInfo(ctx context.Context, prefix string) ([]FileInfo, error) {
+	entries, err := os.ReadDir(filepath.Join(d.dataHome, prefix))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	files := make([]FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			subFiles, err := d.lsWithInfo(ctx, filepath.Join(prefix, entry.Name()))
+			if err != nil {
+				return nil, err
+			}
+
+			files = append(files, subFiles...)
+			continue
+		}
+		if isTempFile(entry.Name()) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+
+		files = append(files, FileInfo{
+			WorkspaceID: DirectoryProvider + "://" + d.dataHome,
+			Name:        filepath.Join(prefix, entry.Name()),
+			Size:        info.Size(),
+			ModTime:     info.ModTime(),
+			MimeType:    pathContentType(filepath.Join(d.dataHome, prefix, entry.Name())),
+			Checksum:    pathChecksum(filepath.Join(d.dataHome, prefix, entry.Name())),
+			Metadata:    pathMetadata(filepath.Join(d.dataHome, prefix, entry.Name())),
+			ExpiresAt:   pathExpiresAt(filepath.Join(d.dataHome, prefix, entry.Name())),
+		})
+	}
+
+	return files, nil
+}
+
+// walkFilesAfter calls fn for each file under dir, in walk order, that comes after the file named after. The walk
+// stops when fn returns false.
+func (d *directoryProvider) walkFilesAfter(dir, after string, fn func(FileInfo) bool) error {
+	var afterParts []string
+	if after != "" {
+		afterParts = strings.Split(after, string(filepath.Separator))
+	}
+
+	err := filepath.WalkDir(filepath.Join(d.dataHome, dir), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		name, err := filepath.Rel(d.dataHome, path)
+		if err != nil {
+			return err
+		}
+		parts := strings.Split(name, string(filepath.Separator))
+
+		if entry.IsDir() {
+			// Skip directories that were completely listed in previous pages.
+			if name != "." && afterParts != nil && slices.Compare(parts, afterParts[:min(len(parts), len(afterParts))]) < 0 {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if isTempFile(entry.Name()) || afterParts != nil && slices.Compare(parts, afterParts) <= 0 {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		if !fn(FileInfo{
+			WorkspaceID: DirectoryProvider + "://" + d.dataHome,
+			Name:        name,
+			Size:        info.Size(),
+			ModTime:     info.ModTime(),
+			MimeType:    pathContentType(path),
+			Checksum:    pathChecksum(path),
+			Metadata:    pathMetadata(path),
+			ExpiresAt:   pathExpiresAt(path),
+		}) {
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
 func (d *directoryProvider) RemoveAllWithPrefix(_ context.Context, dirName string) error {
 	fullDirName := filepath.Join(d.dataHome, dirName)
 
@@ -348,11 +999,102 @@ func (d *directoryProvider) RemoveAllWithPrefix(_ context.Context, dirName strin
 		if os.IsNotExist(err) {
 			return nil
 		}
-		return nil
+		return err
 	}
 	if err = f.Close(); err != nil {
 		return err
 	}
 
-	return os.RemoveAll(fullDirName)
+	if err = os.RemoveAll(fullDirName); err != nil {
+		return err
+	}
+
+	d.pruneDirs(filepath.Dir(strings.TrimSuffix(dirName, "/")))
+	return nil
+}
+
+// Mkdir creates the directory and its parents, and marks it so that it isn't pruned when it is emptied. This is best
+// effort: on file systems without extended attributes, it is pruned like any other directory.
+func (d *directoryProvider) Mkdir(_ context.Context, dirName string) error {
+	if !filepath.IsLocal(dirName) {
+		return fmt.Errorf("invalid directory name: %s", dirName)
+	}
+
+	var f *os.File
+	if err := withDir(filepath.Join(d.dataHome, dirName), func() (err error) {
+		// Check that the directory is safe to mark
+		f, err = safeopen.OpenBeneath(d.dataHome, dirName)
+		return err
+	}); err != nil {
+		return err
+	}
+	defer f.Close()
+
+	setFileAttr(f, dirXattr, "true")
+	return nil
+}
+
+func (d *directoryProvider) RemoveDir(_ context.Context, dirName string, recursive bool) error {
+	f, err := safeopen.OpenBeneath(d.dataHome, dirName)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return newNotFoundError(DirectoryProvider+"://"+d.dataHome, dirName)
+		}
+		return err
+	}
+	defer f.Close()
+
+	if stat, err := f.Stat(); err != nil {
+		return err
+	} else if !stat.IsDir() {
+		return newNotFoundError(DirectoryProvider+"://"+d.dataHome, dirName)
+	}
+
+	remove := os.RemoveAll
+	if !recursive {
+		if entries, err := f.ReadDir(1); err != nil && !errors.Is(err, io.EOF) {
+			return err
+		} else if len(entries) > 0 {
+			return newDirectoryNotEmptyError(DirectoryProvider+"://"+d.dataHome, dirName)
+		}
+		remove = os.Remove
+	}
+
+	if err = remove(filepath.Join(d.dataHome, dirName)); err != nil {
+		return err
+	}
+
+	d.pruneDirs(filepath.Dir(dirName))
+	return nil
+}
+
+// maxDirAttempts is how many times a file is created in a directory, which pruneDirs may remove concurrently once it is
+// empty, before giving up.
+const maxDirAttempts = 5
+
+// withDir creates the directory and its parents and then calls fn, which creates something in it. If fn fails because
+// the directory was pruned in the meantime, then it is created again and fn is retried.
+func withDir(dir string, fn func() error) error {
+	var err error
+	for range maxDirAttempts {
+		if err = os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+		if err = fn(); !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return err
+}
+
+// pruneDirs removes the directory and its parents while they are empty, stopping at one created with Mkdir, so that
+// removing the last file of a directory removes the directory too, as in the object stores. The names have already
+// been checked to be beneath the data home.
+func (d *directoryProvider) pruneDirs(dir string) {
+	for ; dir != "." && dir != string(filepath.Separator); dir = filepath.Dir(dir) {
+		fullDir := filepath.Join(d.dataHome, dir)
+		if pathAttr(fullDir, dirXattr) != "" || os.Remove(fullDir) != nil {
+			return
+		}
+	}
 }
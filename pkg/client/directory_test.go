@@ -33,13 +33,13 @@ var (
 )
 
 func TestMain(m *testing.M) {
-	directoryFactory = newDirectory("")
+	directoryFactory = newDirectory("", nil)
 	directoryTestingID = directoryFactory.Create()
 	dirPrv, _ = directoryFactory.New(directoryTestingID)
 
 	if !skipS3Tests {
 		if os.Getenv("WORKSPACE_PROVIDER_S3_USE_PATH_STYLE") != "true" {
-			s3Factory, _ := newS3(context.Background(), os.Getenv("WORKSPACE_PROVIDER_S3_BUCKET"), os.Getenv("WORKSPACE_PROVIDER_S3_BASE_ENDPOINT"), false)
+			s3Factory, _ := newS3(context.Background(), os.Getenv("WORKSPACE_PROVIDER_S3_BUCKET"), os.Getenv("WORKSPACE_PROVIDER_S3_BASE_ENDPOINT"), false, nil)
 			// This won't ever error because it doesn't create anything.
 			s3TestingID := s3Factory.Create()
 
@@ -53,7 +53,7 @@ func TestMain(m *testing.M) {
 			})
 		}
 
-		s3PathStyleFactory, _ := newS3(context.Background(), os.Getenv("WORKSPACE_PROVIDER_S3_BUCKET"), os.Getenv("WORKSPACE_PROVIDER_S3_BASE_ENDPOINT"), true)
+		s3PathStyleFactory, _ := newS3(context.Background(), os.Getenv("WORKSPACE_PROVIDER_S3_BUCKET"), os.Getenv("WORKSPACE_PROVIDER_S3_BASE_ENDPOINT"), true, nil)
 		s3PathStyleTestingID := s3PathStyleFactory.Create()
 		s3PathStyleClient, _ := s3PathStyleFactory.New(s3PathStyleTestingID)
 		s3TestSetups = append(s3TestSetups, s3TestSetup{
@@ -65,7 +65,7 @@ func TestMain(m *testing.M) {
 	}
 
 	if !skipAzureTests {
-		azureFactory, _ = newAzure(os.Getenv("WORKSPACE_PROVIDER_AZURE_CONTAINER"), os.Getenv("WORKSPACE_PROVIDER_AZURE_CONNECTION_STRING"))
+		azureFactory, _ = newAzure(os.Getenv("WORKSPACE_PROVIDER_AZURE_CONTAINER"), os.Getenv("WORKSPACE_PROVIDER_AZURE_CONNECTION_STRING"), nil)
 		// This won't ever error because it doesn't create anything.
 		azureTestingID = azureFactory.Create()
 
@@ -233,9 +233,9 @@ func TestWriteAndDeleteFileInDirectoryWithSubDir(t *testing.T) {
 		t.Errorf("file should not exist after deleting: %v", err)
 	}
 
-	// Cleanup the directory
-	if err := os.Remove(filepath.Join(strings.TrimPrefix(directoryTestingID, DirectoryProvider+"://"), "subdir")); err != nil {
-		t.Errorf("error when removing subdir directory: %v", err)
+	// Ensure the directory was removed with its last file
+	if _, err := os.Stat(filepath.Join(strings.TrimPrefix(directoryTestingID, DirectoryProvider+"://"), "subdir")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("directory should not exist after deleting its last file: %v", err)
 	}
 }
 
@@ -875,6 +875,54 @@ func TestNoCreateRevisionsClient(t *testing.T) {
 	}
 }
 
+func TestSeparateRevisionStore(t *testing.T) {
+	dataHome, storeDir := t.TempDir(), t.TempDir()
+	store, err := newRevisionStore(context.Background(), DirectoryProvider+"://"+storeDir, Options{})
+	if err != nil {
+		t.Fatalf("unexpected error when creating revision store: %v", err)
+	}
+
+	factory := newDirectory(dataHome, store)
+
+	// A workspace can be named revisions because revisions are not stored next to the workspaces.
+	id := DirectoryProvider + "://" + filepath.Join(dataHome, revisionsDir)
+	wc, err := factory.New(id)
+	if err != nil {
+		t.Fatalf("unexpected error when creating client for revisions dir: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = factory.Rm(context.Background(), id)
+	})
+
+	for _, content := range []string{"test", "test2"} {
+		if err = wc.WriteFile(context.Background(), "test.txt", strings.NewReader(content), WriteOptions{}); err != nil {
+			t.Fatalf("unexpected error when writing file: %v", err)
+		}
+	}
+
+	if _, err = os.Stat(filepath.Join(storeDir, DirectoryProvider, revisionsDir, "test.txt.1")); err != nil {
+		t.Errorf("expected revision in the revision store: %v", err)
+	}
+	if _, err = os.Stat(filepath.Join(dataHome, revisionsDir, revisionsDir)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected no revisions next to the workspace: %v", err)
+	}
+
+	revisions, err := wc.ListRevisions(context.Background(), "test.txt")
+	if err != nil {
+		t.Fatalf("unexpected error when listing revisions: %v", err)
+	}
+	if len(revisions) != 1 {
+		t.Fatalf("unexpected number of revisions: %d", len(revisions))
+	}
+
+	if err = factory.Rm(context.Background(), id); err != nil {
+		t.Fatalf("unexpected error when removing workspace: %v", err)
+	}
+	if _, err = os.Stat(filepath.Join(storeDir, DirectoryProvider, revisionsDir)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected revisions to be removed with the workspace: %v", err)
+	}
+}
+
 func TestStatFile(t *testing.T) {
 	// Copy a file into the workspace
 	if err := dirPrv.WriteFile(context.Background(), "test.txt", strings.NewReader("test"), WriteOptions{}); err != nil {
@@ -915,3 +963,69 @@ func TestStatFile(t *testing.T) {
 		t.Errorf("unexpected revision id when revision requested: %s", rev)
 	}
 }
+
+func TestListRevisionsSharedPrefix(t *testing.T) {
+	// Write two files where the name of one is a prefix of the other
+	for _, name := range []string{"prefix.txt", "prefix.txt.1"} {
+		for _, content := range []string{"test", "test2"} {
+			if err := dirPrv.WriteFile(context.Background(), name, strings.NewReader(content), WriteOptions{}); err != nil {
+				t.Fatalf("error getting file to write: %v", err)
+			}
+		}
+	}
+
+	t.Cleanup(func() {
+		for _, name := range []string{"prefix.txt", "prefix.txt.1"} {
+			if err := dirPrv.DeleteFile(context.Background(), name); err != nil {
+				t.Errorf("unexpected error when deleting file: %v", err)
+			}
+		}
+	})
+
+	// Each file should only have its own revision
+	for _, name := range []string{"prefix.txt", "prefix.txt.1"} {
+		revisions, err := dirPrv.ListRevisions(context.Background(), name)
+		if err != nil {
+			t.Errorf("unexpected error when listing revisions: %v", err)
+		}
+		if len(revisions) != 1 {
+			t.Errorf("unexpected number of revisions for %s: %d", name, len(revisions))
+		} else if revisions[0].RevisionID != "1" || revisions[0].Name != name || revisions[0].Size != 4 {
+			t.Errorf("unexpected revision for %s: %+v", name, revisions[0])
+		}
+	}
+}
+
+func TestLsSkipsTempFiles(t *testing.T) {
+	if err := dirPrv.WriteFile(context.Background(), "temp/test.txt", strings.NewReader("test"), WriteOptions{}); err != nil {
+		t.Fatalf("error getting file to write: %v", err)
+	}
+
+	// A write in progress has a temporary file next to the file it is writing.
+	tmpFile := filepath.Join(strings.TrimPrefix(directoryTestingID, DirectoryProvider+"://"), "temp", ".test.txt.0b7c3a1e-51f2-4a8e-9d3c-6f1e2d4b5a69.tmp")
+	if err := os.WriteFile(tmpFile, []byte("partial"), 0o644); err != nil {
+		t.Fatalf("unexpected error when writing temporary file: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := dirPrv.RemoveAllWithPrefix(context.Background(), "temp"); err != nil {
+			t.Errorf("unexpected error when removing files: %v", err)
+		}
+	})
+
+	files, err := dirPrv.Ls(context.Background(), "temp")
+	if err != nil {
+		t.Fatalf("unexpected error when listing files: %v", err)
+	}
+	if !reflect.DeepEqual(files, []string{"temp/test.txt"}) {
+		t.Errorf("unexpected files: %v", files)
+	}
+
+	infos, err := dirPrv.LsWithInfo(context.Background(), "temp")
+	if err != nil {
+		t.Fatalf("unexpected error when listing files: %v", err)
+	}
+	if len(infos) != 1 || infos[0].Name != "temp/test.txt" {
+		t.Errorf("unexpected files with info: %+v", infos)
+	}
+}
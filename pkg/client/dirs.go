@@ -0,0 +1,124 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// dirXattr is the extended attribute that marks a directory on disk as created with Mkdir, so that it isn't pruned
+// when it is emptied.
+const dirXattr = "user.workspace-provider.dir"
+
+type RemoveDirOptions struct {
+	// Recursive removes everything in the directory, including the revisions of its files. Otherwise, the directory
+	// must be empty.
+	Recursive bool
+}
+
+// Mkdir creates a directory, along with any missing parents. A directory created with Mkdir is kept when it is empty
+// until it is removed with RemoveDir, while one that only exists because files were written in it is removed along
+// with its last file. Creating a directory that exists is not an error, but a FileExistsError is returned if a file
+// has its name or the name of one of its parents.
+func (c *Client) Mkdir(ctx context.Context, id, dirName string) error {
+	dirName, err := cleanDirName(dirName)
+	if err != nil {
+		return err
+	}
+
+	wc, id, err := c.getClient(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	// Object stores allow a file and a directory to have the same name, so this is checked for every backend.
+	parts := strings.Split(dirName, "/")
+	for i := range parts {
+		name := strings.Join(parts[:i+1], "/")
+		info, err := wc.StatFile(ctx, name, StatOptions{})
+		if err != nil {
+			if nfe := (*NotFoundError)(nil); errors.As(err, &nfe) {
+				continue
+			}
+			return err
+		}
+		if !info.IsDir {
+			return &FileExistsError{id: id, name: name}
+		}
+	}
+
+	return wc.Mkdir(ctx, dirName)
+}
+
+// RemoveDir removes a directory. A DirectoryNotEmptyError is returned if it isn't empty, unless the removal is
+// recursive. Empty parents that weren't created with Mkdir are removed along with it.
+func (c *Client) RemoveDir(ctx context.Context, id, dirName string, opts ...RemoveDirOptions) error {
+	var opt RemoveDirOptions
+	for _, o := range opts {
+		opt.Recursive = opt.Recursive || o.Recursive
+	}
+
+	dirName, err := cleanDirName(dirName)
+	if err != nil {
+		return err
+	}
+
+	wc, id, err := c.getClient(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	info, err := wc.StatFile(ctx, dirName, StatOptions{withDirs: true})
+	if err != nil {
+		return err
+	}
+	if !info.IsDir {
+		return newNotFoundError(id, dirName+"/")
+	}
+	defer c.markUsageStale(ctx, id)
+
+	if opt.Recursive {
+		files, err := wc.Ls(ctx, dirName)
+		if err != nil {
+			return err
+		}
+
+		// The files are deleted one at a time so that their revisions are deleted too.
+		for _, file := range files {
+			if err = wc.DeleteFile(ctx, file); err != nil {
+				return fmt.Errorf("failed to remove %s: %w", file, err)
+			}
+		}
+	}
+
+	err = wc.RemoveDir(ctx, dirName, opt.Recursive)
+	if nfe := (*NotFoundError)(nil); opt.Recursive && errors.As(err, &nfe) {
+		// The directory was removed along with its last file.
+		return nil
+	}
+	return err
+}
+
+func cleanDirName(dirName string) (string, error) {
+	dirName = strings.Trim(dirName, "/")
+	if dirName == "" {
+		return "", fmt.Errorf("a directory name is required")
+	}
+	return dirName, nil
+}
+
+// statNonDir stats a file for the operations that only apply to files, returning an error for a directory.
+func statNonDir(ctx context.Context, wc workspaceClient, fileName string) (FileInfo, error) {
+	info, err := wc.StatFile(ctx, fileName, StatOptions{})
+	if err == nil && info.IsDir {
+		return FileInfo{}, fmt.Errorf("%s is a directory", fileName)
+	}
+	return info, err
+}
+
+// isDirMarker returns whether an object is the marker of a directory created with Mkdir in an object store, which is
+// an empty object named after the directory with a trailing slash. Markers aren't listed as files.
+func isDirMarker(key string) bool {
+	return strings.HasSuffix(key, "/")
+}
@@ -40,3 +40,83 @@ type FileExistsError ConflictError
 func (e *FileExistsError) Error() string {
 	return fmt.Sprintf("file already exists: %s/%s", e.id, e.name)
 }
+
+type ChecksumMismatchError struct {
+	id       string
+	name     string
+	expected string
+	actual   string
+}
+
+func newChecksumMismatchError(id, name, expected, actual string) *ChecksumMismatchError {
+	return &ChecksumMismatchError{id: id, name: name, expected: expected, actual: actual}
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("checksum mismatch: %s/%s (expected: %s, actual: %s)", e.id, e.name, e.expected, e.actual)
+}
+
+type DirectoryNotEmptyError struct {
+	id   string
+	name string
+}
+
+func newDirectoryNotEmptyError(id, name string) *DirectoryNotEmptyError {
+	return &DirectoryNotEmptyError{id: id, name: name}
+}
+
+func (e *DirectoryNotEmptyError) Error() string {
+	return fmt.Sprintf("directory not empty: %s/%s", e.id, e.name)
+}
+
+const (
+	QuotaMaxBytes    = "max bytes"
+	QuotaMaxFiles    = "max files"
+	QuotaMaxFileSize = "max file size"
+)
+
+type QuotaExceededError struct {
+	id    string
+	name  string
+	limit string
+	max   int64
+}
+
+func newQuotaExceededError(id, name, limit string, max int64) *QuotaExceededError {
+	return &QuotaExceededError{id: id, name: name, limit: limit, max: max}
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("quota exceeded: %s/%s (%s: %d)", e.id, e.name, e.limit, e.max)
+}
+
+// Limit returns the limit of the quota that was exceeded, one of QuotaMaxBytes, QuotaMaxFiles or QuotaMaxFileSize.
+func (e *QuotaExceededError) Limit() string {
+	return e.limit
+}
+
+type NameExistsError struct {
+	name string
+	id   string
+}
+
+func newNameExistsError(name, id string) *NameExistsError {
+	return &NameExistsError{name: name, id: id}
+}
+
+func (e *NameExistsError) Error() string {
+	return fmt.Sprintf("workspace name already exists: %s (%s)", e.name, e.id)
+}
+
+// InvalidArgumentError is returned when an argument, such as an option, is invalid.
+type InvalidArgumentError struct {
+	message string
+}
+
+func newInvalidArgumentError(format string, args ...any) *InvalidArgumentError {
+	return &InvalidArgumentError{message: fmt.Sprintf(format, args...)}
+}
+
+func (e *InvalidArgumentError) Error() string {
+	return e.message
+}
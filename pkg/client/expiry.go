@@ -0,0 +1,289 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/xattr"
+)
+
+const (
+	// expiresAtMetadataKey is the S3 and Azure metadata key that the expiry of a file is stored under.
+	expiresAtMetadataKey = "expires_at"
+	// expiresAtXattr is the extended attribute that the expiry of a file is stored in on disk.
+	expiresAtXattr = "user.workspace-provider.expires-at"
+	// expiryDir is the directory, next to the manifests, that the files written with an expiry are indexed in, so that
+	// the expired files of a workspace are found without checking the expiry of every file.
+	expiryDir = "expiry"
+)
+
+func formatExpiresAt(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// parseExpiresAt parses a stored expiry, returning the zero time if there is none or it is invalid, so that the file
+// doesn't expire.
+func parseExpiresAt(value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// metadataExpiresAt returns the expiry from S3 or Azure metadata.
+func metadataExpiresAt[T string | *string](metadata map[string]T) time.Time {
+	return parseExpiresAt(metadataValue(metadata, expiresAtMetadataKey))
+}
+
+// fileExpiresAt returns the expiry of a file on disk.
+func fileExpiresAt(f *os.File) time.Time {
+	return parseExpiresAt(fileAttr(f, expiresAtXattr))
+}
+
+// pathExpiresAt returns the expiry of the file at the path, like fileExpiresAt.
+func pathExpiresAt(path string) time.Time {
+	return parseExpiresAt(pathAttr(path, expiresAtXattr))
+}
+
+// setFileExpiresAt stores the expiry of a file on disk, or removes it if there is none. Like metadata, an error is
+// returned if the file system can't store it, since the file would otherwise never expire.
+func setFileExpiresAt(f *os.File, expiresAt time.Time) error {
+	if expiresAt.IsZero() {
+		setFileAttr(f, expiresAtXattr, "")
+		return nil
+	}
+
+	if err := xattr.FSet(f, expiresAtXattr, []byte(formatExpiresAt(expiresAt))); err != nil {
+		return fmt.Errorf("failed to store expiry: %w", err)
+	}
+	return nil
+}
+
+// isExpired returns whether a file with the given expiry has expired.
+func isExpired(expiresAt time.Time) bool {
+	return !expiresAt.IsZero() && !time.Now().Before(expiresAt)
+}
+
+// expiryClient returns the client that the expiring files of a workspace are indexed in, along with the workspace's
+// directory in it. Each entry is an empty file named after the file and when it expires, such as "a.txt@<unix nanos>".
+func expiryClient(factory workspaceFactory, id string) (workspaceClient, string, error) {
+	mc, name, err := factory.ManifestClient(id)
+	return mc, expiryDir + "/" + strings.TrimSuffix(name, ".json"), err
+}
+
+// indexExpiry adds the entry of a file that expires to the index. It is added before the file is written, so that a
+// failed write leaves an entry behind rather than a file that is never found to expire.
+func indexExpiry(ctx context.Context, factory workspaceFactory, id, fileName string, expiresAt time.Time) error {
+	ec, dir, err := expiryClient(factory, id)
+	if err != nil {
+		return err
+	}
+	if err = ec.WriteFile(ctx, fmt.Sprintf("%s/%s@%d", dir, fileName, expiresAt.UnixNano()), strings.NewReader(""), WriteOptions{}); err != nil {
+		return fmt.Errorf("failed to index expiry of %s: %w", fileName, err)
+	}
+	return nil
+}
+
+// dueExpiries returns the entries of the index under the prefix whose time has come, by file name. Entries are left
+// behind when a file is rewritten, moved or deleted, so the file isn't necessarily expired.
+func dueExpiries(ctx context.Context, factory workspaceFactory, id, prefix string) (map[string][]string, error) {
+	ec, dir, err := expiryClient(factory, id)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := ec.Ls(ctx, path.Join(dir, prefix))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	due := make(map[string][]string)
+	for _, entry := range entries {
+		name, nanos, ok := cutLast(strings.TrimPrefix(entry, dir+"/"), "@")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.ParseInt(nanos, 10, 64); err == nil && !now.Before(time.Unix(0, n)) {
+			due[name] = append(due[name], entry)
+		}
+	}
+	return due, nil
+}
+
+// expiredFiles returns the files under the prefix that have expired, along with their expiry. Only the files with due
+// entries in the index are statted to check.
+func expiredFiles(ctx context.Context, factory workspaceFactory, wc workspaceClient, id, prefix string) (map[string]time.Time, error) {
+	due, err := dueExpiries(ctx, factory, id, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	expired := make(map[string]time.Time, len(due))
+	for name := range due {
+		info, err := wc.StatFile(ctx, name, StatOptions{})
+		if err != nil {
+			if nfe := (*NotFoundError)(nil); errors.As(err, &nfe) {
+				continue
+			}
+			return nil, err
+		}
+		if isExpired(info.ExpiresAt) {
+			expired[name] = info.ExpiresAt
+		}
+	}
+	return expired, nil
+}
+
+// withIndexedExpiry sets the expiry of the listed files that have expired, for providers whose listings don't include
+// the metadata that it is stored in, so that they are hidden like in other providers.
+func withIndexedExpiry(ctx context.Context, factory workspaceFactory, wc workspaceClient, id, prefix string, files []FileInfo, opt LsOptions) ([]FileInfo, error) {
+	if _, ok := wc.(metadataFetcher); !ok || !opt.AsOf.IsZero() || len(files) == 0 {
+		return files, nil
+	}
+
+	expired, err := expiredFiles(ctx, factory, wc, id, prefix)
+	if err != nil {
+		return nil, err
+	}
+	for i := range files {
+		if expiresAt, ok := expired[files[i].Name]; ok {
+			files[i].ExpiresAt = expiresAt
+		}
+	}
+	return files, nil
+}
+
+// indexCopiedExpiry adds the entry of a file that was copied or moved with an expiry to the index.
+func indexCopiedExpiry(ctx context.Context, factory workspaceFactory, wc workspaceClient, id, fileName string) error {
+	info, err := wc.StatFile(ctx, fileName, StatOptions{})
+	if err != nil || info.ExpiresAt.IsZero() {
+		// The file may have been deleted since, which leaves nothing to index.
+		return nil
+	}
+	return indexExpiry(ctx, factory, id, fileName, info.ExpiresAt)
+}
+
+// cutLast slices s around the last instance of sep, like strings.Cut.
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// removeIfExpired removes a file along with its revisions if it has expired, as if it had been swept.
+func (c *Client) removeIfExpired(ctx context.Context, wc workspaceClient, id, fileName string) error {
+	info, err := wc.StatFile(ctx, fileName, StatOptions{})
+	if err != nil || !isExpired(info.ExpiresAt) {
+		return nil
+	}
+	defer c.markUsageStale(ctx, id)
+
+	err = wc.DeleteFile(ctx, fileName)
+	if nfe := (*NotFoundError)(nil); errors.As(err, &nfe) {
+		return nil
+	}
+	return err
+}
+
+// SweepExpired removes the files of a workspace that have expired, along with their revisions, and returns their
+// names. Expired files are hidden as soon as they expire, but they take up space until they are swept. Only the files
+// with due entries in the expiry index are checked, and the entries are removed once they have been.
+func (c *Client) SweepExpired(ctx context.Context, id string) ([]string, error) {
+	factory, id, err := c.getWorkspaceFactory(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	wc, err := factory.New(id)
+	if err != nil {
+		return nil, err
+	}
+
+	due, err := dueExpiries(ctx, factory, id, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	defer func() {
+		if len(removed) > 0 {
+			c.markUsageStale(ctx, id)
+		}
+	}()
+
+	ec, _, err := expiryClient(factory, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range slices.Sorted(maps.Keys(due)) {
+		info, err := wc.StatFile(ctx, name, StatOptions{})
+		if nfe := (*NotFoundError)(nil); err != nil && !errors.As(err, &nfe) {
+			return removed, err
+		}
+
+		if err == nil && isExpired(info.ExpiresAt) {
+			// This also removes the revisions of the file.
+			if err = wc.DeleteFile(ctx, name); err != nil {
+				if nfe := (*NotFoundError)(nil); !errors.As(err, &nfe) {
+					return removed, fmt.Errorf("failed to remove %s: %w", name, err)
+				}
+			} else {
+				removed = append(removed, name)
+			}
+		}
+
+		// The file has been removed, or it was deleted or rewritten without the expiry of these entries, which a
+		// rewrite with a new expiry has its own entry for.
+		for _, entry := range due[name] {
+			// Best effort, a remaining entry is checked again by the next sweep.
+			_ = ec.DeleteFile(ctx, entry)
+		}
+	}
+
+	return removed, nil
+}
+
+// SweepAllExpired removes the expired files of every workspace of a provider, and returns the names of the removed
+// files by workspace ID. Workspaces that fail to be swept don't stop the others from being swept, and their errors are
+// returned together.
+func (c *Client) SweepAllExpired(ctx context.Context, provider string) (map[string][]string, error) {
+	factory, err := c.getFactory(provider)
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := factory.List(ctx, "", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		removed = make(map[string][]string)
+		errs    []error
+	)
+	for _, id := range ids {
+		files, err := c.SweepExpired(ctx, id)
+		if len(files) > 0 {
+			removed[id] = files
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to sweep %s: %w", id, err))
+		}
+	}
+
+	return removed, errors.Join(errs...)
+}
@@ -2,6 +2,10 @@ package client
 
 import "time"
 
+// maxWrittenAt is how many write times are kept in the revision info of a file. Older writes fall back to the time
+// their revision was stored, as for files written before write times were recorded.
+const maxWrittenAt = 1000
+
 type FileInfo struct {
 	WorkspaceID string    `json:"workspaceID"`
 	Name        string    `json:"name"`
@@ -9,6 +13,17 @@ type FileInfo struct {
 	ModTime     time.Time `json:"modTime"`
 	MimeType    string    `json:"mimeType"`
 	RevisionID  string    `json:"revisionID"`
+	// Checksum is the hex encoded SHA-256 checksum of the file, if it was stored when the file was written. It isn't
+	// returned when listing files in S3.
+	Checksum string `json:"checksum,omitempty"`
+	// IsDir is true for directories, which are returned by non-recursive listings and by statting them. Their names end
+	// with "/".
+	IsDir bool `json:"isDir,omitempty"`
+	// Metadata is the user-defined metadata of the file. It isn't returned when listing files in S3, unless the listing
+	// filters on it.
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// ExpiresAt is when the file expires, if it was written with an expiry. It isn't returned when listing files in S3.
+	ExpiresAt time.Time `json:"expiresAt,omitzero"`
 }
 
 func (f *FileInfo) GetRevisionID() (string, error) {
@@ -21,6 +36,11 @@ func (f *FileInfo) GetRevisionID() (string, error) {
 type RevisionInfo struct {
 	FileInfo
 	RevisionID string `json:"revisionID"`
+	// StoredSize is the number of bytes used to store the revision. It is smaller than Size for delta encoded revisions.
+	StoredSize int64 `json:"storedSize"`
+	// ReconstructionCost is the number of deltas that are applied to reconstruct the revision. It is zero for revisions
+	// that are stored as full copies.
+	ReconstructionCost int `json:"reconstructionCost"`
 }
 
 func (r *RevisionInfo) GetRevisionID() (string, error) {
@@ -29,4 +49,35 @@ func (r *RevisionInfo) GetRevisionID() (string, error) {
 
 type revisionInfo struct {
 	CurrentID int64 `json:"currentID"`
+	// Deltas holds the revisions that are stored as a delta against another revision, keyed by revision ID.
+	Deltas map[int64]revisionDelta `json:"deltas,omitempty"`
+	// WrittenAt holds the time at which the file was written, keyed by the current ID after that write. Only the last
+	// maxWrittenAt writes are kept.
+	WrittenAt map[int64]time.Time `json:"writtenAt,omitempty"`
+}
+
+func (r *revisionInfo) advance(now time.Time) {
+	r.CurrentID++
+	r.touch(now)
+
+	if len(r.WrittenAt) > maxWrittenAt {
+		for id := range r.WrittenAt {
+			if id <= r.CurrentID-maxWrittenAt {
+				delete(r.WrittenAt, id)
+			}
+		}
+	}
+}
+
+// touch records the time at which the current content was written, without advancing the current ID.
+func (r *revisionInfo) touch(now time.Time) {
+	if r.WrittenAt == nil {
+		r.WrittenAt = make(map[int64]time.Time)
+	}
+	r.WrittenAt[r.CurrentID] = now.UTC()
+}
+
+type revisionDelta struct {
+	BaseID int64 `json:"baseID"`
+	Size   int64 `json:"size"`
 }
@@ -0,0 +1,151 @@
+package client
+
+import (
+	"context"
+	"sync"
+)
+
+const (
+	defaultListLimit = 1000
+	// manifestReadConcurrency is the number of manifests that are read at once when listing workspaces.
+	manifestReadConcurrency = 16
+)
+
+type ListOptions struct {
+	// Labels, if set, only lists the workspaces whose manifests have all of these labels.
+	Labels map[string]string
+	// Limit is the maximum number of workspaces in a page. The default is 1000.
+	Limit int
+	// Continuation lists the page after the one that returned it.
+	Continuation string
+}
+
+// WorkspacePage is a page of workspaces returned by List.
+type WorkspacePage struct {
+	Workspaces []WorkspaceInfo `json:"workspaces"`
+	// Continuation is set in ListOptions to list the next page. It is empty on the last page.
+	Continuation string `json:"continuation,omitempty"`
+}
+
+// List lists a page of the workspaces of a provider, sorted by ID, along with their manifests. For the directory
+// provider, these are the workspaces in the data home, and for S3 and Azure, the ones in the bucket or container.
+// Workspaces that only exist as a manifest because nothing has been written to them yet are included, but manifests
+// left behind by workspaces that don't exist are not. The workspaces are listed from the backend a page at a time,
+// starting after the continuation, and their manifests are read at once.
+func (c *Client) List(ctx context.Context, provider string, opts ...ListOptions) (WorkspacePage, error) {
+	var opt ListOptions
+	for _, o := range opts {
+		if o.Labels != nil {
+			opt.Labels = o.Labels
+		}
+		if o.Limit != 0 {
+			opt.Limit = o.Limit
+		}
+		if o.Continuation != "" {
+			opt.Continuation = o.Continuation
+		}
+	}
+	if opt.Limit < 0 {
+		return WorkspacePage{}, newInvalidArgumentError("invalid limit: %d", opt.Limit)
+	}
+	if opt.Limit == 0 {
+		opt.Limit = defaultListLimit
+	}
+
+	if provider == "" {
+		provider = DirectoryProvider
+	}
+
+	factory, err := c.getFactory(provider)
+	if err != nil {
+		return WorkspacePage{}, err
+	}
+
+	var page WorkspacePage
+	// The continuation is the last workspace of the previous page, which may have been removed since.
+	after := opt.Continuation
+	for {
+		ids, err := factory.List(ctx, after, opt.Limit)
+		if err != nil {
+			return WorkspacePage{}, err
+		}
+
+		infos, err := readManifests(ctx, factory, ids)
+		if err != nil {
+			return WorkspacePage{}, err
+		}
+
+		for i, info := range infos {
+			if info == nil || !matchesMetadata(info.Labels, opt.Labels) {
+				continue
+			}
+
+			page.Workspaces = append(page.Workspaces, *info)
+			if len(page.Workspaces) == opt.Limit {
+				if i < len(ids)-1 || len(ids) == opt.Limit {
+					page.Continuation = ids[i]
+				}
+				return page, nil
+			}
+		}
+
+		if len(ids) < opt.Limit {
+			return page, nil
+		}
+		after = ids[len(ids)-1]
+	}
+}
+
+// readManifests reads the manifests of the workspaces at once, leaving nil for those that don't exist.
+func readManifests(ctx context.Context, factory workspaceFactory, ids []string) ([]*WorkspaceInfo, error) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	var (
+		infos   = make([]*WorkspaceInfo, len(ids))
+		indexes = make(chan int)
+		wg      sync.WaitGroup
+	)
+	for range manifestReadConcurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				info, found, err := loadManifest(ctx, factory, ids[i])
+				if err != nil {
+					cancel(err)
+					continue
+				}
+
+				// A workspace without a manifest was listed because it has a directory.
+				exists := !found
+				if found {
+					if exists, err = workspaceExists(ctx, factory, ids[i], info); err != nil {
+						cancel(err)
+						continue
+					}
+				}
+				if exists {
+					infos[i] = &info
+				}
+			}
+		}()
+	}
+
+	for i := range ids {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if err := context.Cause(ctx); err != nil {
+		return nil, err
+	}
+	return infos, nil
+}
@@ -0,0 +1,223 @@
+package client
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+const (
+	LsSortByName    = "name"
+	LsSortBySize    = "size"
+	LsSortByModTime = "modTime"
+
+	// defaultLsPageSize is the number of files in a page when no limit is given, which matches the S3 default.
+	defaultLsPageSize = 1000
+	// maxLsPageSize is the largest number of files in a page, which matches the Azure maximum. Larger limits are reduced
+	// to it.
+	maxLsPageSize = 5000
+)
+
+// LsPage is a page of files returned by LsPage.
+type LsPage struct {
+	Files []FileInfo `json:"files"`
+	// Continuation is set in LsOptions to list the next page. It is empty on the last page.
+	Continuation string `json:"continuation,omitempty"`
+}
+
+func completeLsOptions(opts ...LsOptions) LsOptions {
+	var opt LsOptions
+	for _, o := range opts {
+		if o.Recursive != nil {
+			opt.Recursive = o.Recursive
+		}
+		if !o.AsOf.IsZero() {
+			opt.AsOf = o.AsOf
+		}
+		if o.MinSize != 0 {
+			opt.MinSize = o.MinSize
+		}
+		if o.MaxSize != 0 {
+			opt.MaxSize = o.MaxSize
+		}
+		if !o.ModifiedAfter.IsZero() {
+			opt.ModifiedAfter = o.ModifiedAfter
+		}
+		if !o.ModifiedBefore.IsZero() {
+			opt.ModifiedBefore = o.ModifiedBefore
+		}
+		if o.SortBy != "" {
+			opt.SortBy = o.SortBy
+		}
+		opt.Descending = opt.Descending || o.Descending
+		if o.Limit != 0 {
+			opt.Limit = o.Limit
+		}
+		if o.Continuation != "" {
+			opt.Continuation = o.Continuation
+		}
+		opt.Include = append(opt.Include, o.Include...)
+		opt.Exclude = append(opt.Exclude, o.Exclude...)
+		if len(o.Metadata) > 0 {
+			if opt.Metadata == nil {
+				opt.Metadata = make(map[string]string, len(o.Metadata))
+			}
+			maps.Copy(opt.Metadata, o.Metadata)
+		}
+	}
+
+	return opt
+}
+
+func (o LsOptions) validate() error {
+	if o.Limit < 0 {
+		return newInvalidArgumentError("invalid limit: %d", o.Limit)
+	}
+
+	for _, pattern := range slices.Concat(o.Include, o.Exclude) {
+		if !doublestar.ValidatePattern(pattern) {
+			return newInvalidArgumentError("invalid pattern: %s", pattern)
+		}
+	}
+
+	switch o.SortBy {
+	case "", LsSortByName, LsSortBySize, LsSortByModTime:
+		return nil
+	default:
+		return newInvalidArgumentError("invalid sort field: %s", o.SortBy)
+	}
+}
+
+// listPrefix returns the prefix to list the files matching the include patterns with. If no prefix is given, then the
+// directory that all the include patterns are under is used, so that the backend doesn't list files that can't match.
+func listPrefix(prefix string, include []string) string {
+	if prefix != "" || len(include) == 0 {
+		return prefix
+	}
+
+	var common []string
+	for i, pattern := range include {
+		base, _ := doublestar.SplitPattern(pattern)
+		if base == "." || base == "/" {
+			return ""
+		}
+
+		parts := strings.Split(base, "/")
+		if i == 0 {
+			common = parts
+			continue
+		}
+
+		n := 0
+		for n < len(common) && n < len(parts) && common[n] == parts[n] {
+			n++
+		}
+		common = common[:n]
+	}
+
+	return path.Join(common...)
+}
+
+// matchesPatterns returns whether the name matches any of the include patterns, if there are any, and none of the
+// exclude patterns. The trailing slash of directory names is ignored.
+func matchesPatterns(name string, opt LsOptions) bool {
+	name = strings.TrimSuffix(name, "/")
+	if len(opt.Include) > 0 && !slices.ContainsFunc(opt.Include, func(pattern string) bool {
+		return doublestar.MatchUnvalidated(pattern, name)
+	}) {
+		return false
+	}
+
+	return !slices.ContainsFunc(opt.Exclude, func(pattern string) bool {
+		return doublestar.MatchUnvalidated(pattern, name)
+	})
+}
+
+// lsDir lists the files and directories immediately under the prefix.
+func lsDir(ctx context.Context, factory workspaceFactory, wc workspaceClient, workspaceID, prefix string, opt LsOptions) ([]FileInfo, error) {
+	files, err := wc.LsDir(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	if files, err = withIndexedExpiry(ctx, factory, wc, workspaceID, prefix, files, opt); err != nil {
+		return nil, err
+	}
+
+	if !opt.AsOf.IsZero() {
+		return lsWithInfoAsOf(ctx, wc, workspaceID, files, opt.AsOf)
+	}
+	return fetchMetadata(ctx, wc, files, opt)
+}
+
+// lsPage lists a page of files and applies the AsOf and filter options to it.
+func lsPage(ctx context.Context, factory workspaceFactory, wc workspaceClient, workspaceID, prefix string, opt LsOptions) (LsPage, error) {
+	limit := min(opt.Limit, maxLsPageSize)
+	if limit == 0 {
+		limit = defaultLsPageSize
+	}
+
+	files, continuation, err := wc.LsPage(ctx, listPrefix(prefix, opt.Include), limit, opt.Continuation)
+	if err != nil {
+		return LsPage{}, err
+	}
+	if files, err = withIndexedExpiry(ctx, factory, wc, workspaceID, listPrefix(prefix, opt.Include), files, opt); err != nil {
+		return LsPage{}, err
+	}
+
+	if !opt.AsOf.IsZero() {
+		if files, err = lsWithInfoAsOf(ctx, wc, workspaceID, files, opt.AsOf); err != nil {
+			return LsPage{}, err
+		}
+	}
+	if files, err = fetchMetadata(ctx, wc, files, opt); err != nil {
+		return LsPage{}, err
+	}
+
+	return LsPage{Files: filterFileInfos(files, opt), Continuation: continuation}, nil
+}
+
+// filterFileInfos removes the files that have expired, unless listing as of a time, or that don't match the patterns
+// or metadata, or the size and modification time filters, which directories are not subject to.
+func filterFileInfos(files []FileInfo, opt LsOptions) []FileInfo {
+	return slices.DeleteFunc(files, func(f FileInfo) bool {
+		if !matchesPatterns(f.Name, opt) || len(opt.Metadata) > 0 && !matchesMetadata(f.Metadata, opt.Metadata) {
+			return true
+		}
+		if opt.AsOf.IsZero() && isExpired(f.ExpiresAt) {
+			return true
+		}
+		if f.IsDir {
+			return false
+		}
+		return f.Size < opt.MinSize ||
+			opt.MaxSize != 0 && f.Size > opt.MaxSize ||
+			!opt.ModifiedAfter.IsZero() && !f.ModTime.After(opt.ModifiedAfter) ||
+			!opt.ModifiedBefore.IsZero() && !f.ModTime.Before(opt.ModifiedBefore)
+	})
+}
+
+func sortFileInfos(files []FileInfo, opt LsOptions) []FileInfo {
+	slices.SortStableFunc(files, func(a, b FileInfo) int {
+		var c int
+		switch opt.SortBy {
+		case LsSortBySize:
+			c = cmp.Compare(a.Size, b.Size)
+		case LsSortByModTime:
+			c = a.ModTime.Compare(b.ModTime)
+		}
+		if c == 0 {
+			c = strings.Compare(a.Name, b.Name)
+		}
+		if opt.Descending {
+			return -c
+		}
+		return c
+	})
+
+	return files
+}
@@ -0,0 +1,291 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// manifestsDir is the directory that the manifests of workspaces are kept in, next to the workspaces themselves.
+	manifestsDir = "manifests"
+	// maxManifestKeyLength is the length of the longest escaped key, which leaves room in a file name for the suffixes
+	// of the records kept under it. Longer keys are hashed.
+	maxManifestKeyLength = 200
+)
+
+// WorkspaceInfo is the manifest of a workspace, which is recorded when it is created.
+type WorkspaceInfo struct {
+	ID       string `json:"id"`
+	Provider string `json:"provider"`
+	// Name is the unique name of the workspace, if it has one.
+	Name string `json:"name,omitempty"`
+	// CreatedAt is when the workspace was created. It is zero for workspaces created before manifests were recorded.
+	CreatedAt      time.Time         `json:"createdAt,omitzero"`
+	Labels         map[string]string `json:"labels,omitempty"`
+	Owner          string            `json:"owner,omitempty"`
+	Description    string            `json:"description,omitempty"`
+	FromWorkspaces []string          `json:"fromWorkspaces,omitempty"`
+	// ExpiresAt is when the workspace expires, if it was created with an expiry.
+	ExpiresAt time.Time `json:"expiresAt,omitzero"`
+}
+
+type CreateOptions struct {
+	// Name is a unique name for the workspace, which can be given as "name://<name>" instead of its ID. A
+	// NameExistsError is returned if another workspace has the name.
+	Name string
+	// FromWorkspaces are the workspaces whose files, along with their revisions, are copied into the new workspace.
+	FromWorkspaces []string
+	// Labels are key-value pairs that workspaces can be found by. Keys must be lowercase letters, digits and
+	// underscores.
+	Labels      map[string]string
+	Owner       string
+	Description string
+	// Quota is the quota of the workspace, whose limits replace those of the provider's quota.
+	Quota Quota
+	// ExpiresAt is when the workspace expires. Once it has, it is removed along with its revisions by ReapExpired. Until
+	// then, it can still be used.
+	ExpiresAt time.Time
+	// TTL sets ExpiresAt to this long after the workspace is created. It is mutually exclusive with ExpiresAt.
+	TTL time.Duration
+}
+
+func completeCreateOptions(opts ...CreateOptions) CreateOptions {
+	var opt CreateOptions
+	for _, o := range opts {
+		opt.FromWorkspaces = append(opt.FromWorkspaces, o.FromWorkspaces...)
+		if o.Labels != nil {
+			opt.Labels = o.Labels
+		}
+		if o.Name != "" {
+			opt.Name = o.Name
+		}
+		if o.Owner != "" {
+			opt.Owner = o.Owner
+		}
+		if o.Description != "" {
+			opt.Description = o.Description
+		}
+		opt.Quota = opt.Quota.merge(o.Quota)
+		if !o.ExpiresAt.IsZero() {
+			opt.ExpiresAt = o.ExpiresAt
+		}
+		if o.TTL != 0 {
+			opt.TTL = o.TTL
+		}
+	}
+	return opt
+}
+
+// validateLabels returns an error if any of the label keys are invalid. They follow the same rules as metadata keys.
+func validateLabels(labels map[string]string) error {
+	for key := range labels {
+		if !metadataKeyPattern.MatchString(key) {
+			return newInvalidArgumentError("invalid label key %q: keys must be lowercase letters, digits and underscores, and not start with a digit", key)
+		}
+	}
+	return nil
+}
+
+// Info returns the manifest of a workspace. A workspace created before manifests were recorded only has its ID and
+// provider.
+func (c *Client) Info(ctx context.Context, id string) (WorkspaceInfo, error) {
+	factory, id, err := c.getWorkspaceFactory(ctx, id)
+	if err != nil {
+		return WorkspaceInfo{}, err
+	}
+
+	return readExistingManifest(ctx, factory, id)
+}
+
+// SetLabels replaces the labels of a workspace. An empty map removes them.
+func (c *Client) SetLabels(ctx context.Context, id string, labels map[string]string) error {
+	if err := validateLabels(labels); err != nil {
+		return err
+	}
+
+	factory, id, err := c.getWorkspaceFactory(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	info, err := readExistingManifest(ctx, factory, id)
+	if err != nil {
+		return err
+	}
+
+	info.Labels = labels
+	if len(labels) == 0 {
+		info.Labels = nil
+	}
+	return writeManifest(ctx, factory, info)
+}
+
+// getWorkspaceFactory returns the factory of the provider of a workspace, along with its ID, which is resolved if the
+// workspace was given by name.
+func (c *Client) getWorkspaceFactory(ctx context.Context, id string) (workspaceFactory, string, error) {
+	id, err := c.resolveID(ctx, id)
+	if err != nil {
+		return nil, id, err
+	}
+
+	provider, _, ok := strings.Cut(id, "://")
+	if !ok {
+		return nil, id, fmt.Errorf("invalid workspace id: %s", id)
+	}
+
+	f, err := c.getFactory(provider)
+	return f, id, err
+}
+
+// workspaceIDs returns the sorted IDs of up to limit workspaces after the given directory, or all of them if limit is 0,
+// from the workspace directories and those with manifests, which include the workspaces that nothing has been written
+// to yet. Each of the directories and manifests only needs to hold the first limit of its own after the directory.
+// Manifests kept under an escaped key are left out, since they aren't of workspaces directly under the data home,
+// bucket or container. They sort after the others, so they don't take the place of any in a page.
+func workspaceIDs(dirs, manifests []string, after string, limit int, id func(string) string) []string {
+	manifests = slices.DeleteFunc(manifests, func(name string) bool {
+		return strings.HasPrefix(name, "~")
+	})
+	dirs = slices.Concat(dirs, manifests)
+	slices.Sort(dirs)
+	dirs = slices.Compact(dirs)
+
+	i, found := slices.BinarySearch(dirs, after)
+	if found {
+		i++
+	}
+	dirs = dirs[i:]
+	if limit > 0 && len(dirs) > limit {
+		dirs = dirs[:limit]
+	}
+
+	ids := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		ids = append(ids, id(dir))
+	}
+	return ids
+}
+
+// isManifestName returns whether a workspace directory can have a manifest, which rules out the directories of the data
+// home that aren't workspaces.
+func isManifestName(dir string) bool {
+	return dir != "" && dir != "." && dir != ".." && dir != manifestsDir && dir != revisionsDir
+}
+
+// manifestKey returns the name that the manifest and the other records of a workspace are kept under, given its path
+// relative to the data home, bucket or container. Workspaces directly under it are kept under their directory, and
+// others, such as nested workspaces, under their escaped path with a "~" prefix. Directories that start with a "~"
+// are escaped too, so that the two can't collide.
+func manifestKey(dir string) string {
+	if !strings.Contains(dir, "/") && !strings.HasPrefix(dir, "~") {
+		return dir
+	}
+	if key := "~" + url.PathEscape(dir); len(key) <= maxManifestKeyLength {
+		return key
+	}
+
+	// Escaped paths contain an escaped slash or start with a "~", so they can't collide with a hash.
+	sum := sha256.Sum256([]byte(dir))
+	return "~" + hex.EncodeToString(sum[:])
+}
+
+// readManifest reads the manifest of a workspace. A workspace without one, such as one created before manifests were
+// recorded, has an empty manifest.
+func readManifest(ctx context.Context, factory workspaceFactory, id string) (WorkspaceInfo, error) {
+	info, _, err := loadManifest(ctx, factory, id)
+	return info, err
+}
+
+// readExistingManifest reads the manifest of a workspace like readManifest, but returns a NotFoundError if the
+// workspace doesn't exist, so that a manifest isn't written for it.
+func readExistingManifest(ctx context.Context, factory workspaceFactory, id string) (WorkspaceInfo, error) {
+	info, _, err := loadManifest(ctx, factory, id)
+	if err != nil {
+		return info, err
+	}
+
+	exists, err := workspaceExists(ctx, factory, id, info)
+	if err != nil {
+		return info, err
+	}
+	if !exists {
+		return info, newNotFoundError(id, "")
+	}
+	return info, nil
+}
+
+// workspaceExists returns whether the workspace with the manifest exists. A manifest is recorded when a workspace is
+// created, so a workspace without one, such as one created before manifests were, or with one that wasn't recorded by
+// Create, only exists if it has files.
+func workspaceExists(ctx context.Context, factory workspaceFactory, id string, info WorkspaceInfo) (bool, error) {
+	if !info.CreatedAt.IsZero() {
+		return true, nil
+	}
+
+	wc, err := factory.New(id)
+	if err != nil {
+		return false, err
+	}
+	files, _, err := wc.LsPage(ctx, "", 1, "")
+	return len(files) > 0, err
+}
+
+// loadManifest reads the manifest of a workspace, and returns whether it has one.
+func loadManifest(ctx context.Context, factory workspaceFactory, id string) (WorkspaceInfo, bool, error) {
+	provider, _, _ := strings.Cut(id, "://")
+	info := WorkspaceInfo{ID: id, Provider: provider}
+
+	mc, name, err := factory.ManifestClient(id)
+	if err != nil {
+		return info, false, err
+	}
+
+	f, err := mc.OpenFile(ctx, name, OpenOptions{})
+	if err != nil {
+		if nfe := (*NotFoundError)(nil); errors.As(err, &nfe) {
+			return info, false, nil
+		}
+		return info, false, err
+	}
+	defer f.Close()
+
+	if err = json.NewDecoder(f).Decode(&info); err != nil {
+		return info, true, fmt.Errorf("failed to read manifest of workspace %s: %w", id, err)
+	}
+	return info, true, nil
+}
+
+func writeManifest(ctx context.Context, factory workspaceFactory, info WorkspaceInfo) error {
+	b, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+
+	mc, name, err := factory.ManifestClient(info.ID)
+	if err != nil {
+		return err
+	}
+	return mc.WriteFile(ctx, name, bytes.NewReader(b), WriteOptions{CreateRevision: &[]bool{false}[0]})
+}
+
+func deleteManifest(ctx context.Context, factory workspaceFactory, id string) error {
+	mc, name, err := factory.ManifestClient(id)
+	if err != nil {
+		return err
+	}
+
+	err = mc.DeleteFile(ctx, name)
+	if nfe := (*NotFoundError)(nil); errors.As(err, &nfe) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,254 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/xattr"
+)
+
+const (
+	// metadataXattr is the extended attribute that the user-defined metadata of a file is stored in on disk, as JSON.
+	metadataXattr = "user.workspace-provider.metadata"
+	// maxMetadataSize is the largest total size of the keys and values of the user-defined metadata of a file. S3 limits
+	// the metadata of an object to 2KB, part of which is used by the checksum and expiry stored alongside it.
+	maxMetadataSize = 2048 - 128
+	// metadataFetchConcurrency is the number of files whose metadata is fetched at once when filtering on it.
+	metadataFetchConcurrency = 16
+)
+
+// metadataKeyPattern matches the metadata keys that every backend can store. Azure requires keys to be C# identifiers
+// and doesn't preserve their case, and S3 lowercases them, so only lowercase keys are allowed.
+var metadataKeyPattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// validateMetadata returns an InvalidArgumentError if any of the metadata keys can't be stored, or are used by the
+// workspace provider, or if the metadata is too large for every backend to store.
+func validateMetadata(metadata map[string]string) error {
+	var size int
+	for key, value := range metadata {
+		if !metadataKeyPattern.MatchString(key) {
+			return newInvalidArgumentError("invalid metadata key %q: keys must be lowercase letters, digits and underscores, and not start with a digit", key)
+		}
+		if key == checksumMetadataKey || key == expiresAtMetadataKey {
+			return newInvalidArgumentError("invalid metadata key %q: the key is reserved", key)
+		}
+		size += len(key) + len(value)
+	}
+	if size > maxMetadataSize {
+		return newInvalidArgumentError("invalid metadata: the keys and values total %d bytes, more than the maximum of %d", size, maxMetadataSize)
+	}
+	return nil
+}
+
+// matchesMetadata returns whether the metadata has all the given values.
+func matchesMetadata(metadata, values map[string]string) bool {
+	for key, value := range values {
+		if v, ok := metadata[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// objectMetadata returns the S3 or Azure metadata of an object with the user-defined metadata, checksum and expiry.
+func objectMetadata(metadata map[string]string, checksum string, expiresAt time.Time) map[string]string {
+	result := make(map[string]string, len(metadata)+2)
+	for key, value := range metadata {
+		result[key] = value
+	}
+	if checksum != "" {
+		result[checksumMetadataKey] = checksum
+	}
+	if !expiresAt.IsZero() {
+		result[expiresAtMetadataKey] = formatExpiresAt(expiresAt)
+	}
+	return result
+}
+
+// metadataValue returns the value of a key from S3 or Azure metadata. Azure doesn't preserve the case of metadata keys.
+func metadataValue[T string | *string](metadata map[string]T, key string) string {
+	for k, value := range metadata {
+		if strings.EqualFold(k, key) {
+			switch v := any(value).(type) {
+			case string:
+				return v
+			case *string:
+				if v != nil {
+					return *v
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// userMetadata returns the user-defined metadata from S3 or Azure metadata, or nil if there is none. Azure doesn't
+// preserve the case of metadata keys.
+func userMetadata[T string | *string](metadata map[string]T) map[string]string {
+	var result map[string]string
+	for key, value := range metadata {
+		key = strings.ToLower(key)
+		if key == checksumMetadataKey || key == expiresAtMetadataKey {
+			continue
+		}
+
+		var v string
+		switch value := any(value).(type) {
+		case string:
+			v = value
+		case *string:
+			if value == nil {
+				continue
+			}
+			v = *value
+		}
+
+		if result == nil {
+			result = make(map[string]string, len(metadata))
+		}
+		result[key] = v
+	}
+	return result
+}
+
+// fileMetadata returns the user-defined metadata of a file on disk, or nil if it has none.
+func fileMetadata(f *os.File) map[string]string {
+	return decodeMetadata(fileAttr(f, metadataXattr))
+}
+
+// pathMetadata returns the user-defined metadata of the file at the path, like fileMetadata.
+func pathMetadata(path string) map[string]string {
+	return decodeMetadata(pathAttr(path, metadataXattr))
+}
+
+func decodeMetadata(value string) map[string]string {
+	if value == "" {
+		return nil
+	}
+
+	var metadata map[string]string
+	if err := json.Unmarshal([]byte(value), &metadata); err != nil || len(metadata) == 0 {
+		return nil
+	}
+	return metadata
+}
+
+// setFileMetadata stores the user-defined metadata of a file on disk, or removes it if there is none. Unlike checksums
+// and content types, metadata can't be recomputed, so an error is returned if the file system can't store it.
+func setFileMetadata(f *os.File, metadata map[string]string) error {
+	if len(metadata) == 0 {
+		setFileAttr(f, metadataXattr, "")
+		return nil
+	}
+
+	value, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	if err = xattr.FSet(f, metadataXattr, value); err != nil {
+		return fmt.Errorf("failed to store metadata: %w", err)
+	}
+	return nil
+}
+
+// SetMetadata replaces the user-defined metadata of a file. An empty map removes it. Changing the metadata of a file
+// doesn't create a revision of it.
+func (c *Client) SetMetadata(ctx context.Context, id, fileName string, metadata map[string]string) error {
+	if err := validateMetadata(metadata); err != nil {
+		return err
+	}
+
+	wc, id, err := c.getClient(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return wc.SetMetadata(ctx, fileName, metadata)
+}
+
+// GetMetadata returns the user-defined metadata of a file, or nil if it has none.
+func (c *Client) GetMetadata(ctx context.Context, id, fileName string) (map[string]string, error) {
+	wc, id, err := c.getClient(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if fetcher, ok := wc.(metadataFetcher); ok {
+		return fetcher.fetchMetadata(ctx, fileName)
+	}
+
+	info, err := wc.StatFile(ctx, fileName, StatOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return info.Metadata, nil
+}
+
+// metadataFetcher is implemented by providers whose listings don't include the metadata of files, so that it is
+// fetched for each file when filtering on it.
+type metadataFetcher interface {
+	fetchMetadata(context.Context, string) (map[string]string, error)
+}
+
+// fetchMetadata fetches the metadata of the listed files when filtering on it, if the provider's listings don't
+// include it. Files listed as of a time were statted, so they already have it. The metadata of several files is fetched
+// at once.
+func fetchMetadata(ctx context.Context, wc workspaceClient, files []FileInfo, opt LsOptions) ([]FileInfo, error) {
+	fetcher, ok := wc.(metadataFetcher)
+	if !ok || len(opt.Metadata) == 0 || !opt.AsOf.IsZero() {
+		return files, nil
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	var (
+		indexes = make(chan int)
+		wg      sync.WaitGroup
+	)
+	for range metadataFetchConcurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				metadata, err := fetcher.fetchMetadata(ctx, files[i].Name)
+				if err != nil {
+					// A file that was deleted since it was listed won't match.
+					if nfe := (*NotFoundError)(nil); !errors.As(err, &nfe) {
+						cancel(err)
+					}
+					continue
+				}
+				files[i].Metadata = metadata
+			}
+		}()
+	}
+
+	for i, file := range files {
+		if file.IsDir || !matchesPatterns(file.Name, opt) {
+			continue
+		}
+
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if err := context.Cause(ctx); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
@@ -0,0 +1,105 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// moveFile moves a file within a workspace, along with its revisions and revision info. rename moves a single file
+// without its revisions.
+//
+// If the destination exists, then it is replaced and its revisions are deleted. The move is not atomic: if it fails
+// part way, the live file may have been moved without some of its revisions.
+func moveFile(ctx context.Context, wc workspaceClient, workspaceID, from, to string, opt MoveOptions, rename func(context.Context, string, string) error) error {
+	if _, err := statNonDir(ctx, wc, from); err != nil {
+		return err
+	}
+
+	rc := wc.RevisionClient()
+	if rc == nil {
+		// This is either a revisions client or a workspace without revisions, so there is nothing else to move.
+		return rename(ctx, from, to)
+	}
+
+	info, err := getRevisionInfo(ctx, rc, from)
+	if err != nil {
+		return err
+	}
+
+	if opt.LatestRevisionID != "" && opt.LatestRevisionID != strconv.FormatInt(info.CurrentID, 10) {
+		return newConflictError(workspaceID, from, opt.LatestRevisionID, strconv.FormatInt(info.CurrentID, 10))
+	}
+
+	if from == to {
+		return nil
+	}
+
+	destExists := true
+	if _, err = wc.StatFile(ctx, to, StatOptions{}); err != nil {
+		if nfe := (*NotFoundError)(nil); !errors.As(err, &nfe) {
+			return err
+		}
+		destExists = false
+	}
+
+	if opt.IfNotExists && destExists {
+		return &FileExistsError{id: workspaceID, name: to}
+	}
+
+	if opt.DestinationLatestRevisionID != "" {
+		destInfo, err := getRevisionInfo(ctx, rc, to)
+		if err != nil {
+			return err
+		}
+		if opt.DestinationLatestRevisionID != strconv.FormatInt(destInfo.CurrentID, 10) {
+			return newConflictError(workspaceID, to, opt.DestinationLatestRevisionID, strconv.FormatInt(destInfo.CurrentID, 10))
+		}
+	}
+
+	if destExists {
+		// Deleting the destination also deletes its revisions, so that they don't mix with the moved ones.
+		if err = wc.DeleteFile(ctx, to); err != nil {
+			return err
+		}
+	}
+
+	if err = rename(ctx, from, to); err != nil {
+		return err
+	}
+
+	if err = moveRevisions(ctx, rc, from, to, info); err != nil {
+		return fmt.Errorf("failed to move revisions: %w", err)
+	}
+
+	return nil
+}
+
+// moveRevisions moves the revisions of a file, including delta encoded ones, and then its revision info.
+func moveRevisions(ctx context.Context, client workspaceClient, from, to string, info revisionInfo) error {
+	if info.CurrentID == -1 {
+		return nil
+	}
+
+	files, err := client.StatWithPrefix(ctx, from+".")
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		suffix := strings.TrimPrefix(f.Name, from+".")
+		if _, ok := parseRevisionID(strings.TrimSuffix(suffix, deltaSuffix)); !ok {
+			// This is either the revision info file or a revision of a different file that shares this prefix.
+			continue
+		}
+
+		if err = client.MoveFile(ctx, f.Name, fmt.Sprintf("%s.%s", to, suffix), MoveOptions{}); err != nil {
+			return err
+		}
+	}
+
+	// The revision info is moved last so that the counters keep pointing at the revisions until they have all moved.
+	return client.MoveFile(ctx, from+".json", to+".json", MoveOptions{})
+}
@@ -0,0 +1,213 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	// NamePrefix is the prefix of a workspace name that can be given wherever a workspace ID is taken, such as
+	// "name://my-workspace".
+	NamePrefix = "name://"
+
+	// namesDir is the directory, next to the manifests, that workspace names are registered in.
+	namesDir = "names"
+)
+
+// namePattern matches the names that workspaces can be given. Names are lowercase so that they are unique on
+// case-insensitive file systems.
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,62}$`)
+
+// WorkspaceName is a name registered for a workspace.
+type WorkspaceName struct {
+	Name string `json:"name"`
+	ID   string `json:"id"`
+}
+
+func validateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return newInvalidArgumentError("invalid workspace name %q: names must be at most 63 lowercase letters, digits, dots, dashes and underscores, and start with a letter or digit", name)
+	}
+	return nil
+}
+
+// Resolve returns the ID of the workspace with the given name, which can have the "name://" prefix. Names are looked
+// up in the backend of every provider, so they are shared by every client using the same backends.
+func (c *Client) Resolve(ctx context.Context, name string) (string, error) {
+	name = strings.TrimPrefix(name, NamePrefix)
+	if err := validateName(name); err != nil {
+		return "", err
+	}
+
+	for _, provider := range slices.Sorted(slices.Values(c.Providers())) {
+		id, found, err := readName(ctx, c.factories[provider], name)
+		if err != nil {
+			return "", err
+		}
+		if found {
+			return id, nil
+		}
+	}
+
+	return "", newNotFoundError(strings.TrimSuffix(NamePrefix, "/"), name)
+}
+
+// resolveID returns the ID of a workspace given by name, or the ID itself if it isn't a name.
+func (c *Client) resolveID(ctx context.Context, id string) (string, error) {
+	if !strings.HasPrefix(id, NamePrefix) {
+		return id, nil
+	}
+	return c.Resolve(ctx, id)
+}
+
+// Rename changes the name of a workspace, or removes its name if the new name is empty. A NameExistsError is returned
+// if another workspace has the name, and a NotFoundError if the workspace doesn't exist. The new name is registered
+// before the manifest is changed and the old name is removed, and the rename is undone if either fails.
+func (c *Client) Rename(ctx context.Context, id, name string) error {
+	name = strings.TrimPrefix(name, NamePrefix)
+	if name != "" {
+		if err := validateName(name); err != nil {
+			return err
+		}
+	}
+
+	factory, id, err := c.getWorkspaceFactory(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	info, err := readExistingManifest(ctx, factory, id)
+	if err != nil {
+		return err
+	}
+	if info.Name == name {
+		return nil
+	}
+
+	if name != "" {
+		if err = c.registerName(ctx, factory, name, id); err != nil {
+			return err
+		}
+	}
+
+	// undo restores the manifest if it was changed, and removes the new name. This is best effort, using a context
+	// that isn't canceled so that the rename is undone even if it was.
+	undo := func(restoreManifest bool) {
+		ctx := context.WithoutCancel(ctx)
+		if restoreManifest {
+			_ = writeManifest(ctx, factory, info)
+		}
+		if name != "" {
+			_ = unregisterName(ctx, factory, name, id)
+		}
+	}
+
+	renamed := info
+	renamed.Name = name
+	if err = writeManifest(ctx, factory, renamed); err != nil {
+		undo(false)
+		return err
+	}
+
+	if info.Name != "" {
+		if err = unregisterName(ctx, factory, info.Name, id); err != nil {
+			undo(true)
+			return err
+		}
+	}
+	return nil
+}
+
+// Names lists the registered workspace names of every provider, sorted by name.
+func (c *Client) Names(ctx context.Context) ([]WorkspaceName, error) {
+	var names []WorkspaceName
+	for _, factory := range c.factories {
+		nc := factory.NamesClient()
+		files, err := nc.Ls(ctx, "")
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			name, ok := strings.CutSuffix(file, ".json")
+			if !ok || strings.Contains(name, "/") {
+				continue
+			}
+
+			id, found, err := readName(ctx, factory, name)
+			if err != nil {
+				return nil, err
+			}
+			if found {
+				names = append(names, WorkspaceName{Name: name, ID: id})
+			}
+		}
+	}
+
+	slices.SortFunc(names, func(a, b WorkspaceName) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return names, nil
+}
+
+// registerName registers the name of a workspace in the backend of its provider. The name is only registered if it
+// isn't already, atomically in the backend, so concurrent registrations of the same name can't both succeed. The
+// backends of the other providers are checked first, which isn't atomic.
+func (c *Client) registerName(ctx context.Context, factory workspaceFactory, name, id string) error {
+	existing, err := c.Resolve(ctx, name)
+	if err == nil {
+		return newNameExistsError(name, existing)
+	}
+	if nfe := (*NotFoundError)(nil); !errors.As(err, &nfe) {
+		return err
+	}
+
+	b, err := json.Marshal(WorkspaceName{Name: name, ID: id})
+	if err != nil {
+		return fmt.Errorf("failed to marshal workspace name: %w", err)
+	}
+
+	err = factory.NamesClient().WriteFile(ctx, name+".json", bytes.NewReader(b), WriteOptions{CreateRevision: &[]bool{false}[0], exclusive: true})
+	if fee := (*FileExistsError)(nil); errors.As(err, &fee) {
+		existing, _, _ = readName(ctx, factory, name)
+		return newNameExistsError(name, existing)
+	}
+	return err
+}
+
+// unregisterName removes the name of a workspace, unless it has since been registered for another workspace.
+func unregisterName(ctx context.Context, factory workspaceFactory, name, id string) error {
+	registered, found, err := readName(ctx, factory, name)
+	if err != nil || !found || registered != id {
+		return err
+	}
+
+	err = factory.NamesClient().DeleteFile(ctx, name+".json")
+	if nfe := (*NotFoundError)(nil); errors.As(err, &nfe) {
+		return nil
+	}
+	return err
+}
+
+func readName(ctx context.Context, factory workspaceFactory, name string) (string, bool, error) {
+	f, err := factory.NamesClient().OpenFile(ctx, name+".json", OpenOptions{})
+	if err != nil {
+		if nfe := (*NotFoundError)(nil); errors.As(err, &nfe) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	defer f.Close()
+
+	var wn WorkspaceName
+	if err = json.NewDecoder(f).Decode(&wn); err != nil {
+		return "", false, fmt.Errorf("failed to read workspace name %s: %w", name, err)
+	}
+	return wn.ID, true, nil
+}
@@ -0,0 +1,59 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+func (o OpenOptions) hasRange() bool {
+	return o.Offset != 0 || o.Length != 0
+}
+
+// httpRange formats a byte range as the value of an HTTP Range header. A zero length reads to the end of the file.
+func httpRange(offset, length int64) string {
+	if length == 0 {
+		return fmt.Sprintf("bytes=%d-", offset)
+	}
+	return fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
+}
+
+// contentRangeSize returns the total size from the value of a Content-Range header, or -1 if it is not known.
+func contentRangeSize(contentRange *string) int64 {
+	if contentRange == nil {
+		return -1
+	}
+
+	_, total, ok := strings.Cut(*contentRange, "/")
+	if !ok {
+		return -1
+	}
+
+	size, err := strconv.ParseInt(total, 10, 64)
+	if err != nil {
+		return -1
+	}
+
+	return size
+}
+
+type rangeReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// limitRange restricts a reader to a byte range by discarding the bytes before the offset. It is used for content that
+// the backend cannot read a range of, such as delta encoded revisions.
+func limitRange(rc io.ReadCloser, offset, length int64) (io.ReadCloser, error) {
+	if _, err := io.CopyN(io.Discard, rc, offset); err != nil && err != io.EOF {
+		_ = rc.Close()
+		return nil, err
+	}
+
+	if length == 0 {
+		return rc, nil
+	}
+
+	return rangeReadCloser{Reader: io.LimitReader(rc, length), Closer: rc}, nil
+}
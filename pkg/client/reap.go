@@ -0,0 +1,57 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+type ReapOptions struct {
+	// DryRun returns the workspaces that would be removed without removing them.
+	DryRun bool
+}
+
+// ReapExpired removes the workspaces of a provider that have expired, along with their revisions, and returns their
+// IDs. Workspaces that fail to be removed don't stop the others from being removed, and their errors are returned
+// together.
+func (c *Client) ReapExpired(ctx context.Context, provider string, opts ...ReapOptions) ([]string, error) {
+	var opt ReapOptions
+	for _, o := range opts {
+		opt.DryRun = opt.DryRun || o.DryRun
+	}
+
+	factory, err := c.getFactory(provider)
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := factory.List(ctx, "", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		removed []string
+		errs    []error
+	)
+	for _, id := range ids {
+		info, err := readManifest(ctx, factory, id)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !isExpired(info.ExpiresAt) {
+			continue
+		}
+
+		if !opt.DryRun {
+			if err = c.Rm(ctx, id); err != nil {
+				errs = append(errs, fmt.Errorf("failed to remove %s: %w", id, err))
+				continue
+			}
+		}
+		removed = append(removed, id)
+	}
+
+	return removed, errors.Join(errs...)
+}
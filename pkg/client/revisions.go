@@ -2,13 +2,22 @@ package client
 
 import (
 	"bytes"
+	"cmp"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 )
 
-const revisionsDir = "revisions"
+const (
+	revisionsDir = "revisions"
+	deltaSuffix  = ".delta"
+)
 
 func getRevisionInfo(ctx context.Context, client workspaceClient, fileName string) (revisionInfo, error) {
 	var info revisionInfo
@@ -48,43 +57,277 @@ func writeRevisionInfo(ctx context.Context, client workspaceClient, fileName str
 	return client.WriteFile(ctx, fileName+".json", bytes.NewReader(b), WriteOptions{})
 }
 
-func listRevisions(ctx context.Context, client workspaceClient, workspaceID, fileName string) ([]RevisionInfo, error) {
-	info, err := getRevisionInfo(ctx, client, fileName)
-	if err != nil || info.CurrentID == -1 {
-		return nil, err
+// recordWrite stores the current content of a file as a revision before the file is overwritten. If a latest revision
+// is required by the write options, then a conflict error is returned when it does not match. Writes that don't create
+// a revision only record their time.
+func recordWrite(ctx context.Context, rClient, wClient workspaceClient, workspaceID, fileName string, opt WriteOptions) error {
+	if opt.CreateRevision != nil && !*opt.CreateRevision {
+		return recordWriteTime(ctx, rClient, fileName)
 	}
 
-	revisions := make([]RevisionInfo, 0, info.CurrentID)
-	for i := int64(1); i <= info.CurrentID; i++ {
-		id := fmt.Sprintf("%d", i)
-		f, err := client.StatFile(ctx, fmt.Sprintf("%s.%s", fileName, id), StatOptions{})
+	info, err := getRevisionInfo(ctx, rClient, fileName)
+	if err != nil {
+		if nfe := (*NotFoundError)(nil); !errors.As(err, &nfe) {
+			return err
+		}
+	}
+
+	if opt.LatestRevisionID != "" {
+		requiredLatestRevision, err := strconv.ParseInt(opt.LatestRevisionID, 10, 64)
 		if err != nil {
-			if nfe := (*NotFoundError)(nil); errors.As(err, &nfe) {
-				continue
-			}
+			return fmt.Errorf("failed to parse latest revision for write: %w", err)
+		}
 
-			return nil, err
+		if requiredLatestRevision != info.CurrentID {
+			return newConflictError(workspaceID, fileName, opt.LatestRevisionID, fmt.Sprintf("%d", info.CurrentID))
+		}
+	}
+
+	info.advance(time.Now())
+	if err = writeRevision(ctx, rClient, wClient, fileName, info); err != nil {
+		if nfe := (*NotFoundError)(nil); !errors.As(err, &nfe) {
+			return fmt.Errorf("failed to write revision: %w", err)
+		}
+	}
+
+	var encoded bool
+	if opt.keyframeInterval > 0 {
+		// Best effort, the previous revision is kept as a full copy if this fails.
+		encoded, _ = encodePreviousRevisionAsDelta(ctx, rClient, fileName, &info, opt.keyframeInterval)
+	}
+
+	if err = writeRevisionInfo(ctx, rClient, fileName, info); err != nil {
+		return fmt.Errorf("failed to write revision info: %w", err)
+	}
+
+	if encoded {
+		// Best effort, the full copy is preferred over the delta if it is left behind.
+		_ = deleteRevision(ctx, rClient, fileName, strconv.FormatInt(info.CurrentID-1, 10))
+	}
+
+	return nil
+}
+
+// recordWriteTime records the time of a write that doesn't create a revision as the time the current content was
+// written, so that reads as of an earlier time don't return it.
+func recordWriteTime(ctx context.Context, client workspaceClient, fileName string) error {
+	info, err := getRevisionInfo(ctx, client, fileName)
+	if err != nil {
+		return err
+	}
+	if info.CurrentID == -1 {
+		// Without revision info, reads as of a time use the modification time of the file.
+		return nil
+	}
+
+	info.touch(time.Now())
+	if err = writeRevisionInfo(ctx, client, fileName, info); err != nil {
+		return fmt.Errorf("failed to write revision info: %w", err)
+	}
+	return nil
+}
+
+func listRevisions(ctx context.Context, client workspaceClient, workspaceID, fileName string) ([]RevisionInfo, error) {
+	// A single prefix listing returns every revision with its size and mod time, so there is no need to stat each
+	// candidate revision individually.
+	files, err := client.StatWithPrefix(ctx, fileName+".")
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		revisions = make([]RevisionInfo, 0, len(files))
+		indexes   = make(map[int64]int, len(files))
+		deltaIDs  = make(map[int64]struct{})
+	)
+	for _, f := range files {
+		suffix, isDelta := strings.CutSuffix(strings.TrimPrefix(f.Name, fileName+"."), deltaSuffix)
+		id, ok := parseRevisionID(suffix)
+		if !ok {
+			// This is either the revision info file or a revision of a different file that shares this prefix.
+			continue
 		}
 
 		f.WorkspaceID = workspaceID
 		f.Name = fileName
-		revisions = append(revisions, RevisionInfo{
-			RevisionID: id,
+		rev := RevisionInfo{
+			RevisionID: strconv.FormatInt(id, 10),
 			FileInfo:   f,
+			StoredSize: f.Size,
+		}
+
+		if idx, ok := indexes[id]; ok {
+			// If both a full copy and a delta exist, then the revision was interrupted while being re-encoded.
+			// Prefer the full copy.
+			if !isDelta {
+				revisions[idx] = rev
+				delete(deltaIDs, id)
+			}
+			continue
+		}
+
+		if isDelta {
+			deltaIDs[id] = struct{}{}
+		}
+		indexes[id] = len(revisions)
+		revisions = append(revisions, rev)
+	}
+
+	if len(deltaIDs) > 0 {
+		info, err := getRevisionInfo(ctx, client, fileName)
+		if err != nil {
+			return nil, err
+		}
+
+		for id := range deltaIDs {
+			rev := &revisions[indexes[id]]
+			rev.Size = info.Deltas[id].Size
+			rev.ReconstructionCost = deltaChainLength(info, id)
+		}
+	}
+
+	slices.SortFunc(revisions, compareRevisions)
+
+	return revisions, nil
+}
+
+func paginateRevisions(revisions []RevisionInfo, opt ListRevisionsOptions) ([]RevisionInfo, error) {
+	if opt.NewestFirst {
+		slices.Reverse(revisions)
+	}
+
+	if opt.Cursor != "" {
+		cursor, ok := parseRevisionID(opt.Cursor)
+		if !ok {
+			return nil, newInvalidArgumentError("invalid revision cursor: %s", opt.Cursor)
+		}
+
+		idx := slices.IndexFunc(revisions, func(r RevisionInfo) bool {
+			id, _ := parseRevisionID(r.RevisionID)
+			if opt.NewestFirst {
+				return id < cursor
+			}
+			return id > cursor
 		})
+		if idx == -1 {
+			return nil, nil
+		}
+		revisions = revisions[idx:]
+	}
+
+	if opt.Limit > 0 && len(revisions) > opt.Limit {
+		revisions = revisions[:opt.Limit]
 	}
 
 	return revisions, nil
 }
 
+func parseRevisionID(s string) (int64, bool) {
+	id, err := strconv.ParseInt(s, 10, 64)
+	return id, err == nil && id > 0
+}
+
+func compareRevisions(a, b RevisionInfo) int {
+	aID, _ := parseRevisionID(a.RevisionID)
+	bID, _ := parseRevisionID(b.RevisionID)
+	return cmp.Compare(aID, bID)
+}
+
 func deleteRevision(ctx context.Context, client workspaceClient, fileName string, revisionID string) error {
 	return client.DeleteFile(ctx, fmt.Sprintf("%s.%s", fileName, revisionID))
 }
 
+// deleteRevisions deletes every revision of the file, including those stored as deltas.
+func deleteRevisions(ctx context.Context, client workspaceClient, fileName string, info revisionInfo) {
+	for i := info.CurrentID; i > 0; i-- {
+		// Best effort
+		_ = deleteRevision(ctx, client, fileName, fmt.Sprintf("%d", i))
+	}
+
+	for id := range info.Deltas {
+		// Best effort
+		_ = client.DeleteFile(ctx, deltaFileName(fileName, id))
+	}
+}
+
+// removeRevision deletes a single revision. A delta encoded revision that uses this revision as its base is rewritten
+// as a full copy first so that it can still be reconstructed.
+func removeRevision(ctx context.Context, client workspaceClient, fileName string, revisionID string) error {
+	id, ok := parseRevisionID(revisionID)
+	if !ok {
+		return deleteRevision(ctx, client, fileName, revisionID)
+	}
+
+	info, err := getRevisionInfo(ctx, client, fileName)
+	if err != nil {
+		return err
+	}
+
+	if len(info.Deltas) == 0 {
+		return deleteRevision(ctx, client, fileName, revisionID)
+	}
+
+	for dependentID, delta := range info.Deltas {
+		if delta.BaseID != id {
+			continue
+		}
+
+		content, err := readRevision(ctx, client, fileName, info, dependentID)
+		if err != nil {
+			return fmt.Errorf("failed to reconstruct revision %d: %w", dependentID, err)
+		}
+
+		if err = client.WriteFile(ctx, fmt.Sprintf("%s.%d", fileName, dependentID), bytes.NewReader(content), WriteOptions{}); err != nil {
+			return fmt.Errorf("failed to write revision %d: %w", dependentID, err)
+		}
+
+		delete(info.Deltas, dependentID)
+		if err = writeRevisionInfo(ctx, client, fileName, info); err != nil {
+			return err
+		}
+
+		// Best effort
+		_ = client.DeleteFile(ctx, deltaFileName(fileName, dependentID))
+	}
+
+	if _, ok := info.Deltas[id]; ok {
+		delete(info.Deltas, id)
+		if err = writeRevisionInfo(ctx, client, fileName, info); err != nil {
+			return err
+		}
+
+		return client.DeleteFile(ctx, deltaFileName(fileName, id))
+	}
+
+	return deleteRevision(ctx, client, fileName, revisionID)
+}
+
 func getRevision(ctx context.Context, client workspaceClient, fileName string, revisionID string) (*File, error) {
 	f, err := client.OpenFile(ctx, fmt.Sprintf("%s.%s", fileName, revisionID), OpenOptions{})
 	if err != nil {
-		return nil, err
+		id, ok := parseRevisionID(revisionID)
+		if nfe := (*NotFoundError)(nil); !ok || !errors.As(err, &nfe) {
+			return nil, err
+		}
+
+		// The revision may be stored as a delta.
+		info, infoErr := getRevisionInfo(ctx, client, fileName)
+		if infoErr != nil {
+			return nil, infoErr
+		}
+		if _, ok = info.Deltas[id]; !ok {
+			return nil, err
+		}
+
+		content, err := readRevision(ctx, client, fileName, info, id)
+		if err != nil {
+			return nil, err
+		}
+
+		return &File{
+			ReadCloser: io.NopCloser(bytes.NewReader(content)),
+			RevisionID: revisionID,
+		}, nil
 	}
 
 	return &File{
@@ -92,3 +335,126 @@ func getRevision(ctx context.Context, client workspaceClient, fileName string, r
 		RevisionID: revisionID,
 	}, nil
 }
+
+// readRevision reads the full content of a revision, applying deltas as needed.
+func readRevision(ctx context.Context, client workspaceClient, fileName string, info revisionInfo, id int64) ([]byte, error) {
+	delta, ok := info.Deltas[id]
+	if !ok {
+		return readAll(ctx, client, fmt.Sprintf("%s.%d", fileName, id))
+	}
+
+	if delta.BaseID <= id {
+		return nil, fmt.Errorf("invalid base %d for delta revision %d", delta.BaseID, id)
+	}
+
+	patch, err := readAll(ctx, client, deltaFileName(fileName, id))
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := readRevision(ctx, client, fileName, info, delta.BaseID)
+	if err != nil {
+		return nil, err
+	}
+
+	return applyDelta(base, patch)
+}
+
+// encodePreviousRevisionAsDelta re-encodes the revision before the latest one as a delta against the latest revision,
+// and records it in info. It is called while a write records its revision so that the delta is stored along with the
+// revision info of that write. Once the revision info is written, the full copy of the previous revision can be
+// deleted, which is reported by returning true. The latest revision, and every revision that is a multiple of
+// keyframeInterval, are kept as full copies.
+func encodePreviousRevisionAsDelta(ctx context.Context, client workspaceClient, fileName string, info *revisionInfo, keyframeInterval int64) (bool, error) {
+	id := info.CurrentID - 1
+	if id < 1 || id%keyframeInterval == 0 {
+		return false, nil
+	}
+	if _, ok := info.Deltas[id]; ok {
+		return false, nil
+	}
+
+	target, err := readAll(ctx, client, fmt.Sprintf("%s.%d", fileName, id))
+	if err != nil {
+		if nfe := (*NotFoundError)(nil); errors.As(err, &nfe) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	base, err := readAll(ctx, client, fmt.Sprintf("%s.%d", fileName, info.CurrentID))
+	if err != nil {
+		if nfe := (*NotFoundError)(nil); errors.As(err, &nfe) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	patch := encodeDelta(base, target)
+	if len(patch) >= len(target) {
+		// Not worth it
+		return false, nil
+	}
+
+	if err = client.WriteFile(ctx, deltaFileName(fileName, id), bytes.NewReader(patch), WriteOptions{}); err != nil {
+		return false, err
+	}
+
+	if info.Deltas == nil {
+		info.Deltas = make(map[int64]revisionDelta)
+	}
+	info.Deltas[id] = revisionDelta{BaseID: info.CurrentID, Size: int64(len(target))}
+	return true, nil
+}
+
+// restoreFullRevision rewrites a delta encoded revision as a full copy, so that it no longer depends on its base.
+func restoreFullRevision(ctx context.Context, client workspaceClient, fileName string, id int64) error {
+	info, err := getRevisionInfo(ctx, client, fileName)
+	if err != nil {
+		return err
+	}
+	if _, ok := info.Deltas[id]; !ok {
+		return nil
+	}
+
+	content, err := readRevision(ctx, client, fileName, info, id)
+	if err != nil {
+		return fmt.Errorf("failed to reconstruct revision %d: %w", id, err)
+	}
+
+	if err = client.WriteFile(ctx, fmt.Sprintf("%s.%d", fileName, id), bytes.NewReader(content), WriteOptions{}); err != nil {
+		return err
+	}
+
+	delete(info.Deltas, id)
+	if err = writeRevisionInfo(ctx, client, fileName, info); err != nil {
+		return err
+	}
+
+	// Best effort, the full copy is preferred over the delta if it is left behind.
+	_ = client.DeleteFile(ctx, deltaFileName(fileName, id))
+	return nil
+}
+
+func deltaChainLength(info revisionInfo, id int64) int {
+	var n int
+	for delta, ok := info.Deltas[id]; ok && n <= len(info.Deltas); delta, ok = info.Deltas[id] {
+		n++
+		id = delta.BaseID
+	}
+	return n
+}
+
+func deltaFileName(fileName string, id int64) string {
+	return fmt.Sprintf("%s.%d%s", fileName, id, deltaSuffix)
+}
+
+func readAll(ctx context.Context, client workspaceClient, fileName string) ([]byte, error) {
+	f, err := client.OpenFile(ctx, fileName, OpenOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return io.ReadAll(f)
+}
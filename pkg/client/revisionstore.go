@@ -0,0 +1,72 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+)
+
+// revisionStore returns the client that stores the revisions of a workspace. The workspace is identified by its
+// provider, the bucket or container it lives in (empty for the directory provider), and its directory.
+type revisionStore func(provider, scope, dir string) workspaceClient
+
+// newRevisionStore creates a revision store at the given location, which has the same form as a workspace ID, such as
+// "directory:///var/lib/revisions", "s3://bucket/prefix" or "azure://container/prefix". Revisions of each workspace are
+// kept under the location in a directory named after the workspace's provider, bucket or container, and directory.
+func newRevisionStore(ctx context.Context, location string, opt Options) (revisionStore, error) {
+	provider, base, ok := strings.Cut(location, "://")
+	if !ok || base == "" {
+		return nil, fmt.Errorf("invalid revisions store: %s", location)
+	}
+
+	switch provider {
+	case DirectoryProvider:
+		if !filepath.IsAbs(base) {
+			return nil, fmt.Errorf("invalid revisions store: directory must be absolute: %s", location)
+		}
+
+		return func(provider, scope, dir string) workspaceClient {
+			return &directoryProvider{
+				dataHome: filepath.Join(base, provider, scope, dir),
+			}
+		}, nil
+	case S3Provider:
+		bucket, prefix, _ := strings.Cut(base, "/")
+		client, err := newS3Client(ctx, opt.S3BaseEndpoint, opt.S3UsePathStyle)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(provider, scope, dir string) workspaceClient {
+			return &s3Provider{
+				bucket: bucket,
+				dir:    path.Join(prefix, provider, scope, dir),
+				client: client,
+			}
+		}, nil
+	case AzureProvider:
+		if opt.AzureConnectionString == "" {
+			return nil, fmt.Errorf("invalid revisions store: azure connection string is required: %s", location)
+		}
+
+		containerName, prefix, _ := strings.Cut(base, "/")
+		client, err := azblob.NewClientFromConnectionString(opt.AzureConnectionString, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(provider, scope, dir string) workspaceClient {
+			return &azureProvider{
+				containerName: containerName,
+				dir:           path.Join(prefix, provider, scope, dir),
+				client:        client,
+			}
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid revisions store provider: %s", provider)
+	}
+}
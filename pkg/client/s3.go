@@ -6,60 +6,92 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/url"
+	"os"
 	"path/filepath"
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
 	"github.com/google/uuid"
 
 	"github.com/gabriel-vasile/mimetype"
 )
 
-func newS3(ctx context.Context, bucket string, baseEndpoint string, usePathStyle bool) (workspaceFactory, error) {
+const (
+	// maxCopyObjectSize is the size of the largest object that CopyObject can copy in a single request.
+	maxCopyObjectSize = 5 << 30
+	// copyPartSize is the smallest part that larger objects are copied in.
+	copyPartSize = 512 << 20
+	// maxUploadParts is the largest number of parts in a multipart upload.
+	maxUploadParts = 10000
+)
+
+func newS3(ctx context.Context, bucket string, baseEndpoint string, usePathStyle bool, store revisionStore) (workspaceFactory, error) {
+	client, err := newS3Client(ctx, baseEndpoint, usePathStyle)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3Provider{
+		bucket:        bucket,
+		client:        client,
+		revisionStore: store,
+	}, nil
+}
+
+func newS3Client(ctx context.Context, baseEndpoint string, usePathStyle bool) (*s3.Client, error) {
 	cfg, err := config.LoadDefaultConfig(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+	return s3.NewFromConfig(cfg, func(o *s3.Options) {
 		if baseEndpoint != "" {
 			o.BaseEndpoint = aws.String(baseEndpoint)
 		}
 		o.UsePathStyle = usePathStyle // often required e.g. for MinIO which requires extra configuration for virtual hosted-style requests
-	})
-	return &s3Provider{
-		bucket: bucket,
-		client: client,
-	}, nil
+	}), nil
 }
 
 type s3Provider struct {
 	bucket, dir       string
 	client            *s3.Client
 	revisionsProvider workspaceClient
+	revisionStore     revisionStore
+}
+
+// revisionClient returns the client for the revisions of the workspace in dir of bucket. Unless a separate revision
+// store is configured, revisions are kept in the revisions directory of the same bucket.
+func (s *s3Provider) revisionClient(bucket, dir string) workspaceClient {
+	if s.revisionStore != nil {
+		return s.revisionStore(S3Provider, bucket, dir)
+	}
+	return &s3Provider{
+		bucket: bucket,
+		dir:    fmt.Sprintf("%s/%s", revisionsDir, dir),
+		client: s.client,
+	}
 }
 
 func (s *s3Provider) New(id string) (workspaceClient, error) {
 	bucket, dir, _ := strings.Cut(strings.TrimPrefix(id, S3Provider+"://"), "/")
-	if dir == revisionsDir {
+	if s.revisionStore == nil && dir == revisionsDir {
 		return nil, errors.New("cannot create a workspace client for the revisions directory")
 	}
 
 	return &s3Provider{
-		bucket: bucket,
-		dir:    dir,
-		client: s.client,
-		revisionsProvider: &s3Provider{
-			bucket: bucket,
-			dir:    fmt.Sprintf("%s/%s", revisionsDir, dir),
-			client: s.client,
-		},
+		bucket:            bucket,
+		dir:               dir,
+		client:            s.client,
+		revisionsProvider: s.revisionClient(bucket, dir),
 	}, nil
 }
 
@@ -71,22 +103,100 @@ func (s *s3Provider) Rm(ctx context.Context, id string) error {
 	bucket, dir, _ := strings.Cut(strings.TrimPrefix(id, S3Provider+"://"), "/")
 
 	newS := &s3Provider{
-		bucket: bucket,
-		dir:    dir,
-		client: s.client,
-		revisionsProvider: &s3Provider{
-			bucket: bucket,
-			dir:    fmt.Sprintf("%s/%s", revisionsDir, dir),
-			client: s.client,
-		},
+		bucket:            bucket,
+		dir:               dir,
+		client:            s.client,
+		revisionsProvider: s.revisionClient(bucket, dir),
 	}
 
-	// Best effort
-	_ = newS.revisionsProvider.RemoveAllWithPrefix(ctx, s.dir)
+	// The revisions are removed first so that removing the workspace can be retried if they can't be.
+	if err := newS.revisionsProvider.RemoveAllWithPrefix(ctx, ""); err != nil {
+		return fmt.Errorf("failed to remove revisions: %w", err)
+	}
 
 	return newS.RemoveAllWithPrefix(ctx, "")
 }
 
+// List returns the IDs of the workspaces in the bucket, sorted. The workspaces and the manifests are listed from after
+// the given workspace, until enough of each have been listed.
+func (s *s3Provider) List(ctx context.Context, after string, limit int) ([]string, error) {
+	_, after, _ = strings.Cut(strings.TrimPrefix(after, S3Provider+"://"), "/")
+
+	input := &s3.ListObjectsV2Input{
+		Bucket:    aws.String(s.bucket),
+		Delimiter: aws.String("/"),
+	}
+	if after != "" {
+		// "0" follows "/", so the objects of the workspace itself are skipped.
+		input.StartAfter = aws.String(after + "0")
+	}
+
+	var dirs []string
+	for {
+		contents, err := s.client.ListObjectsV2(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, commonPrefix := range contents.CommonPrefixes {
+			if dir := strings.TrimSuffix(aws.ToString(commonPrefix.Prefix), "/"); isManifestName(dir) {
+				dirs = append(dirs, dir)
+			}
+		}
+
+		if contents.IsTruncated == nil || !*contents.IsTruncated || limit > 0 && len(dirs) >= limit {
+			break
+		}
+		input.ContinuationToken = contents.NextContinuationToken
+	}
+
+	input = &s3.ListObjectsV2Input{
+		Bucket:    aws.String(s.bucket),
+		Prefix:    aws.String(manifestsDir + "/"),
+		Delimiter: aws.String("/"),
+	}
+	if after != "" {
+		input.StartAfter = aws.String(fmt.Sprintf("%s/%s.json", manifestsDir, after))
+	}
+
+	var manifests []string
+	for {
+		contents, err := s.client.ListObjectsV2(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range contents.Contents {
+			if dir, ok := strings.CutSuffix(strings.TrimPrefix(aws.ToString(object.Key), manifestsDir+"/"), ".json"); ok {
+				manifests = append(manifests, dir)
+			}
+		}
+
+		if contents.IsTruncated == nil || !*contents.IsTruncated || limit > 0 && len(manifests) >= limit {
+			break
+		}
+		input.ContinuationToken = contents.NextContinuationToken
+	}
+
+	return workspaceIDs(dirs, manifests, after, limit, func(dir string) string {
+		return fmt.Sprintf("%s://%s/%s", S3Provider, s.bucket, dir)
+	}), nil
+}
+
+// ManifestClient returns the client for the manifests directory of the workspace's bucket.
+func (s *s3Provider) ManifestClient(id string) (workspaceClient, string, error) {
+	bucket, dir, _ := strings.Cut(strings.TrimPrefix(id, S3Provider+"://"), "/")
+	if first, _, _ := strings.Cut(dir, "/"); bucket == "" || !isManifestName(first) {
+		return nil, "", newInvalidArgumentError("invalid workspace id: %s", id)
+	}
+	return &s3Provider{bucket: bucket, dir: manifestsDir, client: s.client}, manifestKey(dir) + ".json", nil
+}
+
+// NamesClient returns the client for the names directory next to the manifests in the bucket.
+func (s *s3Provider) NamesClient() workspaceClient {
+	return &s3Provider{bucket: s.bucket, dir: manifestsDir + "/" + namesDir, client: s.client}
+}
+
 func (s *s3Provider) RevisionClient() workspaceClient {
 	return s.revisionsProvider
 }
@@ -118,6 +228,9 @@ func (s *s3Provider) Ls(ctx context.Context, prefix string) ([]string, error) {
 
 		files = slices.Grow(files, len(contents.Contents))
 		for _, content := range contents.Contents {
+			if isDirMarker(*content.Key) {
+				continue
+			}
 			files = append(files, strings.TrimPrefix(*content.Key, s.dir+"/"))
 		}
 
@@ -125,7 +238,7 @@ func (s *s3Provider) Ls(ctx context.Context, prefix string) ([]string, error) {
 			return files, nil
 		}
 
-		continuation = contents.ContinuationToken
+		continuation = contents.NextContinuationToken
 	}
 }
 
@@ -150,10 +263,7 @@ func (s *s3Provider) DeleteFile(ctx context.Context, filePath string) error {
 		return err
 	}
 
-	for i := info.CurrentID; i > 0; i-- {
-		// Best effort
-		_ = deleteRevision(ctx, s.revisionsProvider, filePath, fmt.Sprintf("%d", i))
-	}
+	deleteRevisions(ctx, s.revisionsProvider, filePath, info)
 
 	// Best effort
 	_ = deleteRevisionInfo(ctx, s.revisionsProvider, filePath)
@@ -162,16 +272,53 @@ func (s *s3Provider) DeleteFile(ctx context.Context, filePath string) error {
 }
 
 func (s *s3Provider) OpenFile(ctx context.Context, filePath string, opt OpenOptions) (*File, error) {
-	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+	input := &s3.GetObjectInput{
 		Bucket: aws.String(s.bucket),
 		Key:    aws.String(fmt.Sprintf("%s/%s", s.dir, filePath)),
-	})
+	}
+	if opt.hasRange() {
+		input.Range = aws.String(httpRange(opt.Offset, opt.Length))
+	}
+
+	var (
+		body      io.ReadCloser
+		size      int64
+		checksum  string
+		etag      string
+		metadata  map[string]string
+		expiresAt time.Time
+	)
+	out, err := s.client.GetObject(ctx, input)
 	if err != nil {
 		var respErr *http.ResponseError
-		if errors.As(err, &respErr) && respErr.Response.StatusCode == 404 {
+		if !errors.As(err, &respErr) {
+			return nil, err
+		}
+
+		switch respErr.Response.StatusCode {
+		case 404:
 			return nil, newNotFoundError(fmt.Sprintf("%s://%s/%s", S3Provider, s.bucket, s.dir), filePath)
+		case 416:
+			// The range starts beyond the end of the object.
+			head, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+				Bucket: input.Bucket,
+				Key:    input.Key,
+			})
+			if err != nil {
+				return nil, err
+			}
+			body, size = io.NopCloser(strings.NewReader("")), aws.ToInt64(head.ContentLength)
+			expiresAt = metadataExpiresAt(head.Metadata)
+		default:
+			return nil, err
+		}
+	} else {
+		body, checksum, metadata = out.Body, metadataChecksum(out.Metadata), userMetadata(out.Metadata)
+		etag = aws.ToString(out.ETag)
+		expiresAt = metadataExpiresAt(out.Metadata)
+		if opt.hasRange() {
+			size = contentRangeSize(out.ContentRange)
 		}
-		return nil, err
 	}
 
 	var revision string
@@ -184,46 +331,20 @@ func (s *s3Provider) OpenFile(ctx context.Context, filePath string, opt OpenOpti
 	}
 
 	return &File{
-		ReadCloser: out.Body,
+		ReadCloser: body,
 		RevisionID: revision,
+		Size:       size,
+		Checksum:   checksum,
+		Metadata:   metadata,
+		ExpiresAt:  expiresAt,
+		etag:       etag,
 	}, nil
 }
 
 func (s *s3Provider) WriteFile(ctx context.Context, fileName string, reader io.Reader, opt WriteOptions) error {
-	if s.revisionsProvider != nil && (opt.CreateRevision == nil || *opt.CreateRevision) {
-		info, err := getRevisionInfo(ctx, s.revisionsProvider, fileName)
-		if err != nil {
-			if nfe := (*NotFoundError)(nil); !errors.As(err, &nfe) {
-				return err
-			}
-		}
-
-		if opt.LatestRevisionID != "" {
-			requiredLatestRevision, err := strconv.ParseInt(opt.LatestRevisionID, 10, 64)
-			if err != nil {
-				return fmt.Errorf("failed to parse latest revision for write: %w", err)
-			}
-
-			if requiredLatestRevision != info.CurrentID {
-				return newConflictError(S3Provider+"://"+s.bucket, fileName, opt.LatestRevisionID, fmt.Sprintf("%d", info.CurrentID))
-			}
-		}
-
-		info.CurrentID++
-		if err = writeRevision(ctx, s.revisionsProvider, s, fileName, info); err != nil {
-			if nfe := (*NotFoundError)(nil); !errors.As(err, &nfe) {
-				return fmt.Errorf("failed to write revision: %w", err)
-			}
-		}
-
-		if err = writeRevisionInfo(ctx, s.revisionsProvider, fileName, info); err != nil {
-			return fmt.Errorf("failed to write revision info: %w", err)
-		}
-	}
-
 	var contentLength int64
 	switch r := reader.(type) {
-	case io.Seeker:
+	case io.ReadSeeker:
 		var err error
 		contentLength, err = r.Seek(0, io.SeekEnd)
 		if err != nil {
@@ -243,48 +364,243 @@ func (s *s3Provider) WriteFile(ctx context.Context, fileName string, reader io.R
 		reader = bytes.NewReader(b)
 	}
 
-	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+	sum, err := checksumOf(reader.(io.ReadSeeker))
+	if err != nil {
+		return err
+	}
+	if err = verifyChecksum(fmt.Sprintf("%s://%s/%s", S3Provider, s.bucket, s.dir), fileName, opt.Checksum, sum); err != nil {
+		return err
+	}
+
+	contentType, err := seekContentType(reader.(io.ReadSeeker), opt.ContentType)
+	if err != nil {
+		return err
+	}
+
+	if s.revisionsProvider != nil {
+		if err := recordWrite(ctx, s.revisionsProvider, s, S3Provider+"://"+s.bucket, fileName, opt); err != nil {
+			return err
+		}
+	}
+
+	input := &s3.PutObjectInput{
 		Bucket:        aws.String(s.bucket),
 		Key:           aws.String(fmt.Sprintf("%s/%s", s.dir, fileName)),
 		ContentLength: aws.Int64(contentLength),
 		Body:          reader,
+		ContentType:   aws.String(contentType),
+		Metadata:      objectMetadata(opt.Metadata, sum, opt.ExpiresAt),
+	}
+	if opt.exclusive {
+		input.IfNoneMatch = aws.String("*")
+	}
+	var optFns []func(*s3.Options)
+	if opt.ifMatch != "" {
+		// This version of the SDK has no field for conditional writes on the entity tag, so the header is set directly.
+		optFns = append(optFns, s3.WithAPIOptions(smithyhttp.SetHeaderValue("If-Match", opt.ifMatch)))
+	}
+
+	_, err = s.client.PutObject(ctx, input, optFns...)
+	var respErr *http.ResponseError
+	if err != nil && errors.As(err, &respErr) && (respErr.Response.StatusCode == 412 || respErr.Response.StatusCode == 409) {
+		if opt.exclusive {
+			return &FileExistsError{id: S3Provider + "://" + s.bucket, name: fileName}
+		}
+		if opt.ifMatch != "" {
+			// The file was changed since it was read.
+			var current string
+			if s.revisionsProvider != nil {
+				if info, err := getRevisionInfo(ctx, s.revisionsProvider, fileName); err == nil {
+					current = strconv.FormatInt(info.CurrentID, 10)
+				}
+			}
+			return newConflictError(S3Provider+"://"+s.bucket, fileName, opt.LatestRevisionID, current)
+		}
+	}
+
+	return err
+}
+
+func (s *s3Provider) CopyFile(ctx context.Context, fileName string, dest workspaceClient, destFileName string, opt WriteOptions) error {
+	destS3, ok := dest.(*s3Provider)
+	if !ok {
+		return streamFile(ctx, s, fileName, dest, destFileName, opt)
+	}
+
+	return copyFile(ctx, s, fileName, dest, S3Provider+"://"+destS3.bucket, destFileName, opt, func(ctx context.Context) error {
+		out, err := s.headObject(ctx, fileName)
+		if err != nil {
+			return err
+		}
+		return destS3.copyObject(ctx, s, fileName, out, destFileName, nil, nil)
 	})
+}
+
+// copySource returns the URL encoded source of a CopyObject request for the file.
+func (s *s3Provider) copySource(fileName string) string {
+	return (&url.URL{Path: fmt.Sprintf("%s/%s/%s", s.bucket, s.dir, fileName)}).EscapedPath()
+}
 
+// copyObject copies a file of source, whose properties are src, to destFileName. If a content type is given, then it
+// and the metadata replace the source's. Objects that are too large for CopyObject are copied in parts.
+func (s *s3Provider) copyObject(ctx context.Context, source *s3Provider, fileName string, src *s3.HeadObjectOutput, destFileName string, contentType *string, metadata map[string]string) error {
+	if aws.ToInt64(src.ContentLength) > maxCopyObjectSize {
+		if contentType == nil {
+			// Parts don't carry the properties of the source, so they are set on the upload.
+			contentType, metadata = src.ContentType, src.Metadata
+		}
+		return s.copyObjectInParts(ctx, source, fileName, src, destFileName, contentType, metadata)
+	}
+
+	input := &s3.CopyObjectInput{
+		Bucket:            aws.String(s.bucket),
+		CopySource:        aws.String(source.copySource(fileName)),
+		CopySourceIfMatch: src.ETag,
+		Key:               aws.String(fmt.Sprintf("%s/%s", s.dir, destFileName)),
+	}
+	if contentType != nil {
+		input.ContentType, input.Metadata, input.MetadataDirective = contentType, metadata, types.MetadataDirectiveReplace
+	}
+	_, err := s.client.CopyObject(ctx, input)
 	return err
 }
 
-func (s *s3Provider) StatFile(ctx context.Context, fileName string, opt StatOptions) (FileInfo, error) {
-	out, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
-		Bucket: aws.String(s.bucket),
-		Key:    aws.String(fmt.Sprintf("%s/%s", s.dir, fileName)),
+// copyObjectInParts copies an object with a multipart upload, copying ranges of the source that are no larger than
+// CopyObject allows. Every part is copied from the version of the source that src describes.
+func (s *s3Provider) copyObjectInParts(ctx context.Context, source *s3Provider, fileName string, src *s3.HeadObjectOutput, destFileName string, contentType *string, metadata map[string]string) (err error) {
+	key := aws.String(fmt.Sprintf("%s/%s", s.dir, destFileName))
+	upload, err := s.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         key,
+		ContentType: contentType,
+		Metadata:    metadata,
 	})
 	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			// Best effort, the parts that were copied are otherwise kept until the upload is aborted.
+			_, _ = s.client.AbortMultipartUpload(context.Background(), &s3.AbortMultipartUploadInput{
+				Bucket:   aws.String(s.bucket),
+				Key:      key,
+				UploadId: upload.UploadId,
+			})
+		}
+	}()
+
+	size := aws.ToInt64(src.ContentLength)
+	partSize := max(copyPartSize, (size+maxUploadParts-1)/maxUploadParts)
+
+	var parts []types.CompletedPart
+	for start, number := int64(0), int32(1); start < size; start, number = start+partSize, number+1 {
+		out, err := s.client.UploadPartCopy(ctx, &s3.UploadPartCopyInput{
+			Bucket:            aws.String(s.bucket),
+			Key:               key,
+			UploadId:          upload.UploadId,
+			PartNumber:        aws.Int32(number),
+			CopySource:        aws.String(source.copySource(fileName)),
+			CopySourceIfMatch: src.ETag,
+			CopySourceRange:   aws.String(fmt.Sprintf("bytes=%d-%d", start, min(start+partSize, size)-1)),
+		})
+		if err != nil {
+			return err
+		}
+		parts = append(parts, types.CompletedPart{ETag: out.CopyPartResult.ETag, PartNumber: aws.Int32(number)})
+	}
+
+	_, err = s.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(s.bucket),
+		Key:             key,
+		UploadId:        upload.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	return err
+}
+
+func (s *s3Provider) MoveFile(ctx context.Context, from, to string, opt MoveOptions) error {
+	return moveFile(ctx, s, S3Provider+"://"+s.bucket, from, to, opt, s.renameFile)
+}
+
+// renameFile copies the object to its new key within the bucket and then deletes the original.
+func (s *s3Provider) renameFile(ctx context.Context, from, to string) error {
+	out, err := s.headObject(ctx, from)
+	if err != nil {
+		return err
+	}
+	if err = s.copyObject(ctx, s, from, out, to, nil, nil); err != nil {
 		var respErr *http.ResponseError
 		if errors.As(err, &respErr) && respErr.Response.StatusCode == 404 {
-			return FileInfo{}, newNotFoundError(fmt.Sprintf("%s://%s/%s", S3Provider, s.bucket, s.dir), fileName)
+			return newNotFoundError(fmt.Sprintf("%s://%s/%s", S3Provider, s.bucket, s.dir), from)
 		}
-		return FileInfo{}, err
+		return err
 	}
 
-	var mime string
-	if out.ContentType != nil {
-		mime = *out.ContentType
+	_, err = s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(fmt.Sprintf("%s/%s", s.dir, from)),
+	})
+	return err
+}
+
+// AppendFile rewrites the object with the appended content, since S3 objects cannot be appended to. The existing
+// content is streamed into a temporary file along with the appended content, and the object is only replaced if it
+// hasn't changed since it was read, so a concurrent append returns a ConflictError instead of being lost.
+func (s *s3Provider) AppendFile(ctx context.Context, fileName string, reader io.Reader, opt WriteOptions) error {
+	createRevision := s.revisionsProvider != nil && (opt.CreateRevision == nil || *opt.CreateRevision)
+
+	f, err := s.OpenFile(ctx, fileName, OpenOptions{WithLatestRevisionID: createRevision && opt.LatestRevisionID == ""})
+	if err != nil {
+		if nfe := (*NotFoundError)(nil); !errors.As(err, &nfe) {
+			return err
+		}
+		return s.WriteFile(ctx, fileName, reader, opt)
 	}
+	defer f.Close()
 
-	// get the first 3072 bytes of the file to detect the mimetype, as the S3 ContentType is not reliable if not set explicitly
-	fileStart, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+	if opt.LatestRevisionID == "" {
+		opt.LatestRevisionID = f.RevisionID
+	}
+	// Appending keeps the metadata and expiry of the file.
+	opt.Metadata, opt.ExpiresAt, opt.ifMatch = f.Metadata, f.ExpiresAt, f.etag
+
+	tmp, err := os.CreateTemp("", "workspace-provider-append-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err = io.Copy(tmp, io.MultiReader(f, reader)); err != nil {
+		return err
+	}
+
+	return s.WriteFile(ctx, fileName, tmp, opt)
+}
+
+func (s *s3Provider) StatFile(ctx context.Context, fileName string, opt StatOptions) (FileInfo, error) {
+	out, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
 		Bucket: aws.String(s.bucket),
 		Key:    aws.String(fmt.Sprintf("%s/%s", s.dir, fileName)),
-		Range:  aws.String("bytes=0-3072"), // 3072 is the default read limit of the mimetype package
 	})
 	if err != nil {
+		var respErr *http.ResponseError
+		if errors.As(err, &respErr) && respErr.Response.StatusCode == 404 {
+			if opt.withDirs {
+				return s.statDir(ctx, fileName)
+			}
+			return FileInfo{}, newNotFoundError(fmt.Sprintf("%s://%s/%s", S3Provider, s.bucket, s.dir), fileName)
+		}
 		return FileInfo{}, err
 	}
-	defer fileStart.Body.Close()
 
-	mt, err := mimetype.DetectReader(fileStart.Body)
-	if err == nil {
-		mime = strings.Split(mt.String(), ";")[0]
+	checksum := metadataChecksum(out.Metadata)
+	mime := storedContentType(out.ContentType, checksum)
+	if mime == "" {
+		// Get the first 3072 bytes of the file to detect the mimetype, as the S3 ContentType is not reliable if not set explicitly
+		if mime, err = s.sniffContentType(ctx, fileName); err != nil {
+			return FileInfo{}, err
+		}
 	}
 
 	var revision string
@@ -303,9 +619,180 @@ func (s *s3Provider) StatFile(ctx context.Context, fileName string, opt StatOpti
 		ModTime:     aws.ToTime(out.LastModified),
 		MimeType:    mime,
 		RevisionID:  revision,
+		Checksum:    checksum,
+		Metadata:    userMetadata(out.Metadata),
+		ExpiresAt:   metadataExpiresAt(out.Metadata),
 	}, nil
 }
 
+// SetMetadata replaces the object with a copy of itself that has the metadata, keeping its checksum, content type and
+// expiry. Objects that are too large to be copied in a single request are copied in parts.
+// This updates its modification time.
+func (s *s3Provider) SetMetadata(ctx context.Context, fileName string, metadata map[string]string) error {
+	out, err := s.headObject(ctx, fileName)
+	if err != nil {
+		return err
+	}
+
+	return s.copyObject(ctx, s, fileName, out, fileName, aws.String(aws.ToString(out.ContentType)), objectMetadata(metadata, metadataChecksum(out.Metadata), metadataExpiresAt(out.Metadata)))
+}
+
+// fetchMetadata returns the metadata of a file, which isn't included when listing objects.
+func (s *s3Provider) fetchMetadata(ctx context.Context, fileName string) (map[string]string, error) {
+	out, err := s.headObject(ctx, fileName)
+	if err != nil {
+		return nil, err
+	}
+	return userMetadata(out.Metadata), nil
+}
+
+// headObject returns the object's properties, or a NotFoundError if it doesn't exist.
+func (s *s3Provider) headObject(ctx context.Context, fileName string) (*s3.HeadObjectOutput, error) {
+	out, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(fmt.Sprintf("%s/%s", s.dir, fileName)),
+	})
+	if err != nil {
+		var respErr *http.ResponseError
+		if errors.As(err, &respErr) && respErr.Response.StatusCode == 404 {
+			return nil, newNotFoundError(fmt.Sprintf("%s://%s/%s", S3Provider, s.bucket, s.dir), fileName)
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+// BackfillContentType replaces the object with a copy of itself that has its detected content type, keeping its
+// metadata. This updates its modification time.
+func (s *s3Provider) BackfillContentType(ctx context.Context, fileName string) (bool, error) {
+	out, err := s.headObject(ctx, fileName)
+	if err != nil {
+		return false, err
+	}
+
+	if storedContentType(out.ContentType, metadataChecksum(out.Metadata)) != "" {
+		return false, nil
+	}
+	if aws.ToInt64(out.ContentLength) > maxCopyObjectSize {
+		// The object is too large to be copied in a single request, so its content type is detected whenever it is
+		// statted instead.
+		return false, nil
+	}
+
+	contentType, err := s.sniffContentType(ctx, fileName)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = s.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:            aws.String(s.bucket),
+		CopySource:        aws.String(s.copySource(fileName)),
+		Key:               aws.String(fmt.Sprintf("%s/%s", s.dir, fileName)),
+		ContentType:       aws.String(contentType),
+		Metadata:          out.Metadata,
+		MetadataDirective: types.MetadataDirectiveReplace,
+	})
+	return err == nil, err
+}
+
+// sniffContentType detects the content type of a file from its first bytes.
+func (s *s3Provider) sniffContentType(ctx context.Context, fileName string) (string, error) {
+	fileStart, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(fmt.Sprintf("%s/%s", s.dir, fileName)),
+		Range:  aws.String(httpRange(0, sniffLength)),
+	})
+	if err != nil {
+		var respErr *http.ResponseError
+		if errors.As(err, &respErr) && respErr.Response.StatusCode == 416 {
+			// The file is empty.
+			return detectContentType(nil), nil
+		}
+		return "", err
+	}
+	defer fileStart.Body.Close()
+
+	mt, err := mimetype.DetectReader(fileStart.Body)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Split(mt.String(), ";")[0], nil
+}
+
+// statDir returns the information about a directory, which exists if it has a marker or any objects beneath it. Its
+// modification time is that of its marker, if it has one.
+func (s *s3Provider) statDir(ctx context.Context, dirName string) (FileInfo, error) {
+	contents, err := s.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:  aws.String(s.bucket),
+		Prefix:  aws.String(s.dirMarkerKey(dirName)),
+		MaxKeys: aws.Int32(1),
+	})
+	if err != nil {
+		return FileInfo{}, err
+	}
+	if len(contents.Contents) == 0 {
+		return FileInfo{}, newNotFoundError(fmt.Sprintf("%s://%s/%s", S3Provider, s.bucket, s.dir), dirName)
+	}
+
+	info := FileInfo{
+		WorkspaceID: fmt.Sprintf("%s://%s/%s", S3Provider, s.bucket, s.dir),
+		Name:        dirName + "/",
+		IsDir:       true,
+	}
+	// The marker sorts before every other object beneath the directory.
+	if aws.ToString(contents.Contents[0].Key) == s.dirMarkerKey(dirName) {
+		info.ModTime = aws.ToTime(contents.Contents[0].LastModified)
+	}
+	return info, nil
+}
+
+func (s *s3Provider) dirMarkerKey(dirName string) string {
+	return fmt.Sprintf("%s/%s/", s.dir, dirName)
+}
+
+// Mkdir writes the marker of the directory. Its parents exist as long as it does.
+func (s *s3Provider) Mkdir(ctx context.Context, dirName string) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(s.dirMarkerKey(dirName)),
+		ContentLength: aws.Int64(0),
+		Body:          bytes.NewReader(nil),
+	})
+	return err
+}
+
+func (s *s3Provider) RemoveDir(ctx context.Context, dirName string, recursive bool) error {
+	// Listing two objects is enough to tell whether there is anything other than the marker.
+	contents, err := s.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:  aws.String(s.bucket),
+		Prefix:  aws.String(s.dirMarkerKey(dirName)),
+		MaxKeys: aws.Int32(2),
+	})
+	if err != nil {
+		return err
+	}
+	if len(contents.Contents) == 0 {
+		return newNotFoundError(fmt.Sprintf("%s://%s/%s", S3Provider, s.bucket, s.dir), dirName)
+	}
+
+	if recursive {
+		return s.RemoveAllWithPrefix(ctx, dirName)
+	}
+
+	for _, content := range contents.Contents {
+		if aws.ToString(content.Key) != s.dirMarkerKey(dirName) {
+			return newDirectoryNotEmptyError(fmt.Sprintf("%s://%s/%s", S3Provider, s.bucket, s.dir), dirName)
+		}
+	}
+
+	_, err = s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.dirMarkerKey(dirName)),
+	})
+	return err
+}
+
 func (s *s3Provider) RemoveAllWithPrefix(ctx context.Context, prefix string) error {
 	if prefix != "" {
 		prefix = fmt.Sprintf("%s/%s/", s.dir, strings.TrimSuffix(prefix, "/"))
@@ -336,7 +823,7 @@ func (s *s3Provider) RemoveAllWithPrefix(ctx context.Context, prefix string) err
 			})
 		}
 
-		_, err = s.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+		out, err := s.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
 			Bucket: aws.String(s.bucket),
 			Delete: &types.Delete{
 				Objects: deleteObjects,
@@ -345,12 +832,157 @@ func (s *s3Provider) RemoveAllWithPrefix(ctx context.Context, prefix string) err
 		if err != nil {
 			return err
 		}
+		// The objects that couldn't be deleted are reported in the response rather than as an error.
+		if len(out.Errors) > 0 {
+			errs := make([]error, 0, len(out.Errors))
+			for _, e := range out.Errors {
+				errs = append(errs, fmt.Errorf("failed to delete %s: %s", aws.ToString(e.Key), aws.ToString(e.Message)))
+			}
+			return errors.Join(errs...)
+		}
 
 		if contents.IsTruncated == nil || !*contents.IsTruncated {
 			return nil
 		}
 
-		continuation = contents.ContinuationToken
+		continuation = contents.NextContinuationToken
+	}
+}
+
+func (s *s3Provider) LsWithInfo(ctx context.Context, prefix string) ([]FileInfo, error) {
+	if prefix != "" {
+		prefix = fmt.Sprintf("%s/", strings.TrimSuffix(prefix, "/"))
+	}
+
+	return s.StatWithPrefix(ctx, prefix)
+}
+
+func (s *s3Provider) LsPage(ctx context.Context, prefix string, limit int, continuation string) ([]FileInfo, string, error) {
+	if prefix != "" {
+		prefix = fmt.Sprintf("%s/%s/", s.dir, strings.TrimSuffix(prefix, "/"))
+	} else {
+		prefix = fmt.Sprintf("%s/", s.dir)
+	}
+
+	input := &s3.ListObjectsV2Input{
+		Bucket:  aws.String(s.bucket),
+		Prefix:  aws.String(prefix),
+		MaxKeys: aws.Int32(int32(limit)),
+	}
+	if continuation != "" {
+		input.ContinuationToken = aws.String(continuation)
+	}
+
+	contents, err := s.client.ListObjectsV2(ctx, input)
+	if err != nil {
+		return nil, "", err
+	}
+
+	files := make([]FileInfo, 0, len(contents.Contents))
+	for _, content := range contents.Contents {
+		if isDirMarker(aws.ToString(content.Key)) {
+			continue
+		}
+		files = append(files, FileInfo{
+			WorkspaceID: fmt.Sprintf("%s://%s/%s", S3Provider, s.bucket, s.dir),
+			Name:        strings.TrimPrefix(aws.ToString(content.Key), s.dir+"/"),
+			Size:        aws.ToInt64(content.Size),
+			ModTime:     aws.ToTime(content.LastModified),
+		})
+	}
+
+	if contents.IsTruncated == nil || !*contents.IsTruncated {
+		return files, "", nil
+	}
+
+	return files, aws.ToString(contents.NextContinuationToken), nil
+}
+
+func (s *s3Provider) LsDir(ctx context.Context, prefix string) ([]FileInfo, error) {
+	if prefix != "" {
+		prefix = fmt.Sprintf("%s/%s/", s.dir, strings.TrimSuffix(prefix, "/"))
+	} else {
+		prefix = fmt.Sprintf("%s/", s.dir)
+	}
+
+	var (
+		continuation *string
+		files        []FileInfo
+	)
+	for {
+		contents, err := s.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+			Bucket:            aws.String(s.bucket),
+			Prefix:            aws.String(prefix),
+			Delimiter:         aws.String("/"),
+			ContinuationToken: continuation,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		files = slices.Grow(files, len(contents.Contents)+len(contents.CommonPrefixes))
+		for _, commonPrefix := range contents.CommonPrefixes {
+			files = append(files, FileInfo{
+				WorkspaceID: fmt.Sprintf("%s://%s/%s", S3Provider, s.bucket, s.dir),
+				Name:        strings.TrimPrefix(aws.ToString(commonPrefix.Prefix), s.dir+"/"),
+				IsDir:       true,
+			})
+		}
+		for _, content := range contents.Contents {
+			if isDirMarker(aws.ToString(content.Key)) {
+				continue
+			}
+			files = append(files, FileInfo{
+				WorkspaceID: fmt.Sprintf("%s://%s/%s", S3Provider, s.bucket, s.dir),
+				Name:        strings.TrimPrefix(aws.ToString(content.Key), s.dir+"/"),
+				Size:        aws.ToInt64(content.Size),
+				ModTime:     aws.ToTime(content.LastModified),
+			})
+		}
+
+		if contents.IsTruncated == nil || !*contents.IsTruncated {
+			return files, nil
+		}
+
+		continuation = contents.NextContinuationToken
+	}
+}
+
+func (s *s3Provider) StatWithPrefix(ctx context.Context, prefix string) ([]FileInfo, error) {
+	prefix = fmt.Sprintf("%s/%s", s.dir, prefix)
+
+	var (
+		continuation *string
+		files        []FileInfo
+	)
+	for {
+		contents, err := s.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+			Bucket:            aws.String(s.bucket),
+			Prefix:            aws.String(prefix),
+			ContinuationToken: continuation,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		files = slices.Grow(files, len(contents.Contents))
+		for _, content := range contents.Contents {
+			if isDirMarker(aws.ToString(content.Key)) {
+				continue
+			}
+			files = append(files, FileInfo{
+				WorkspaceID: fmt.Sprintf("%s://%s/%s", S3Provider, s.bucket, s.dir),
+				Name:        strings.TrimPrefix(aws.ToString(content.Key), s.dir+"/"),
+				Size:        aws.ToInt64(content.Size),
+				ModTime:     aws.ToTime(content.LastModified),
+			})
+		}
+
+		if contents.IsTruncated == nil || !*contents.IsTruncated {
+			return files, nil
+		}
+
+		continuation = contents.NextContinuationToken
 	}
 }
 
@@ -363,5 +995,5 @@ func (s *s3Provider) GetRevision(ctx context.Context, fileName, revisionID strin
 }
 
 func (s *s3Provider) DeleteRevision(ctx context.Context, fileName, revisionID string) error {
-	return deleteRevision(ctx, s.revisionsProvider, fileName, revisionID)
+	return removeRevision(ctx, s.revisionsProvider, fileName, revisionID)
 }
@@ -0,0 +1,270 @@
+package client
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+const (
+	// defaultSearchConcurrency is the number of files that are searched at once when no concurrency is given.
+	defaultSearchConcurrency = 8
+	// maxSearchLineLength is the longest line that is searched. Files with longer lines are searched up to that line.
+	maxSearchLineLength = 1024 * 1024
+	// maxSnippetLength is the longest snippet returned for a matching line or a line of context.
+	maxSnippetLength = 512
+	// sniffLength is the number of bytes at the start of a file that its mime type is detected from.
+	sniffLength = 3072
+)
+
+type SearchOptions struct {
+	// Regex treats the query as a regular expression, in RE2 syntax, instead of literal text.
+	Regex bool
+	// IgnoreCase matches the query regardless of case.
+	IgnoreCase bool
+	// Prefix, Include and Exclude select the files to search, as when listing them.
+	Prefix           string
+	Include, Exclude []string
+	// MimeTypes, if set, only searches files whose detected mime type starts with one of these, such as "text/markdown".
+	// Files that are not text are never searched.
+	MimeTypes []string
+	// MaxFileSize, if set, skips files larger than this many bytes.
+	MaxFileSize int64
+	// ContextLines is the number of lines before and after each match to return with it.
+	ContextLines int
+	// MaxMatches, if set, stops the search after this many matches.
+	MaxMatches int
+	// Concurrency is the number of files searched at once. The default is 8.
+	Concurrency int
+}
+
+type SearchMatch struct {
+	FileName string `json:"fileName"`
+	// Line is the line number of the match, starting at 1.
+	Line int `json:"line"`
+	// Text is the matching line, truncated if it is very long.
+	Text   string   `json:"text"`
+	Before []string `json:"before,omitempty"`
+	After  []string `json:"after,omitempty"`
+}
+
+// Search finds the lines that match the query in the text files of a workspace. Files are streamed from the backend
+// rather than read into memory, and matches are returned ordered by file name and line number.
+func (c *Client) Search(ctx context.Context, id, query string, opts ...SearchOptions) ([]SearchMatch, error) {
+	opt := completeSearchOptions(opts...)
+	if query == "" {
+		return nil, fmt.Errorf("search query is required")
+	}
+	if opt.MaxFileSize < 0 || opt.ContextLines < 0 || opt.MaxMatches < 0 || opt.Concurrency < 0 {
+		return nil, fmt.Errorf("search options must not be negative")
+	}
+
+	if !opt.Regex {
+		query = regexp.QuoteMeta(query)
+	}
+	if opt.IgnoreCase {
+		query = "(?i)" + query
+	}
+	re, err := regexp.Compile(query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid search query: %w", err)
+	}
+
+	wc, id, err := c.getClient(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	var (
+		files   = make(chan string)
+		wg      sync.WaitGroup
+		lock    sync.Mutex
+		matches []SearchMatch
+		done    = errors.New("maximum number of matches found")
+	)
+	for range cmp.Or(opt.Concurrency, defaultSearchConcurrency) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for file := range files {
+				fileMatches, err := searchFile(ctx, wc, file, re, opt)
+				if err != nil {
+					cancel(err)
+					continue
+				}
+				if len(fileMatches) == 0 {
+					continue
+				}
+
+				lock.Lock()
+				matches = append(matches, fileMatches...)
+				if opt.MaxMatches != 0 && len(matches) >= opt.MaxMatches {
+					cancel(done)
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+
+	var listErr error
+	for info, err := range c.LsIter(ctx, id, opt.Prefix, LsOptions{Include: opt.Include, Exclude: opt.Exclude, MaxSize: opt.MaxFileSize}) {
+		if err != nil {
+			listErr = err
+			break
+		}
+
+		select {
+		case files <- info.Name:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(files)
+	wg.Wait()
+
+	if err = context.Cause(ctx); err != nil && !errors.Is(err, done) {
+		return nil, err
+	}
+	if listErr != nil && !errors.Is(listErr, context.Canceled) {
+		return nil, listErr
+	}
+
+	slices.SortFunc(matches, func(a, b SearchMatch) int {
+		return cmp.Or(strings.Compare(a.FileName, b.FileName), cmp.Compare(a.Line, b.Line))
+	})
+	if opt.MaxMatches != 0 && len(matches) > opt.MaxMatches {
+		matches = matches[:opt.MaxMatches]
+	}
+
+	return matches, nil
+}
+
+func completeSearchOptions(opts ...SearchOptions) SearchOptions {
+	var opt SearchOptions
+	for _, o := range opts {
+		opt.Regex = opt.Regex || o.Regex
+		opt.IgnoreCase = opt.IgnoreCase || o.IgnoreCase
+		if o.Prefix != "" {
+			opt.Prefix = o.Prefix
+		}
+		opt.Include = append(opt.Include, o.Include...)
+		opt.Exclude = append(opt.Exclude, o.Exclude...)
+		opt.MimeTypes = append(opt.MimeTypes, o.MimeTypes...)
+		if o.MaxFileSize != 0 {
+			opt.MaxFileSize = o.MaxFileSize
+		}
+		if o.ContextLines != 0 {
+			opt.ContextLines = o.ContextLines
+		}
+		if o.MaxMatches != 0 {
+			opt.MaxMatches = o.MaxMatches
+		}
+		if o.Concurrency != 0 {
+			opt.Concurrency = o.Concurrency
+		}
+	}
+
+	return opt
+}
+
+// searchFile returns the matches in a file, or nothing if the file is not text or not one of the requested mime types.
+// Files that are removed during the search are skipped.
+func searchFile(ctx context.Context, wc workspaceClient, fileName string, re *regexp.Regexp, opt SearchOptions) ([]SearchMatch, error) {
+	file, err := wc.OpenFile(ctx, fileName, OpenOptions{})
+	if err != nil {
+		if nfe := (*NotFoundError)(nil); errors.As(err, &nfe) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReaderSize(file, sniffLength)
+	head, err := reader.Peek(sniffLength)
+	if err != nil && len(head) == 0 {
+		// The file is empty, or couldn't be read, in which case the error is returned when scanning.
+		head = nil
+	}
+	if len(head) > 0 && !isText(head) {
+		return nil, nil
+	}
+	if len(opt.MimeTypes) > 0 {
+		mt := mimetype.Detect(head).String()
+		if !slices.ContainsFunc(opt.MimeTypes, func(mimeType string) bool {
+			return strings.HasPrefix(mt, mimeType)
+		}) {
+			return nil, nil
+		}
+	}
+
+	var (
+		matches []SearchMatch
+		before  []string
+		// pending are the indexes of the matches that still need lines of context after them.
+		pending []int
+	)
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSearchLineLength)
+	for line := 1; scanner.Scan(); line++ {
+		text := snippet(scanner.Text())
+
+		for _, i := range pending {
+			matches[i].After = append(matches[i].After, text)
+		}
+		pending = slices.DeleteFunc(pending, func(i int) bool {
+			return len(matches[i].After) >= opt.ContextLines
+		})
+
+		if re.Match(scanner.Bytes()) {
+			matches = append(matches, SearchMatch{
+				FileName: fileName,
+				Line:     line,
+				Text:     text,
+				Before:   slices.Clone(before),
+			})
+			if opt.ContextLines > 0 {
+				pending = append(pending, len(matches)-1)
+			}
+		}
+
+		if opt.ContextLines > 0 {
+			if len(before) == opt.ContextLines {
+				before = before[1:]
+			}
+			before = append(before, text)
+		}
+	}
+	if err = scanner.Err(); err != nil && !errors.Is(err, bufio.ErrTooLong) {
+		return nil, fmt.Errorf("failed to search %s: %w", fileName, err)
+	}
+
+	return matches, nil
+}
+
+// snippet truncates a line to the maximum snippet length, without splitting a UTF-8 character.
+func snippet(line string) string {
+	if len(line) <= maxSnippetLength {
+		return line
+	}
+
+	end := maxSnippetLength
+	for end > 0 && !utf8.RuneStart(line[end]) {
+		end--
+	}
+	return line[:end]
+}
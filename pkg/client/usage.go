@@ -0,0 +1,867 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// usageDir is the directory, next to the manifests, that the usage of workspaces with a quota is recorded in.
+const usageDir = "usage"
+
+const (
+	// usageLockTimeout is how long the lock on the usage of a workspace can be held. An older lock was left by a client
+	// that stopped while holding it, and is taken over.
+	usageLockTimeout = 30 * time.Second
+	// usageReserveSize is the least that is reserved at a time for content whose size isn't known.
+	usageReserveSize = 1 << 20
+)
+
+// Usage is the storage used by a workspace. Revision info is not counted.
+type Usage struct {
+	Files         int64 `json:"files"`
+	Bytes         int64 `json:"bytes"`
+	RevisionFiles int64 `json:"revisionFiles"`
+	RevisionBytes int64 `json:"revisionBytes"`
+}
+
+// Quota limits the storage used by a workspace. Zero values are unlimited.
+type Quota struct {
+	// MaxBytes limits the bytes stored by the workspace, including its revisions.
+	MaxBytes int64 `json:"maxBytes,omitempty"`
+	// MaxFiles limits the number of files in the workspace, not including revisions.
+	MaxFiles int64 `json:"maxFiles,omitempty"`
+	// MaxFileSize limits the size of each file.
+	MaxFileSize int64 `json:"maxFileSize,omitempty"`
+}
+
+func (q Quota) isZero() bool {
+	return q == Quota{}
+}
+
+// merge returns the quota with the limits that are set in o replacing its own.
+func (q Quota) merge(o Quota) Quota {
+	if o.MaxBytes != 0 {
+		q.MaxBytes = o.MaxBytes
+	}
+	if o.MaxFiles != 0 {
+		q.MaxFiles = o.MaxFiles
+	}
+	if o.MaxFileSize != 0 {
+		q.MaxFileSize = o.MaxFileSize
+	}
+	return q
+}
+
+func (q Quota) validate() error {
+	if q.MaxBytes < 0 || q.MaxFiles < 0 || q.MaxFileSize < 0 {
+		return newInvalidArgumentError("invalid quota: limits must not be negative")
+	}
+	return nil
+}
+
+type UsageOptions struct {
+	// Recompute lists the workspace to compute its usage instead of using the recorded counters.
+	Recompute bool
+}
+
+// usageRecord is the usage of a workspace that is kept up to date as files are written and deleted, so that quotas can
+// be enforced without listing the workspace. It is only kept for workspaces that a quota applies to.
+type usageRecord struct {
+	// Quota is the quota set on the workspace, whose limits replace those of the provider's quota.
+	Quota Quota `json:"quota,omitzero"`
+	Usage Usage `json:"usage"`
+	// Stale is set when files were removed without counting them, so that the usage is recomputed when next needed.
+	Stale bool `json:"stale,omitempty"`
+}
+
+// Usage returns the storage used by a workspace. For workspaces that a quota applies to, the recorded counters are
+// returned. With delta encoding, they count each revision at its full size until the usage is recomputed.
+func (c *Client) Usage(ctx context.Context, id string, opts ...UsageOptions) (Usage, error) {
+	var opt UsageOptions
+	for _, o := range opts {
+		opt.Recompute = opt.Recompute || o.Recompute
+	}
+
+	wc, id, err := c.getClient(ctx, id)
+	if err != nil {
+		return Usage{}, err
+	}
+
+	a, err := c.loadAccount(ctx, id, wc)
+	if err != nil {
+		return Usage{}, err
+	}
+	if a == nil {
+		return computeUsage(ctx, wc)
+	}
+	if opt.Recompute {
+		if err = a.recompute(ctx); err != nil {
+			return Usage{}, err
+		}
+	}
+
+	return a.record.Usage, nil
+}
+
+// Quota returns the quota that applies to a workspace, which combines the quota of its provider with its own.
+func (c *Client) Quota(ctx context.Context, id string) (Quota, error) {
+	factory, id, err := c.getWorkspaceFactory(ctx, id)
+	if err != nil {
+		return Quota{}, err
+	}
+
+	provider, _, _ := strings.Cut(id, "://")
+	if !c.hasQuotas(provider) {
+		return Quota{}, nil
+	}
+
+	record, _, err := readUsageRecord(ctx, factory, id)
+	if err != nil {
+		return Quota{}, err
+	}
+
+	return c.quotas[provider].merge(record.Quota), nil
+}
+
+// hasQuotas returns whether the workspaces of a provider have quotas, even if the provider's quota has no limits.
+func (c *Client) hasQuotas(provider string) bool {
+	_, ok := c.quotas[provider]
+	return ok
+}
+
+// checkQuotasEnabled returns an InvalidArgumentError if a quota is set on a workspace of a provider that doesn't have
+// quotas.
+func (c *Client) checkQuotasEnabled(provider string, quota Quota) error {
+	if !c.hasQuotas(provider) && !quota.isZero() {
+		return newInvalidArgumentError("quotas are not enabled for provider %s", provider)
+	}
+	return nil
+}
+
+// SetQuota replaces the quota set on a workspace. Its limits replace those of the provider's quota, and a zero quota
+// removes it. Lowering a quota below the current usage doesn't remove any files, but only writes that free space are
+// allowed until the usage is under the quota. The provider must have quotas, even if without limits of its own.
+func (c *Client) SetQuota(ctx context.Context, id string, quota Quota) error {
+	if err := quota.validate(); err != nil {
+		return err
+	}
+
+	wc, id, err := c.getClient(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	provider, _, _ := strings.Cut(id, "://")
+	if err = c.checkQuotasEnabled(provider, quota); err != nil {
+		return err
+	}
+	if !c.hasQuotas(provider) {
+		// There is no quota to remove.
+		return nil
+	}
+
+	factory, id, err := c.getWorkspaceFactory(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := lockUsage(ctx, factory, id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	record, found, err := readUsageRecord(ctx, factory, id)
+	if err != nil {
+		return err
+	}
+
+	if c.quotas[provider].merge(quota).isZero() {
+		if found {
+			return deleteUsageRecord(ctx, factory, id)
+		}
+		return nil
+	}
+
+	record.Quota = quota
+	if !found || record.Stale {
+		if record.Usage, err = computeUsage(ctx, wc); err != nil {
+			return err
+		}
+		record.Stale = false
+	}
+	return writeUsageRecord(ctx, factory, id, record)
+}
+
+// computeUsage lists a workspace and its revisions to compute its usage.
+func computeUsage(ctx context.Context, wc workspaceClient) (Usage, error) {
+	var usage Usage
+
+	files, err := wc.LsWithInfo(ctx, "")
+	if err != nil {
+		return usage, err
+	}
+	for _, file := range files {
+		if file.IsDir || strings.HasSuffix(file.Name, "/") {
+			continue
+		}
+		usage.Files++
+		usage.Bytes += file.Size
+	}
+
+	rc := wc.RevisionClient()
+	if rc == nil {
+		return usage, nil
+	}
+
+	revisions, err := rc.LsWithInfo(ctx, "")
+	if err != nil {
+		return usage, err
+	}
+	for _, revision := range revisions {
+		if revision.IsDir || strings.HasSuffix(revision.Name, ".json") {
+			continue
+		}
+		usage.RevisionFiles++
+		usage.RevisionBytes += revision.Size
+	}
+
+	return usage, nil
+}
+
+// account is the recorded usage of a workspace that a quota applies to, which writes are checked against.
+//
+// Changes to the usage are made while holding the lock on the record, so that concurrent writes can't both pass the
+// quota check or lose each other's counts. Writes reserve their bytes before they are made, so on top of reading the
+// record to load the account, a write costs a stat of the file and a locked update of the record, which creates and
+// removes the lock and reads and writes the record. Content whose size isn't known takes a few more updates.
+type account struct {
+	factory workspaceFactory
+	wc      workspaceClient
+	id      string
+	// base is the quota of the provider, which the quota set on the workspace is merged into.
+	base   Quota
+	quota  Quota
+	record usageRecord
+}
+
+// loadAccount returns the account of a workspace, or nil if no quota applies to it. If the usage hasn't been recorded
+// or is stale, then it is recomputed.
+func (c *Client) loadAccount(ctx context.Context, id string, wc workspaceClient) (*account, error) {
+	factory, id, err := c.getWorkspaceFactory(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	provider, _, _ := strings.Cut(id, "://")
+	if !c.hasQuotas(provider) {
+		// Without quotas, the usage isn't recorded, so the record isn't read.
+		return nil, nil
+	}
+	base := c.quotas[provider]
+
+	record, found, err := readUsageRecord(ctx, factory, id)
+	if err != nil {
+		return nil, err
+	}
+
+	quota := base.merge(record.Quota)
+	if quota.isZero() {
+		if found {
+			// The provider's quota was removed, so the usage would no longer be kept up to date.
+			return nil, deleteUsageRecord(ctx, factory, id)
+		}
+		return nil, nil
+	}
+
+	a := &account{factory: factory, wc: wc, id: id, base: base, quota: quota, record: record}
+	if !found || record.Stale {
+		if err = a.update(ctx, nil); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+func (a *account) recompute(ctx context.Context) error {
+	return a.updateRecord(ctx, true, nil)
+}
+
+// update reads the usage record again while holding its lock, recomputing the usage if it hasn't been recorded or is
+// stale, and then applies fn to it and writes it. The record isn't written if fn returns an error. A nil fn only
+// reads the record.
+func (a *account) update(ctx context.Context, fn func(*usageRecord) error) error {
+	return a.updateRecord(ctx, false, fn)
+}
+
+func (a *account) updateRecord(ctx context.Context, recount bool, fn func(*usageRecord) error) error {
+	unlock, err := lockUsage(ctx, a.factory, a.id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	record, found, err := readUsageRecord(ctx, a.factory, a.id)
+	if err != nil {
+		return err
+	}
+
+	changed := recount || !found || record.Stale
+	if changed {
+		if record.Usage, err = computeUsage(ctx, a.wc); err != nil {
+			return err
+		}
+		record.Stale = false
+	}
+
+	a.record, a.quota = record, a.base.merge(record.Quota)
+	if fn != nil {
+		if err = fn(&a.record); err != nil {
+			a.record = record
+			return err
+		}
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return writeUsageRecord(ctx, a.factory, a.id, a.record)
+}
+
+// fileChange is a write to a file, which is checked against the quota before it is made.
+type fileChange struct {
+	name   string
+	exists bool
+	// size is the size of the file before the write.
+	size int64
+	// appending adds the written bytes to the file instead of replacing its content.
+	appending bool
+	// revision is whether the previous content of the file is kept as a revision.
+	revision bool
+}
+
+func (a *account) change(ctx context.Context, name string, appending, revision bool) (fileChange, error) {
+	ch := fileChange{name: name, appending: appending, revision: revision && a.wc.RevisionClient() != nil}
+
+	info, err := a.wc.StatFile(ctx, name, StatOptions{})
+	if err != nil {
+		if nfe := (*NotFoundError)(nil); errors.As(err, &nfe) {
+			return ch, nil
+		}
+		return ch, err
+	}
+
+	ch.exists, ch.size = !info.IsDir, info.Size
+	return ch, nil
+}
+
+// added returns the bytes that writing n bytes adds to the workspace, which is negative if the write frees space.
+func (ch fileChange) added(n int64) int64 {
+	switch {
+	case !ch.exists:
+		return n
+	case ch.appending && ch.revision:
+		return n + ch.size
+	case ch.appending, ch.revision:
+		return n
+	default:
+		return n - ch.size
+	}
+}
+
+// delta returns the change in usage of writing n bytes.
+func (ch fileChange) delta(n int64) Usage {
+	var d Usage
+	if !ch.exists {
+		d.Files = 1
+	} else if ch.revision {
+		d.RevisionFiles, d.RevisionBytes = 1, ch.size
+	}
+	d.Bytes = n
+	if ch.exists && !ch.appending {
+		d.Bytes -= ch.size
+	}
+	return d
+}
+
+// fileSize returns the size of the file after writing n bytes.
+func (ch fileChange) fileSize(n int64) int64 {
+	if ch.appending && ch.exists {
+		return ch.size + n
+	}
+	return n
+}
+
+// check returns a QuotaExceededError if writing n bytes would exceed the quota.
+func (a *account) check(ch fileChange, n int64) error {
+	q, u := a.quota, a.record.Usage
+	if q.MaxFileSize > 0 && ch.fileSize(n) > q.MaxFileSize {
+		return newQuotaExceededError(a.id, ch.name, QuotaMaxFileSize, q.MaxFileSize)
+	}
+	if q.MaxFiles > 0 && !ch.exists && u.Files >= q.MaxFiles {
+		return newQuotaExceededError(a.id, ch.name, QuotaMaxFiles, q.MaxFiles)
+	}
+	if added := ch.added(n); q.MaxBytes > 0 && added > 0 && u.Bytes+u.RevisionBytes+added > q.MaxBytes {
+		return newQuotaExceededError(a.id, ch.name, QuotaMaxBytes, q.MaxBytes)
+	}
+	return nil
+}
+
+// reserve checks a write of n bytes against the quota and adds it to the recorded usage before it is made.
+func (a *account) reserve(ctx context.Context, ch fileChange, n int64) error {
+	return a.update(ctx, func(r *usageRecord) error {
+		if err := a.check(ch, n); err != nil {
+			return err
+		}
+		r.Usage = r.Usage.plus(ch.delta(n))
+		return nil
+	})
+}
+
+// reserveMore grows the bytes reserved for content whose size isn't known once n bytes of it have been read, and
+// returns the bytes now reserved. The reservation is doubled, so that the record is only updated a few times for large
+// content, unless that would exceed the quota, in which case only what was read is reserved.
+func (a *account) reserveMore(ctx context.Context, ch fileChange, reserved, n int64) (int64, error) {
+	err := a.update(ctx, func(r *usageRecord) error {
+		q, u := a.quota, r.Usage
+		exceeds := func(extra int64) bool {
+			return q.MaxBytes > 0 && ch.added(reserved+extra) > 0 && u.Bytes+u.RevisionBytes+extra > q.MaxBytes
+		}
+
+		extra := max(n-reserved, reserved, usageReserveSize)
+		if exceeds(extra) {
+			if extra = n - reserved; exceeds(extra) {
+				return newQuotaExceededError(a.id, ch.name, QuotaMaxBytes, q.MaxBytes)
+			}
+		}
+
+		r.Usage.Bytes += extra
+		reserved += extra
+		return nil
+	})
+	return reserved, err
+}
+
+// release removes the reservation of a write that failed. Like settling a reservation, this is best effort, since the
+// write can't be undone if it fails, so the usage can be off until it is recomputed.
+func (a *account) release(ctx context.Context, ch fileChange, reserved int64) {
+	_ = a.update(context.WithoutCancel(ctx), func(r *usageRecord) error {
+		r.Usage = r.Usage.minus(ch.delta(reserved))
+		return nil
+	})
+}
+
+// settle removes the bytes that were reserved for a write but not written.
+func (a *account) settle(ctx context.Context, unused int64) {
+	if unused == 0 {
+		return
+	}
+	_ = a.update(ctx, func(r *usageRecord) error {
+		r.Usage = r.Usage.minus(Usage{Bytes: unused})
+		return nil
+	})
+}
+
+// write checks the content of a write against the quota, makes the write and records it. The size of seekable content
+// is reserved before writing, and other content is reserved as it is read, failing the write once it exceeds the quota.
+func (a *account) write(ctx context.Context, ch fileChange, r io.Reader, write func(io.Reader) error) error {
+	if size, ok := readerSize(r); ok {
+		if err := a.reserve(ctx, ch, size); err != nil {
+			return err
+		}
+		if err := write(r); err != nil {
+			a.release(ctx, ch, size)
+			return err
+		}
+		return nil
+	}
+
+	if err := a.reserve(ctx, ch, 0); err != nil {
+		return err
+	}
+
+	qr := &quotaReader{Reader: r, ctx: ctx, account: a, change: ch}
+	if err := write(qr); err != nil {
+		a.release(ctx, ch, qr.reserved)
+		if qr.err != nil {
+			// The backend may not wrap the error from reading the content.
+			return qr.err
+		}
+		return err
+	}
+
+	a.settle(ctx, qr.reserved-qr.n)
+	return nil
+}
+
+// copyFile copies a file from another workspace client into the workspace, checking its size against the quota before
+// copying it.
+func (a *account) copyFile(ctx context.Context, source workspaceClient, srcFile, dstFile string, opt WriteOptions) error {
+	info, err := source.StatFile(ctx, srcFile, StatOptions{})
+	if err != nil {
+		return err
+	}
+
+	ch, err := a.change(ctx, dstFile, false, opt.CreateRevision == nil || *opt.CreateRevision)
+	if err != nil {
+		return err
+	}
+	if err = a.reserve(ctx, ch, info.Size); err != nil {
+		return err
+	}
+
+	if err = source.CopyFile(ctx, srcFile, a.wc, dstFile, opt); err != nil {
+		a.release(ctx, ch, info.Size)
+		return err
+	}
+	return nil
+}
+
+// deleteFile deletes a file along with its revisions, and records the space that is freed. Recording it is best effort,
+// like settling a reservation.
+func (a *account) deleteFile(ctx context.Context, name string) error {
+	info, err := a.wc.StatFile(ctx, name, StatOptions{})
+	if err != nil {
+		return err
+	}
+
+	var revisions []RevisionInfo
+	if !info.IsDir && a.wc.RevisionClient() != nil {
+		if revisions, err = a.wc.ListRevisions(ctx, name); err != nil {
+			return err
+		}
+	}
+
+	if err = a.wc.DeleteFile(ctx, name); err != nil || info.IsDir {
+		return err
+	}
+
+	freed := Usage{Files: 1, Bytes: info.Size}
+	for _, revision := range revisions {
+		freed.RevisionFiles++
+		freed.RevisionBytes += revision.StoredSize
+	}
+
+	_ = a.update(ctx, func(r *usageRecord) error {
+		r.Usage = r.Usage.minus(freed)
+		return nil
+	})
+	return nil
+}
+
+func (u Usage) plus(d Usage) Usage {
+	return Usage{Files: u.Files + d.Files, Bytes: u.Bytes + d.Bytes, RevisionFiles: u.RevisionFiles + d.RevisionFiles, RevisionBytes: u.RevisionBytes + d.RevisionBytes}
+}
+
+// minus subtracts a change in usage, without letting the counters go below zero.
+func (u Usage) minus(d Usage) Usage {
+	return Usage{
+		Files:         max(u.Files-d.Files, 0),
+		Bytes:         max(u.Bytes-d.Bytes, 0),
+		RevisionFiles: max(u.RevisionFiles-d.RevisionFiles, 0),
+		RevisionBytes: max(u.RevisionBytes-d.RevisionBytes, 0),
+	}
+}
+
+// quotaReader reserves the content of a write as it is read, and fails once it exceeds the quota.
+type quotaReader struct {
+	io.Reader
+	ctx     context.Context
+	account *account
+	change  fileChange
+	n       int64
+	// reserved is the number of bytes of the content that have been added to the recorded usage.
+	reserved int64
+	err      error
+}
+
+func (r *quotaReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.n += int64(n)
+	if q := r.account.quota; q.MaxFileSize > 0 && r.change.fileSize(r.n) > q.MaxFileSize {
+		r.err = newQuotaExceededError(r.account.id, r.change.name, QuotaMaxFileSize, q.MaxFileSize)
+		return n, r.err
+	}
+	if r.n > r.reserved {
+		if r.reserved, r.err = r.account.reserveMore(r.ctx, r.change, r.reserved, r.n); r.err != nil {
+			return n, r.err
+		}
+	}
+	return n, err
+}
+
+// readerSize returns the size of the remaining content of a reader, if it can seek.
+func readerSize(r io.Reader) (int64, bool) {
+	s, ok := r.(io.Seeker)
+	if !ok {
+		return 0, false
+	}
+
+	current, err := s.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, false
+	}
+	end, err := s.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, false
+	}
+	if _, err = s.Seek(current, io.SeekStart); err != nil {
+		return 0, false
+	}
+	return end - current, true
+}
+
+// markUsageStale marks the recorded usage of a workspace as stale after files were removed without counting them, so
+// that it is recomputed when next needed. This is best effort, like recording the usage.
+func (c *Client) markUsageStale(ctx context.Context, id string) {
+	factory, id, err := c.getWorkspaceFactory(ctx, id)
+	if err != nil {
+		return
+	}
+	if provider, _, _ := strings.Cut(id, "://"); !c.hasQuotas(provider) {
+		return
+	}
+
+	// The record is only locked if there is one, so that workspaces without a quota aren't locked.
+	if record, found, err := readUsageRecord(ctx, factory, id); err != nil || !found || record.Stale {
+		return
+	}
+
+	unlock, err := lockUsage(ctx, factory, id)
+	if err != nil {
+		return
+	}
+	defer unlock()
+
+	record, found, err := readUsageRecord(ctx, factory, id)
+	if err != nil || !found || record.Stale {
+		return
+	}
+
+	record.Stale = true
+	_ = writeUsageRecord(ctx, factory, id, record)
+}
+
+// chargeBatch checks the net change of a batch whose content has been staged against the quota, and adds it to the
+// recorded usage before the batch is applied. Its deletes are counted first, and then its writes in order, so a batch
+// that frees as much space as it uses is allowed.
+func (c *Client) chargeBatch(ctx context.Context, id string, wc, sc workspaceClient, stageDir string, ops []BatchOperation, createRevision bool) error {
+	a, err := c.loadAccount(ctx, id, wc)
+	if err != nil || a == nil {
+		return err
+	}
+
+	var freed Usage
+	changes := make([]fileChange, 0, len(ops))
+	sizes := make([]int64, 0, len(ops))
+	for i, op := range ops {
+		if op.Op == BatchDelete {
+			info, err := wc.StatFile(ctx, op.FileName, StatOptions{})
+			if err != nil {
+				return err
+			}
+			if !info.IsDir {
+				freed.Files++
+				freed.Bytes += info.Size
+			}
+			continue
+		}
+
+		staged, err := sc.StatFile(ctx, stagedFileName(stageDir, i), StatOptions{})
+		if err != nil {
+			return err
+		}
+		ch, err := a.change(ctx, op.FileName, false, createRevision)
+		if err != nil {
+			return err
+		}
+		changes = append(changes, ch)
+		sizes = append(sizes, staged.Size)
+	}
+
+	return a.update(ctx, func(r *usageRecord) error {
+		r.Usage = r.Usage.minus(freed)
+		for i, ch := range changes {
+			if err := a.check(ch, sizes[i]); err != nil {
+				return err
+			}
+			r.Usage = r.Usage.plus(ch.delta(sizes[i]))
+		}
+		return nil
+	})
+}
+
+// checkCreateQuota returns a QuotaExceededError if copying the files of the given workspaces into a new workspace would
+// exceed the quota.
+func (c *Client) checkCreateQuota(ctx context.Context, id string, quota Quota, fromWorkspaces []string) error {
+	var total Usage
+	for _, fromWorkspace := range fromWorkspaces {
+		wc, _, err := c.getClient(ctx, fromWorkspace)
+		if err != nil {
+			return err
+		}
+
+		if quota.MaxFileSize > 0 {
+			files, err := wc.LsWithInfo(ctx, "")
+			if err != nil {
+				return err
+			}
+			for _, file := range files {
+				if file.Size > quota.MaxFileSize {
+					return newQuotaExceededError(id, file.Name, QuotaMaxFileSize, quota.MaxFileSize)
+				}
+			}
+		}
+
+		usage, err := computeUsage(ctx, wc)
+		if err != nil {
+			return err
+		}
+		total.Files += usage.Files
+		total.Bytes += usage.Bytes + usage.RevisionBytes
+	}
+
+	if quota.MaxFiles > 0 && total.Files > quota.MaxFiles {
+		return newQuotaExceededError(id, "", QuotaMaxFiles, quota.MaxFiles)
+	}
+	if quota.MaxBytes > 0 && total.Bytes > quota.MaxBytes {
+		return newQuotaExceededError(id, "", QuotaMaxBytes, quota.MaxBytes)
+	}
+	return nil
+}
+
+// usageClient returns the client that the usage of a workspace is recorded in, along with its file name.
+func usageClient(factory workspaceFactory, id string) (workspaceClient, string, error) {
+	mc, name, err := factory.ManifestClient(id)
+	return mc, usageDir + "/" + name, err
+}
+
+// usageLock is the lock on the usage record of a workspace.
+type usageLock struct {
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// lockUsage locks the usage record of a workspace, so that it is changed by one client at a time, and returns the
+// function that unlocks it. The lock is a file next to the record that is created only if it doesn't exist, so locking
+// waits for it to be removed. Taking over an expired lock isn't atomic, but it only happens after a client stopped
+// while holding one.
+func lockUsage(ctx context.Context, factory workspaceFactory, id string) (func(), error) {
+	uc, name, err := usageClient(factory, id)
+	if err != nil {
+		return nil, err
+	}
+	lockName := strings.TrimSuffix(name, ".json") + ".lock"
+
+	delay := 10 * time.Millisecond
+	for {
+		b, err := json.Marshal(usageLock{ExpiresAt: time.Now().Add(usageLockTimeout)})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal usage lock: %w", err)
+		}
+
+		err = uc.WriteFile(ctx, lockName, bytes.NewReader(b), WriteOptions{CreateRevision: &[]bool{false}[0], exclusive: true})
+		if err == nil {
+			return func() {
+				// Best effort, since the lock is taken over once it expires.
+				_ = uc.DeleteFile(context.WithoutCancel(ctx), lockName)
+			}, nil
+		}
+		if fee := (*FileExistsError)(nil); !errors.As(err, &fee) {
+			return nil, err
+		}
+
+		expired, err := usageLockExpired(ctx, uc, lockName)
+		if err != nil {
+			return nil, err
+		}
+		if expired {
+			if err = uc.DeleteFile(ctx, lockName); err != nil {
+				if nfe := (*NotFoundError)(nil); !errors.As(err, &nfe) {
+					return nil, err
+				}
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, context.Cause(ctx)
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, 250*time.Millisecond)
+	}
+}
+
+// usageLockExpired returns whether the lock on a usage record has expired. A lock that can't be read is treated as
+// expired, and one that has just been removed isn't.
+func usageLockExpired(ctx context.Context, uc workspaceClient, lockName string) (bool, error) {
+	f, err := uc.OpenFile(ctx, lockName, OpenOptions{})
+	if err != nil {
+		if nfe := (*NotFoundError)(nil); errors.As(err, &nfe) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer f.Close()
+
+	var lock usageLock
+	if err = json.NewDecoder(f).Decode(&lock); err != nil {
+		return true, nil
+	}
+	return time.Now().After(lock.ExpiresAt), nil
+}
+
+func readUsageRecord(ctx context.Context, factory workspaceFactory, id string) (usageRecord, bool, error) {
+	var record usageRecord
+
+	uc, name, err := usageClient(factory, id)
+	if err != nil {
+		return record, false, err
+	}
+
+	f, err := uc.OpenFile(ctx, name, OpenOptions{})
+	if err != nil {
+		if nfe := (*NotFoundError)(nil); errors.As(err, &nfe) {
+			return record, false, nil
+		}
+		return record, false, err
+	}
+	defer f.Close()
+
+	if err = json.NewDecoder(f).Decode(&record); err != nil {
+		return record, false, fmt.Errorf("failed to read usage of workspace %s: %w", id, err)
+	}
+	return record, true, nil
+}
+
+func writeUsageRecord(ctx context.Context, factory workspaceFactory, id string, record usageRecord) error {
+	b, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal usage: %w", err)
+	}
+
+	uc, name, err := usageClient(factory, id)
+	if err != nil {
+		return err
+	}
+	return uc.WriteFile(ctx, name, bytes.NewReader(b), WriteOptions{CreateRevision: &[]bool{false}[0]})
+}
+
+func deleteUsageRecord(ctx context.Context, factory workspaceFactory, id string) error {
+	uc, name, err := usageClient(factory, id)
+	if err != nil {
+		return err
+	}
+
+	err = uc.DeleteFile(ctx, name)
+	if nfe := (*NotFoundError)(nil); errors.As(err, &nfe) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,36 @@
+package client
+
+import (
+	"os"
+
+	"github.com/pkg/xattr"
+)
+
+// fileAttr returns the value of an extended attribute of a file on disk, or nothing if it isn't set or the file system
+// doesn't support extended attributes.
+func fileAttr(f *os.File, name string) string {
+	value, err := xattr.FGet(f, name)
+	if err != nil {
+		return ""
+	}
+	return string(value)
+}
+
+// pathAttr returns the value of an extended attribute of the file at the path, like fileAttr.
+func pathAttr(path, name string) string {
+	value, err := xattr.Get(path, name)
+	if err != nil {
+		return ""
+	}
+	return string(value)
+}
+
+// setFileAttr sets an extended attribute of a file on disk, or removes it if the value is empty. This is best effort,
+// since not all file systems support extended attributes.
+func setFileAttr(f *os.File, name, value string) {
+	if value == "" {
+		_ = xattr.FRemove(f, name)
+		return
+	}
+	_ = xattr.FSet(f, name, []byte(value))
+}
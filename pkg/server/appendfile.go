@@ -0,0 +1,35 @@
+package server
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gptscript-ai/workspace-provider/pkg/client"
+)
+
+func (s *server) appendFile(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	fileName := r.PathValue("fileName")
+	query := r.URL.Query()
+
+	opts := client.AppendOptions{
+		LatestRevisionID: query.Get("latestRevision"),
+		CreateRevision:   toPtr(query.Get("createRevision") != "false"),
+	}
+
+	if err := s.client.AppendFile(r.Context(), id, fileName, base64.NewDecoder(base64.StdEncoding, r.Body), opts); err != nil {
+		if ce := (*client.ConflictError)(nil); errors.As(err, &ce) {
+			w.WriteHeader(http.StatusConflict)
+		} else if status, ok := quotaExceededStatus(err); ok {
+			w.WriteHeader(status)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+
+	_, _ = w.Write([]byte(fmt.Sprintf("file %s has been appended to in workspace %s", fileName, id)))
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gptscript-ai/workspace-provider/pkg/client"
+)
+
+// maxBatchSize is the largest batch request that is accepted, as its content is held in memory until it is staged.
+const maxBatchSize = 64 << 20
+
+type batchRequest struct {
+	CreateRevision *bool            `json:"createRevision"`
+	Operations     []batchOperation `json:"operations"`
+}
+
+type batchOperation struct {
+	Op       string `json:"op"`
+	FileName string `json:"fileName"`
+	// Content is base64 encoded, as it is when writing a file.
+	Content          []byte            `json:"content"`
+	LatestRevisionID string            `json:"latestRevisionID"`
+	IfNotExists      bool              `json:"ifNotExists"`
+	Checksum         string            `json:"checksum"`
+	ContentType      string            `json:"contentType"`
+	Metadata         map[string]string `json:"metadata"`
+}
+
+func (s *server) batch(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	var req batchRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchSize)).Decode(&req); err != nil {
+		if mbe := (*http.MaxBytesError)(nil); errors.As(err, &mbe) {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+		} else {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		_, _ = w.Write([]byte(fmt.Sprintf("invalid batch: %s", err.Error())))
+		return
+	}
+
+	ops := make([]client.BatchOperation, 0, len(req.Operations))
+	for _, op := range req.Operations {
+		batchOp := client.BatchOperation{
+			Op:               op.Op,
+			FileName:         op.FileName,
+			LatestRevisionID: op.LatestRevisionID,
+			IfNotExists:      op.IfNotExists,
+			Checksum:         op.Checksum,
+			ContentType:      op.ContentType,
+			Metadata:         op.Metadata,
+		}
+		if op.Op == client.BatchWrite {
+			batchOp.Content = bytes.NewReader(op.Content)
+		}
+		ops = append(ops, batchOp)
+	}
+
+	if err := s.client.Batch(r.Context(), id, ops, client.BatchOptions{CreateRevision: req.CreateRevision}); err != nil {
+		if fnf := (*client.NotFoundError)(nil); errors.As(err, &fnf) {
+			w.WriteHeader(http.StatusNotFound)
+		} else if ce, fee := (*client.ConflictError)(nil), (*client.FileExistsError)(nil); errors.As(err, &ce) || errors.As(err, &fee) {
+			w.WriteHeader(http.StatusConflict)
+		} else if cme, iae := (*client.ChecksumMismatchError)(nil), (*client.InvalidArgumentError)(nil); errors.As(err, &cme) || errors.As(err, &iae) {
+			w.WriteHeader(http.StatusBadRequest)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+
+	_, _ = w.Write([]byte(fmt.Sprintf("batch of %d operations has been applied to workspace %s", len(ops), id)))
+}
@@ -2,8 +2,13 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strings"
+	"time"
+
+	"github.com/gptscript-ai/workspace-provider/pkg/client"
 )
 
 type createRequest struct {
@@ -11,7 +16,14 @@ type createRequest struct {
 	FromWorkspaceIDs []string `json:"fromWorkspaceIDs"`
 	// This tool accepts two different types "from these workspaces" because it is not possible to specify that a tool
 	// argument is an array. So, we also support a comma-delimited string for workspace IDs.
-	WorkspaceIDs string `json:"workspace_ids"`
+	WorkspaceIDs string            `json:"workspace_ids"`
+	Name         string            `json:"name"`
+	Labels       map[string]string `json:"labels"`
+	Owner        string            `json:"owner"`
+	Description  string            `json:"description"`
+	// ExpiresAt is an RFC 3339 time, and TTL is a duration such as "24h".
+	ExpiresAt string `json:"expiresAt"`
+	TTL       string `json:"ttl"`
 }
 
 func (s *server) create(w http.ResponseWriter, r *http.Request) {
@@ -23,13 +35,49 @@ func (s *server) create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var (
+		expiresAt time.Time
+		ttl       time.Duration
+		err       error
+	)
+	if req.ExpiresAt != "" {
+		if expiresAt, err = time.Parse(time.RFC3339Nano, req.ExpiresAt); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(fmt.Sprintf("invalid expiresAt time: %s", err.Error())))
+			return
+		}
+	}
+	if req.TTL != "" {
+		if ttl, err = time.ParseDuration(req.TTL); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(fmt.Sprintf("invalid ttl: %s", err.Error())))
+			return
+		}
+	}
+
 	if req.WorkspaceIDs != "" {
 		req.FromWorkspaceIDs = append(req.FromWorkspaceIDs, strings.Split(req.WorkspaceIDs, ",")...)
 	}
 
-	id, err := s.client.Create(r.Context(), req.Provider, req.FromWorkspaceIDs...)
+	id, err := s.client.CreateWithOptions(r.Context(), req.Provider, client.CreateOptions{
+		FromWorkspaces: req.FromWorkspaceIDs,
+		Name:           req.Name,
+		Labels:         req.Labels,
+		Owner:          req.Owner,
+		Description:    req.Description,
+		ExpiresAt:      expiresAt,
+		TTL:            ttl,
+	})
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		if nee := (*client.NameExistsError)(nil); errors.As(err, &nee) {
+			w.WriteHeader(http.StatusConflict)
+		} else if iae := (*client.InvalidArgumentError)(nil); errors.As(err, &iae) {
+			w.WriteHeader(http.StatusBadRequest)
+		} else if status, ok := quotaExceededStatus(err); ok {
+			w.WriteHeader(status)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
 		_, _ = w.Write([]byte(err.Error()))
 		return
 	}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gptscript-ai/workspace-provider/pkg/client"
+)
+
+func (s *server) mkdir(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	dirName := r.PathValue("dirName")
+
+	if err := s.client.Mkdir(r.Context(), id, dirName); err != nil {
+		if fee := (*client.FileExistsError)(nil); errors.As(err, &fee) {
+			w.WriteHeader(http.StatusConflict)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+
+	_, _ = w.Write([]byte(fmt.Sprintf("directory %s has been created in workspace %s", dirName, id)))
+}
+
+func (s *server) removeDir(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	dirName := r.PathValue("dirName")
+
+	if err := s.client.RemoveDir(r.Context(), id, dirName, client.RemoveDirOptions{
+		Recursive: r.URL.Query().Get("recursive") == "true",
+	}); err != nil {
+		if fnf := (*client.NotFoundError)(nil); errors.As(err, &fnf) {
+			w.WriteHeader(http.StatusNotFound)
+		} else if dne := (*client.DirectoryNotEmptyError)(nil); errors.As(err, &dne) {
+			w.WriteHeader(http.StatusConflict)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+
+	_, _ = w.Write([]byte(fmt.Sprintf("directory %s has been removed from workspace %s", dirName, id)))
+}
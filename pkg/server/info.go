@@ -0,0 +1,61 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gptscript-ai/workspace-provider/pkg/client"
+)
+
+func (s *server) info(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	info, err := s.client.Info(r.Context(), id)
+	if err != nil {
+		if nfe := (*client.NotFoundError)(nil); errors.As(err, &nfe) {
+			w.WriteHeader(http.StatusNotFound)
+		} else if iae := (*client.InvalidArgumentError)(nil); errors.As(err, &iae) {
+			w.WriteHeader(http.StatusBadRequest)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+
+	b, err := json.Marshal(info)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+
+	_, _ = w.Write(b)
+}
+
+func (s *server) setLabels(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	var labels map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&labels); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(fmt.Sprintf("invalid labels: %s", err.Error())))
+		return
+	}
+
+	if err := s.client.SetLabels(r.Context(), id, labels); err != nil {
+		if nfe := (*client.NotFoundError)(nil); errors.As(err, &nfe) {
+			w.WriteHeader(http.StatusNotFound)
+		} else if iae := (*client.InvalidArgumentError)(nil); errors.As(err, &iae) {
+			w.WriteHeader(http.StatusBadRequest)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+
+	_, _ = w.Write([]byte(fmt.Sprintf("labels of workspace %s have been set", id)))
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gptscript-ai/workspace-provider/pkg/client"
+)
+
+// list is an admin route that lists the workspaces of a provider, for operators rather than tools.
+func (s *server) list(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	labels, err := metadataValues(query, "label")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+
+	opts := client.ListOptions{
+		Labels:       labels,
+		Continuation: query.Get("continuation"),
+	}
+	if limit := query.Get("limit"); limit != "" {
+		if opts.Limit, err = strconv.Atoi(limit); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(fmt.Sprintf("invalid limit: %s", err.Error())))
+			return
+		}
+	}
+
+	page, err := s.client.List(r.Context(), query.Get("provider"), opts)
+	if err != nil {
+		if iae := (*client.InvalidArgumentError)(nil); errors.As(err, &iae) {
+			w.WriteHeader(http.StatusBadRequest)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+
+	b, err := json.Marshal(page)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+
+	_, _ = w.Write(b)
+}
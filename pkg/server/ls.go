@@ -2,17 +2,47 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gptscript-ai/workspace-provider/pkg/client"
 )
 
 func (s *server) ls(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	prefix := r.PathValue("prefix")
 
-	ws, err := s.client.Ls(r.Context(), id, prefix)
+	opts, err := lsOptions(r)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+
+	query := r.URL.Query()
+	if query.Get("stream") == "true" {
+		s.lsStream(w, r, id, prefix, opts)
+		return
+	}
+
+	var ws any
+	if opts.Limit != 0 || opts.Continuation != "" {
+		ws, err = s.client.LsPage(r.Context(), id, prefix, opts)
+	} else if query.Get("withInfo") == "true" {
+		ws, err = s.client.LsWithInfo(r.Context(), id, prefix, opts)
+	} else {
+		ws, err = s.client.Ls(r.Context(), id, prefix, opts)
+	}
+	if err != nil {
+		if iae := (*client.InvalidArgumentError)(nil); errors.As(err, &iae) {
+			w.WriteHeader(http.StatusBadRequest)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
 		_, _ = w.Write([]byte(err.Error()))
 		return
 	}
@@ -26,3 +56,101 @@ func (s *server) ls(w http.ResponseWriter, r *http.Request) {
 
 	_, _ = w.Write(b)
 }
+
+// lsStream writes the files as newline-delimited JSON as they are listed, so that large workspaces aren't held in
+// memory. An error after the first file can't change the status, so it is written as a final {"error": "..."} line.
+func (s *server) lsStream(w http.ResponseWriter, r *http.Request, id, prefix string, opts client.LsOptions) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+
+	// The response writer's buffer is written out as it fills, so files are sent as they are listed.
+	encoder := json.NewEncoder(w)
+	var written bool
+	for info, err := range s.client.LsIter(r.Context(), id, prefix, opts) {
+		if err != nil {
+			if !written {
+				w.Header().Del("Content-Type")
+				if iae := (*client.InvalidArgumentError)(nil); errors.As(err, &iae) {
+					w.WriteHeader(http.StatusBadRequest)
+				} else {
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+
+			_ = encoder.Encode(map[string]string{"error": err.Error()})
+			return
+		}
+
+		if err = encoder.Encode(info); err != nil {
+			return
+		}
+		written = true
+	}
+}
+
+// lsOptions parses the listing options from the query parameters. Filtering and sorting only apply when listing with
+// info.
+func lsOptions(r *http.Request) (client.LsOptions, error) {
+	t, err := asOf(r)
+	if err != nil {
+		return client.LsOptions{}, err
+	}
+
+	query := r.URL.Query()
+	opts := client.LsOptions{
+		AsOf:       t,
+		SortBy:     query.Get("sortBy"),
+		Descending: query.Get("descending") == "true",
+		Include:    patterns(query, "include"),
+		Exclude:    patterns(query, "exclude"),
+	}
+
+	if recursive := query.Get("recursive"); recursive != "" {
+		r, err := strconv.ParseBool(recursive)
+		if err != nil {
+			return client.LsOptions{}, fmt.Errorf("invalid recursive: %w", err)
+		}
+		opts.Recursive = &r
+	}
+
+	if opts.Metadata, err = metadataValues(query, "metadata"); err != nil {
+		return client.LsOptions{}, err
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		if opts.Limit, err = strconv.Atoi(limit); err != nil {
+			return client.LsOptions{}, fmt.Errorf("invalid limit: %w", err)
+		}
+	}
+	opts.Continuation = query.Get("continuation")
+
+	for name, size := range map[string]*int64{"minSize": &opts.MinSize, "maxSize": &opts.MaxSize} {
+		if value := query.Get(name); value != "" {
+			if *size, err = strconv.ParseInt(value, 10, 64); err != nil {
+				return client.LsOptions{}, fmt.Errorf("invalid %s: %w", name, err)
+			}
+		}
+	}
+
+	for name, modTime := range map[string]*time.Time{"modifiedAfter": &opts.ModifiedAfter, "modifiedBefore": &opts.ModifiedBefore} {
+		if value := query.Get(name); value != "" {
+			if *modTime, err = time.Parse(time.RFC3339Nano, value); err != nil {
+				return client.LsOptions{}, fmt.Errorf("invalid %s time: %w", name, err)
+			}
+		}
+	}
+
+	return opts, nil
+}
+
+// patterns returns the values of a repeated query parameter, skipping empty ones.
+func patterns(query url.Values, name string) []string {
+	var result []string
+	for _, pattern := range query[name] {
+		if pattern != "" {
+			result = append(result, pattern)
+		}
+	}
+	return result
+}
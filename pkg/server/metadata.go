@@ -0,0 +1,88 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gptscript-ai/workspace-provider/pkg/client"
+)
+
+func (s *server) setMetadata(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	fileName := r.PathValue("fileName")
+
+	var metadata map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&metadata); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(fmt.Sprintf("invalid metadata: %s", err.Error())))
+		return
+	}
+
+	if err := s.client.SetMetadata(r.Context(), id, fileName, metadata); err != nil {
+		if fnf := (*client.NotFoundError)(nil); errors.As(err, &fnf) {
+			w.WriteHeader(http.StatusNotFound)
+		} else if iae := (*client.InvalidArgumentError)(nil); errors.As(err, &iae) {
+			w.WriteHeader(http.StatusBadRequest)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+
+	_, _ = w.Write([]byte(fmt.Sprintf("metadata of file %s has been set in workspace %s", fileName, id)))
+}
+
+func (s *server) getMetadata(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	fileName := r.PathValue("fileName")
+
+	metadata, err := s.client.GetMetadata(r.Context(), id, fileName)
+	if err != nil {
+		if fnf := (*client.NotFoundError)(nil); errors.As(err, &fnf) {
+			w.WriteHeader(http.StatusNotFound)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+
+	b, err := json.Marshal(metadata)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+
+	_, _ = w.Write(b)
+}
+
+// metadataValues parses the values of a repeated query parameter of the form key=value into metadata, skipping empty
+// ones.
+func metadataValues(query url.Values, name string) (map[string]string, error) {
+	var metadata map[string]string
+	for _, value := range query[name] {
+		if value == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(value, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid %s %q: must be key=value", name, key)
+		}
+		if metadata == nil {
+			metadata = make(map[string]string)
+		}
+		metadata[key] = value
+	}
+	return metadata, nil
+}
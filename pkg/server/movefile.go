@@ -0,0 +1,36 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gptscript-ai/workspace-provider/pkg/client"
+)
+
+func (s *server) moveFile(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	fileName := r.PathValue("fileName")
+	newFileName := r.PathValue("newFileName")
+	query := r.URL.Query()
+
+	opts := client.MoveOptions{
+		LatestRevisionID:            query.Get("latestRevision"),
+		DestinationLatestRevisionID: query.Get("destinationLatestRevision"),
+		IfNotExists:                 query.Get("ifNotExists") == "true",
+	}
+
+	if err := s.client.Move(r.Context(), id, fileName, newFileName, opts); err != nil {
+		if fnf := (*client.NotFoundError)(nil); errors.As(err, &fnf) {
+			w.WriteHeader(http.StatusNotFound)
+		} else if ce, fee := (*client.ConflictError)(nil), (*client.FileExistsError)(nil); errors.As(err, &ce) || errors.As(err, &fee) {
+			w.WriteHeader(http.StatusConflict)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+
+	_, _ = w.Write([]byte(fmt.Sprintf("file %s has been moved to %s in workspace %s", fileName, newFileName, id)))
+}
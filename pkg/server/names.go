@@ -0,0 +1,70 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gptscript-ai/workspace-provider/pkg/client"
+)
+
+func (s *server) resolve(w http.ResponseWriter, r *http.Request) {
+	id, err := s.client.Resolve(r.Context(), r.PathValue("name"))
+	if err != nil {
+		if fnf := (*client.NotFoundError)(nil); errors.As(err, &fnf) {
+			w.WriteHeader(http.StatusNotFound)
+		} else if iae := (*client.InvalidArgumentError)(nil); errors.As(err, &iae) {
+			w.WriteHeader(http.StatusBadRequest)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+
+	_, _ = w.Write([]byte(id))
+}
+
+func (s *server) rename(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	name := r.URL.Query().Get("name")
+
+	if err := s.client.Rename(r.Context(), id, name); err != nil {
+		if nee := (*client.NameExistsError)(nil); errors.As(err, &nee) {
+			w.WriteHeader(http.StatusConflict)
+		} else if nfe := (*client.NotFoundError)(nil); errors.As(err, &nfe) {
+			w.WriteHeader(http.StatusNotFound)
+		} else if iae := (*client.InvalidArgumentError)(nil); errors.As(err, &iae) {
+			w.WriteHeader(http.StatusBadRequest)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+
+	if name == "" {
+		_, _ = w.Write([]byte(fmt.Sprintf("name of workspace %s has been removed", id)))
+		return
+	}
+	_, _ = w.Write([]byte(fmt.Sprintf("workspace %s has been renamed to %s", id, name)))
+}
+
+func (s *server) names(w http.ResponseWriter, r *http.Request) {
+	names, err := s.client.Names(r.Context())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+
+	b, err := json.Marshal(names)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+
+	_, _ = w.Write(b)
+}
@@ -4,8 +4,12 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 
 	"github.com/gptscript-ai/workspace-provider/pkg/client"
 )
@@ -14,8 +18,45 @@ func (s *server) readFile(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	fileName := r.PathValue("fileName")
 	withLatestRevision := r.URL.Query().Get("withLatestRevision") == "true"
+	verifyChecksum := r.URL.Query().Get("verifyChecksum") == "true"
 
-	rc, err := s.client.OpenFile(r.Context(), id, fileName, client.OpenOptions{WithLatestRevisionID: withLatestRevision})
+	t, err := asOf(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+
+	opts := client.OpenOptions{WithLatestRevisionID: withLatestRevision, AsOf: t, VerifyChecksum: verifyChecksum}
+	rng, err := readRange(r, &opts)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+
+	partial := rng != nil
+	var size int64
+	if partial {
+		if size, err = s.resolveRange(r, id, fileName, *rng, &opts); err != nil {
+			if fnf := (*client.NotFoundError)(nil); errors.As(err, &fnf) {
+				w.WriteHeader(http.StatusNotFound)
+			} else if iae := (*client.InvalidArgumentError)(nil); errors.As(err, &iae) {
+				w.WriteHeader(http.StatusBadRequest)
+			} else {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		if opts.Offset >= size {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+	}
+
+	rc, err := s.client.OpenFile(r.Context(), id, fileName, opts)
 	if err != nil {
 		if fnf := (*client.NotFoundError)(nil); errors.As(err, &fnf) {
 			w.WriteHeader(http.StatusNotFound)
@@ -27,10 +68,64 @@ func (s *server) readFile(w http.ResponseWriter, r *http.Request) {
 	}
 	defer rc.Close()
 
+	if partial {
+		// Partial content is returned as is, like any other range response.
+		w.Header().Set("Content-Range", contentRange(opts.Offset, opts.Length, size))
+		w.Header().Set("Content-Length", strconv.FormatInt(opts.Length, 10))
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = io.Copy(w, rc)
+		return
+	}
+
+	if rc.Checksum != "" {
+		w.Header().Set("X-Checksum-Sha256", rc.Checksum)
+	}
+
+	var reader io.Reader = rc
+	if verifyChecksum {
+		// The content is verified while it is spooled to a temporary file, before it is written, so that a checksum
+		// mismatch can be reported with the status without holding the file in memory.
+		spool, err := spoolFile(rc)
+		if err != nil {
+			w.Header().Del("X-Checksum-Sha256")
+			if cme := (*client.ChecksumMismatchError)(nil); errors.As(err, &cme) {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+			} else {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		defer func() {
+			_ = spool.Close()
+			_ = os.Remove(spool.Name())
+		}()
+		reader = spool
+	}
+
 	writer := base64.NewEncoder(base64.StdEncoding, w)
 	defer writer.Close()
 
-	_, _ = io.Copy(writer, rc)
+	_, _ = io.Copy(writer, reader)
+}
+
+// spoolFile copies the content to a temporary file and returns it, positioned at the start. The caller removes it.
+func spoolFile(r io.Reader) (*os.File, error) {
+	f, err := os.CreateTemp("", "workspace-provider-read-")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = io.Copy(f, r); err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return nil, err
+	}
+
+	return f, nil
 }
 
 type readFileWithRevisionResponse struct {
@@ -77,3 +172,97 @@ func (s *server) readFileWithRevision(w http.ResponseWriter, r *http.Request) {
 
 	_, _ = w.Write(b)
 }
+
+// byteRange is a range requested with a standard HTTP Range header. A suffix range is the last bytes of the file, and
+// a last byte of -1 is the end of the file.
+type byteRange struct {
+	offset, last int64
+	suffix       bool
+}
+
+// readRange sets the byte range to read from the offset and length query parameters or, failing that, returns the
+// range of a standard HTTP Range header, in which case a partial content response is expected once the range is
+// resolved. Range headers with multiple ranges are ignored and the whole file is returned.
+func readRange(r *http.Request, opts *client.OpenOptions) (*byteRange, error) {
+	query := r.URL.Query()
+	if offset, length := query.Get("offset"), query.Get("length"); offset != "" || length != "" {
+		var err error
+		if offset != "" {
+			if opts.Offset, err = strconv.ParseInt(offset, 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid offset: %w", err)
+			}
+		}
+		if length != "" {
+			if opts.Length, err = strconv.ParseInt(length, 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid length: %w", err)
+			}
+		}
+		return nil, nil
+	}
+
+	spec, ok := strings.CutPrefix(r.Header.Get("Range"), "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return nil, nil
+	}
+
+	start, end, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return nil, fmt.Errorf("invalid range: %s", spec)
+	}
+
+	var (
+		rng = byteRange{suffix: start == ""}
+		err error
+	)
+	if rng.suffix {
+		// A suffix of zero bytes is valid, but can't be satisfied.
+		if rng.last, err = strconv.ParseInt(end, 10, 64); err != nil || rng.last < 0 {
+			return nil, fmt.Errorf("invalid range: %s", spec)
+		}
+	} else {
+		if rng.offset, err = strconv.ParseInt(start, 10, 64); err != nil || rng.offset < 0 {
+			return nil, fmt.Errorf("invalid range: %s", spec)
+		}
+
+		rng.last = -1
+		if end != "" {
+			if rng.last, err = strconv.ParseInt(end, 10, 64); err != nil || rng.last < rng.offset {
+				return nil, fmt.Errorf("invalid range: %s", spec)
+			}
+		}
+	}
+
+	return &rng, nil
+}
+
+// resolveRange sets the byte range to read from a range requested with a Range header, and returns the size of the
+// file. Open-ended and suffix ranges are resolved against the size of the file, which is also needed for the
+// Content-Range header. Ranges that can't be satisfied start at the end of the file.
+func (s *server) resolveRange(r *http.Request, id, fileName string, rng byteRange, opts *client.OpenOptions) (int64, error) {
+	info, err := s.client.StatFile(r.Context(), id, fileName, client.StatOptions{AsOf: opts.AsOf})
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case rng.suffix:
+		opts.Offset = max(info.Size-rng.last, 0)
+		opts.Length = info.Size - opts.Offset
+	case rng.last == -1 || rng.last >= info.Size:
+		opts.Offset, opts.Length = rng.offset, max(info.Size-rng.offset, 0)
+	default:
+		opts.Offset, opts.Length = rng.offset, rng.last-rng.offset+1
+	}
+
+	if opts.Length == 0 {
+		// Nothing can be read, so the range is not satisfiable.
+		opts.Offset = info.Size
+	}
+
+	return info.Size, nil
+}
+
+// contentRange formats the value of a Content-Range header for a partial content response.
+func contentRange(offset, length, size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", offset, offset+length-1, size)
+}
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// reap periodically removes the expired workspaces of every provider until the context is canceled.
+func (s *server) reap(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, provider := range s.client.Providers() {
+			removed, err := s.client.ReapExpired(ctx, provider)
+			for _, id := range removed {
+				log.Printf("removed expired workspace %s", id)
+			}
+			if err != nil && ctx.Err() == nil {
+				log.Printf("failed to reap expired %s workspaces: %v", provider, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gptscript-ai/workspace-provider/pkg/client"
+)
+
+func (s *server) removeMatching(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	query := r.URL.Query()
+
+	opts := client.RemoveMatchingOptions{
+		Include: patterns(query, "include"),
+		Exclude: patterns(query, "exclude"),
+		DryRun:  query.Get("dryRun") == "true",
+	}
+	if len(opts.Include) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("at least one include pattern is required"))
+		return
+	}
+
+	files, err := s.client.RemoveMatching(r.Context(), id, opts)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+
+	b, err := json.Marshal(files)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(fmt.Sprintf("error: %s", err.Error())))
+		return
+	}
+
+	_, _ = w.Write(b)
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"io"
 	"net/http"
+	"strconv"
 
 	"github.com/gptscript-ai/workspace-provider/pkg/client"
 )
@@ -13,10 +14,29 @@ import (
 func (s *server) listRevisions(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	fileName := r.PathValue("fileName")
+	query := r.URL.Query()
 
-	revisions, err := s.client.ListRevisions(r.Context(), id, fileName)
+	opts := client.ListRevisionsOptions{
+		Cursor:      query.Get("cursor"),
+		NewestFirst: query.Get("newestFirst") == "true",
+	}
+	if limit := query.Get("limit"); limit != "" {
+		var err error
+		opts.Limit, err = strconv.Atoi(limit)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+	}
+
+	revisions, err := s.client.ListRevisions(r.Context(), id, fileName, opts)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		if iae := (*client.InvalidArgumentError)(nil); errors.As(err, &iae) {
+			w.WriteHeader(http.StatusBadRequest)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
 		_, _ = w.Write([]byte(err.Error()))
 		return
 	}
@@ -1,15 +1,22 @@
 package server
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+
+	"github.com/gptscript-ai/workspace-provider/pkg/client"
 )
 
 func (s *server) rm(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	err := s.client.Rm(r.Context(), id)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		if iae := (*client.InvalidArgumentError)(nil); errors.As(err, &iae) {
+			w.WriteHeader(http.StatusBadRequest)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
 		_, _ = w.Write([]byte(err.Error()))
 		return
 	}
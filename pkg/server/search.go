@@ -0,0 +1,64 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gptscript-ai/workspace-provider/pkg/client"
+)
+
+func (s *server) search(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	query := r.URL.Query()
+
+	opts := client.SearchOptions{
+		Regex:      query.Get("regex") == "true",
+		IgnoreCase: query.Get("ignoreCase") == "true",
+		Prefix:     query.Get("prefix"),
+		Include:    patterns(query, "include"),
+		Exclude:    patterns(query, "exclude"),
+		MimeTypes:  patterns(query, "mimeType"),
+	}
+
+	var err error
+	if value := query.Get("maxFileSize"); value != "" {
+		if opts.MaxFileSize, err = strconv.ParseInt(value, 10, 64); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(fmt.Sprintf("invalid maxFileSize: %v", err)))
+			return
+		}
+	}
+	for name, value := range map[string]*int{"context": &opts.ContextLines, "maxMatches": &opts.MaxMatches} {
+		if v := query.Get(name); v != "" {
+			if *value, err = strconv.Atoi(v); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(fmt.Sprintf("invalid %s: %v", name, err)))
+				return
+			}
+		}
+	}
+
+	if query.Get("query") == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("query is required"))
+		return
+	}
+
+	matches, err := s.client.Search(r.Context(), id, query.Get("query"), opts)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+
+	b, err := json.Marshal(matches)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(fmt.Sprintf("error: %s", err.Error())))
+		return
+	}
+
+	_, _ = w.Write(b)
+}
@@ -5,11 +5,29 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gptscript-ai/workspace-provider/pkg/client"
 )
 
-func Run(ctx context.Context, client *client.Client, port int) error {
+type Options struct {
+	// SweepInterval is how often the expired files of every workspace are swept. They aren't swept if it is zero.
+	SweepInterval time.Duration
+	// ReapInterval is how often the expired workspaces of every provider are removed. They aren't removed if it is zero.
+	ReapInterval time.Duration
+}
+
+func Run(ctx context.Context, client *client.Client, port int, opts ...Options) error {
+	var opt Options
+	for _, o := range opts {
+		if o.SweepInterval != 0 {
+			opt.SweepInterval = o.SweepInterval
+		}
+		if o.ReapInterval != 0 {
+			opt.ReapInterval = o.ReapInterval
+		}
+	}
+
 	mux := http.NewServeMux()
 	s := &server{
 		client: client,
@@ -22,16 +40,40 @@ func Run(ctx context.Context, client *client.Client, port int) error {
 	mux.HandleFunc("/healthz", s.healthz)
 	mux.HandleFunc("POST /create", s.create)
 	mux.HandleFunc("POST /rm/{id}", s.rm)
+	mux.HandleFunc("POST /info/{id}", s.info)
+	mux.HandleFunc("POST /set-labels/{id}", s.setLabels)
+	mux.HandleFunc("POST /resolve/{name}", s.resolve)
+	mux.HandleFunc("POST /rename/{id}", s.rename)
+	mux.HandleFunc("POST /names", s.names)
+	mux.HandleFunc("POST /usage/{id}", s.usage)
+	mux.HandleFunc("POST /admin/list", s.list)
 	mux.HandleFunc("POST /ls/{id}/{prefix...}", s.ls)
 	mux.HandleFunc("POST /read-file/{id}/{fileName}", s.readFile)
 	mux.HandleFunc("POST /read-file-with-revision/{id}/{fileName}", s.readFileWithRevision)
 	mux.HandleFunc("POST /write-file/{id}/{fileName}", s.writeFile)
+	mux.HandleFunc("POST /append-file/{id}/{fileName}", s.appendFile)
 	mux.HandleFunc("POST /rm-file/{id}/{fileName}", s.deleteFile)
+	mux.HandleFunc("POST /move-file/{id}/{fileName}/{newFileName}", s.moveFile)
 	mux.HandleFunc("POST /stat-file/{id}/{fileName}", s.statFile)
+	mux.HandleFunc("POST /set-metadata/{id}/{fileName}", s.setMetadata)
+	mux.HandleFunc("POST /get-metadata/{id}/{fileName}", s.getMetadata)
+	mux.HandleFunc("POST /mkdir/{id}/{dirName}", s.mkdir)
+	mux.HandleFunc("POST /rm-dir/{id}/{dirName}", s.removeDir)
 	mux.HandleFunc("POST /rm-with-prefix/{id}/{prefix}", s.removeAllWithPrefix)
+	mux.HandleFunc("POST /rm-matching/{id}", s.removeMatching)
+	mux.HandleFunc("POST /batch/{id}", s.batch)
+	mux.HandleFunc("POST /search/{id}", s.search)
 	mux.HandleFunc("POST /list-revisions/{id}/{fileName}", s.listRevisions)
 	mux.HandleFunc("POST /get-revision/{id}/{fileName}/{revisionID}", s.getRevision)
 	mux.HandleFunc("POST /delete-revision/{id}/{fileName}/{revisionID}", s.deleteRevision)
+	mux.HandleFunc("POST /blame/{id}/{fileName}", s.blame)
+
+	if opt.SweepInterval > 0 {
+		go s.sweep(ctx, opt.SweepInterval)
+	}
+	if opt.ReapInterval > 0 {
+		go s.reap(ctx, opt.ReapInterval)
+	}
 
 	context.AfterFunc(ctx, func() {
 		if err := s.httpServer.Shutdown(context.Background()); err != nil {
@@ -54,3 +96,18 @@ type server struct {
 func (s *server) healthz(w http.ResponseWriter, _ *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
+
+// asOf parses the optional asOf query parameter, which must be an RFC 3339 timestamp.
+func asOf(r *http.Request) (time.Time, error) {
+	value := r.URL.Query().Get("asOf")
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid asOf time: %w", err)
+	}
+
+	return t, nil
+}
@@ -13,7 +13,14 @@ func (s *server) statFile(w http.ResponseWriter, r *http.Request) {
 	fileName := r.PathValue("fileName")
 	withLatestRevision := r.URL.Query().Get("withLatestRevision") == "true"
 
-	info, err := s.client.StatFile(r.Context(), id, fileName, client.StatOptions{WithLatestRevisionID: withLatestRevision})
+	t, err := asOf(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+
+	info, err := s.client.StatFile(r.Context(), id, fileName, client.StatOptions{WithLatestRevisionID: withLatestRevision, AsOf: t})
 	if err != nil {
 		if fnf := (*client.NotFoundError)(nil); errors.As(err, &fnf) {
 			w.WriteHeader(http.StatusNotFound)
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// sweep periodically removes the expired files of the workspaces of every provider until the context is canceled.
+func (s *server) sweep(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, provider := range s.client.Providers() {
+			removed, err := s.client.SweepAllExpired(ctx, provider)
+			for id, files := range removed {
+				log.Printf("removed %d expired files from workspace %s", len(files), id)
+			}
+			if err != nil && ctx.Err() == nil {
+				log.Printf("failed to sweep expired files of %s workspaces: %v", provider, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gptscript-ai/workspace-provider/pkg/client"
+)
+
+type usageResponse struct {
+	client.Usage
+	Quota client.Quota `json:"quota,omitzero"`
+}
+
+func (s *server) usage(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	usage, err := s.client.Usage(r.Context(), id, client.UsageOptions{Recompute: r.URL.Query().Get("recompute") == "true"})
+	if err != nil {
+		if iae := (*client.InvalidArgumentError)(nil); errors.As(err, &iae) {
+			w.WriteHeader(http.StatusBadRequest)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+
+	quota, err := s.client.Quota(r.Context(), id)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+
+	b, err := json.Marshal(usageResponse{Usage: usage, Quota: quota})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+
+	_, _ = w.Write(b)
+}
+
+// quotaExceededStatus returns the status for an error if a quota was exceeded: 413 if the file is too large, and 507
+// if the workspace is out of space.
+func quotaExceededStatus(err error) (int, bool) {
+	qee := (*client.QuotaExceededError)(nil)
+	if !errors.As(err, &qee) {
+		return 0, false
+	}
+	if qee.Limit() == client.QuotaMaxFileSize {
+		return http.StatusRequestEntityTooLarge, true
+	}
+	return http.StatusInsufficientStorage, true
+}
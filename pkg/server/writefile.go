@@ -2,8 +2,11 @@ package server
 
 import (
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/gptscript-ai/workspace-provider/pkg/client"
 )
@@ -13,13 +16,38 @@ func (s *server) writeFile(w http.ResponseWriter, r *http.Request) {
 	fileName := r.PathValue("fileName")
 	query := r.URL.Query()
 
+	metadata, err := metadataValues(query, "metadata")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+
+	expiresAt, ttl, err := expiry(query)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
+
 	opts := client.WriteOptions{
 		LatestRevisionID: query.Get("latestRevision"),
 		CreateRevision:   toPtr(query.Get("createRevision") != "false"),
+		Checksum:         query.Get("checksum"),
+		ContentType:      query.Get("contentType"),
+		Metadata:         metadata,
+		ExpiresAt:        expiresAt,
+		TTL:              ttl,
 	}
 
 	if err := s.client.WriteFile(r.Context(), id, fileName, base64.NewDecoder(base64.StdEncoding, r.Body), opts); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		if cme, iae := (*client.ChecksumMismatchError)(nil), (*client.InvalidArgumentError)(nil); errors.As(err, &cme) || errors.As(err, &iae) {
+			w.WriteHeader(http.StatusBadRequest)
+		} else if status, ok := quotaExceededStatus(err); ok {
+			w.WriteHeader(status)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
 		_, _ = w.Write([]byte(err.Error()))
 		return
 	}
@@ -27,6 +55,27 @@ func (s *server) writeFile(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write([]byte(fmt.Sprintf("file %s has been written to workspace %s", fileName, id)))
 }
 
+// expiry parses the optional expiresAt query parameter, which must be an RFC 3339 timestamp, and the optional ttl query
+// parameter, which is a duration such as "1h".
+func expiry(query url.Values) (time.Time, time.Duration, error) {
+	var (
+		expiresAt time.Time
+		ttl       time.Duration
+		err       error
+	)
+	if value := query.Get("expiresAt"); value != "" {
+		if expiresAt, err = time.Parse(time.RFC3339Nano, value); err != nil {
+			return time.Time{}, 0, fmt.Errorf("invalid expiresAt time: %w", err)
+		}
+	}
+	if value := query.Get("ttl"); value != "" {
+		if ttl, err = time.ParseDuration(value); err != nil {
+			return time.Time{}, 0, fmt.Errorf("invalid ttl: %w", err)
+		}
+	}
+	return expiresAt, ttl, nil
+}
+
 func toPtr[T any](t T) *T {
 	return &t
 }